@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/lc/void/pkg/client"
+)
+
+// newAuditCmd builds the `void audit` command.
+func newAuditCmd(cli *client.Client) *cobra.Command {
+	var (
+		since  string
+		asJSON bool
+	)
+	auditCmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Show who changed which blocks, and why",
+		Long: `Show the daemon's audit log: every rule added, updated, expired or
+removed and every pf sync, with the user and process that asked for it
+and the reason they gave (see --reason). Changes the daemon made on its
+own (expiry, schedules, DNS refresh) have no user.
+
+Examples:
+  void audit                        Changes in the last 24 hours
+  void audit --since 168h           Changes in the last week
+  void audit --since 2025-03-01T00:00:00Z
+  void unblock x.com --reason "need it for work"`,
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			entries, err := cli.Audit(ctx, since)
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				for _, ev := range entries {
+					if err := enc.Encode(ev); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			if len(entries) == 0 {
+				color.Yellow("No audit entries.")
+				return nil
+			}
+
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Time", "Event", "Domain", "Actor", "Reason / Detail"})
+			table.SetBorder(false)
+			table.SetAutoWrapText(false)
+			for _, ev := range entries {
+				actor := "voidd"
+				if ev.Actor != nil {
+					actor = ev.Actor.String()
+				}
+				var notes []string
+				for _, n := range []string{ev.Reason, ev.Detail, ev.Error} {
+					if n != "" {
+						notes = append(notes, n)
+					}
+				}
+				table.Append([]string{
+					ev.Time.Local().Format("2006-01-02 15:04:05"),
+					string(ev.Type),
+					ev.Domain,
+					actor,
+					strings.Join(notes, "; "),
+				})
+			}
+			color.New(color.Bold).Println("AUDIT LOG:")
+			table.Render()
+			return nil
+		},
+	}
+	auditCmd.Flags().StringVar(&since, "since", "24h", "How far back to look: a duration or an RFC 3339 time")
+	auditCmd.Flags().BoolVar(&asJSON, "json", false, "Print raw newline-delimited JSON entries")
+	return auditCmd
+}
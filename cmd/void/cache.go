@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/lc/void/pkg/client"
+)
+
+// newCacheCmd builds the `void cache` command tree.
+func newCacheCmd(cli *client.Client) *cobra.Command {
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the daemon's DNS cache",
+		Long: `The daemon caches DNS answers, including answers that a name does not
+exist, for as long as their TTL allows.
+
+Examples:
+  void cache flush    Forget every cached answer, e.g. after changing networks`,
+	}
+
+	flushCmd := &cobra.Command{
+		Use:   "flush",
+		Short: "Forget every cached DNS answer",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			n, err := cli.FlushCache(ctx)
+			if err != nil {
+				return err
+			}
+			color.New(color.FgGreen, color.Bold).Printf("✓ Flushed ")
+			color.New(color.FgHiGreen, color.Bold).Printf("%d ", n)
+			color.New(color.FgGreen, color.Bold).Println("cached DNS answers")
+			return nil
+		},
+	}
+
+	cacheCmd.AddCommand(flushCmd)
+	return cacheCmd
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/lc/void/internal/rules"
+	"github.com/lc/void/pkg/api"
+	"github.com/lc/void/pkg/client"
+)
+
+// newExtendCmd builds the `void extend` command.
+func newExtendCmd(cli *client.Client) *cobra.Command {
+	var fromNow, replace bool
+	extendCmd := &cobra.Command{
+		Use:     "extend <domain|id|id-prefix> <duration>",
+		Aliases: []string{"ext"},
+		Short:   "Extend (or shorten) a temporary block",
+		Long: `Change when a temporary block expires without recreating it.
+
+By default the duration is added to the current expiry and the block can
+only get longer. --from-now sets the expiry relative to the current time
+instead, and --replace allows the new expiry to be earlier than the old
+one. Locked blocks can never be shortened.
+
+Examples:
+  void extend twitter.com 1h              Add an hour to the twitter.com block
+  void extend twitter.com 2h --from-now   Expire two hours from now, if that is later
+  void extend 3f2a 10m --from-now --replace
+                                          Expire in ten minutes, even if that is sooner`,
+		Example: "void extend twitter.com 1h",
+		Args:    cobra.ExactArgs(2),
+		RunE: func(_ *cobra.Command, args []string) error {
+			dur, err := time.ParseDuration(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid duration: %w", err)
+			}
+			if dur <= 0 {
+				return errors.New("duration must be positive")
+			}
+			req := api.ExpiryRequest{Target: args[0], Extend: dur}
+			if fromNow {
+				req.Extend, req.TTL = 0, dur
+			}
+			if replace {
+				req.Policy = rules.Replace.String()
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			rule, err := cli.UpdateExpiry(ctx, req)
+			if err != nil {
+				return err
+			}
+			printExpiry(rule)
+			return nil
+		},
+	}
+	extendCmd.Flags().BoolVar(&fromNow, "from-now", false, "Expire this long from now instead of adding to the current expiry")
+	extendCmd.Flags().BoolVar(&replace, "replace", false, "Allow the new expiry to be earlier than the current one")
+	return extendCmd
+}
+
+// newConvertCmd builds the `void convert` command.
+func newConvertCmd(cli *client.Client) *cobra.Command {
+	var permanent bool
+	convertCmd := &cobra.Command{
+		Use:   "convert <domain|id|id-prefix> [<duration>]",
+		Short: "Turn a block permanent, or a permanent block temporary",
+		Long: `Convert a block between permanent and temporary in place.
+
+Examples:
+  void convert twitter.com --permanent   Make the twitter.com block permanent
+  void convert twitter.com 2h            Make a permanent block expire in two hours`,
+		Example: "void convert twitter.com --permanent",
+		Args: func(_ *cobra.Command, args []string) error {
+			if permanent {
+				return cobra.ExactArgs(1)(nil, args)
+			}
+			if len(args) != 2 {
+				return errors.New("requires a duration, or --permanent")
+			}
+			return nil
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			req := api.ExpiryRequest{Target: args[0], Permanent: true}
+			if !permanent {
+				dur, err := time.ParseDuration(args[1])
+				if err != nil {
+					return fmt.Errorf("invalid duration: %w", err)
+				}
+				// Converting to temporary is a deliberate weakening.
+				req = api.ExpiryRequest{Target: args[0], TTL: dur, Policy: rules.Replace.String()}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			rule, err := cli.UpdateExpiry(ctx, req)
+			if err != nil {
+				return err
+			}
+			printExpiry(rule)
+			return nil
+		},
+	}
+	convertCmd.Flags().BoolVarP(&permanent, "permanent", "p", false, "Make the block permanent")
+	return convertCmd
+}
+
+// printExpiry reports a rule's expiry after a change.
+func printExpiry(r rules.Rule) {
+	color.New(color.FgGreen, color.Bold).Printf("✓ ")
+	color.New(color.FgHiGreen, color.Bold).Printf("%s ", r.Domain)
+	if r.Permanent {
+		color.New(color.FgGreen, color.Bold).Println("is now blocked permanently")
+	} else {
+		color.New(color.FgGreen, color.Bold).Printf("now expires at ")
+		color.New(color.FgHiYellow, color.Bold).Printf("%s ", r.Expires.Format(time.RFC1123))
+		color.New(color.FgHiBlack).Printf("(in %s)\n", time.Until(r.Expires).Round(time.Second))
+	}
+	color.New(color.FgHiBlack).Printf("  rule ID: %s\n", r.ID)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/lc/void/internal/focus"
+	"github.com/lc/void/pkg/api"
+	"github.com/lc/void/pkg/client"
+)
+
+// newFocusCmd builds the `void focus` command tree.
+func newFocusCmd(cli *client.Client) *cobra.Command {
+	var (
+		work, brk time.Duration
+		cycles    int
+		groupName string
+	)
+	focusCmd := &cobra.Command{
+		Use:   "focus [<domain>...]",
+		Short: "Start a focus session alternating blocks and breaks",
+		Long: `Start a pomodoro-style focus session. The domains are blocked during each
+work phase and reachable during the breaks in between; the session ends
+after the last work phase. A domain you block yourself, before or during
+the session, stays blocked when the session ends or is stopped, and a
+running session survives a restart of the daemon.
+
+Examples:
+  void focus --group distractions
+  void focus --work 50m --break 10m --cycles 4 --group distractions
+  void focus twitter.com reddit.com --work 25m --break 5m
+  void focus status
+  void focus stop`,
+		Args: func(_ *cobra.Command, args []string) error {
+			if len(args) == 0 && groupName == "" {
+				return errors.New("requires at least one domain or --group")
+			}
+			return nil
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			sess, err := cli.StartSession(ctx, api.SessionRequest{
+				Domains: args,
+				Group:   groupName,
+				Work:    work,
+				Break:   brk,
+				Cycles:  cycles,
+			})
+			if err != nil {
+				return err
+			}
+			color.New(color.FgGreen, color.Bold).Printf("✓ Focus session started: ")
+			color.New(color.FgHiYellow, color.Bold).Printf("%d × %v work / %v break\n", sess.Cycles, sess.Work, sess.Break)
+			printSession(sess)
+			return nil
+		},
+	}
+	focusCmd.Flags().DurationVarP(&work, "work", "w", 25*time.Minute, "Length of each work (blocked) phase")
+	focusCmd.Flags().DurationVarP(&brk, "break", "b", 5*time.Minute, "Length of each break")
+	focusCmd.Flags().IntVarP(&cycles, "cycles", "c", 4, "Number of work phases")
+	focusCmd.Flags().StringVarP(&groupName, "group", "g", "", "Block the domains of this group")
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the current phase and time remaining",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+
+			sess, err := cli.Session(ctx)
+			if err != nil {
+				return err
+			}
+			printSession(sess)
+			return nil
+		},
+	}
+
+	stopCmd := &cobra.Command{
+		Use:   "stop",
+		Short: "End the focus session and lift its blocks",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			sess, err := cli.StopSession(ctx)
+			if err != nil {
+				return err
+			}
+			color.New(color.FgGreen, color.Bold).Printf("✓ Stopped focus session ")
+			color.New(color.FgHiBlack).Printf("(%s)\n", sess.ID)
+			return nil
+		},
+	}
+
+	focusCmd.AddCommand(statusCmd, stopCmd)
+	return focusCmd
+}
+
+// printSession shows a session's phase and the time left in it.
+func printSession(sess api.SessionResponse) {
+	st := sess.State
+	switch st.Phase {
+	case focus.PhaseWork:
+		color.New(color.FgRed, color.Bold).Printf("  ● work ")
+	case focus.PhaseBreak:
+		color.New(color.FgGreen, color.Bold).Printf("  ○ break ")
+	default:
+		color.New(color.FgHiBlack, color.Bold).Println("  session finished")
+		return
+	}
+	color.New(color.FgHiBlack).Printf("(cycle %d/%d): ", st.Cycle, sess.Cycles)
+	remaining := time.Until(st.Ends).Round(time.Second)
+	color.New(color.FgHiYellow, color.Bold).Printf("%v remaining", remaining)
+	color.New(color.FgHiBlack).Printf(" (until %s)\n", st.Ends.Format("15:04:05"))
+}
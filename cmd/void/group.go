@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/lc/void/pkg/client"
+)
+
+// newGroupCmd builds the `void group` command tree.
+func newGroupCmd(cli *client.Client) *cobra.Command {
+	groupCmd := &cobra.Command{
+		Use:   "group",
+		Short: "Manage named groups of domains",
+		Long: `Manage named groups of domains that are blocked and unblocked together.
+
+Examples:
+  void group add social twitter.com x.com t.co twimg.com
+  void block --group social 2h
+  void unblock --group social`,
+	}
+
+	addCmd := &cobra.Command{
+		Use:     "add <name> <domain>...",
+		Aliases: []string{"set"},
+		Short:   "Define (or redefine) a group",
+		Example: "void group add social twitter.com x.com t.co twimg.com",
+		Args:    cobra.MinimumNArgs(2),
+		RunE: func(_ *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			g, err := cli.DefineGroup(ctx, args[0], args[1:])
+			if err != nil {
+				return err
+			}
+			color.New(color.FgGreen, color.Bold).Printf("✓ Defined group ")
+			color.New(color.FgHiGreen, color.Bold).Printf("%s ", g.Name)
+			color.New(color.FgHiBlack).Printf("(%s)\n", strings.Join(g.Domains, ", "))
+			return nil
+		},
+	}
+
+	listCmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List groups",
+		Args:    cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+
+			groups, err := cli.Groups(ctx)
+			if err != nil {
+				return err
+			}
+			if len(groups) == 0 {
+				color.Yellow("No groups defined.")
+				return nil
+			}
+
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Group", "Domains"})
+			table.SetBorder(false)
+			for _, g := range groups {
+				table.Append([]string{g.Name, strings.Join(g.Domains, ", ")})
+			}
+			color.New(color.Bold).Println("GROUPS:")
+			table.Render()
+			return nil
+		},
+	}
+
+	rmCmd := &cobra.Command{
+		Use:     "rm <name>",
+		Aliases: []string{"remove"},
+		Short:   "Delete a group definition (active rules are kept)",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			if err := cli.DeleteGroup(ctx, args[0]); err != nil {
+				return err
+			}
+			color.New(color.FgGreen, color.Bold).Printf("✓ Deleted group ")
+			color.New(color.FgHiGreen, color.Bold).Println(args[0])
+			return nil
+		},
+	}
+
+	groupCmd.AddCommand(addCmd, listCmd, rmCmd)
+	return groupCmd
+}
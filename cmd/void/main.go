@@ -7,13 +7,25 @@
 // Usage:
 //
 //	void block <domain> [<duration>]  - Block a domain (permanently or temporarily)
+//	void unblock <domain|id>...       - Remove blocking rules
+//	void extend <domain|id> <dur>     - Push back when a temporary block expires
+//	void convert <domain|id> -p       - Make a block permanent
 //	void list                         - List all currently blocked domains
+//	void show <domain|id>             - Show a block's addresses, sources and route
+//	void stats                        - Show how much traffic each block catches
+//	void group add <name> <domain>... - Define a named group of domains
+//	void schedule add <domain> ...    - Block a domain during a recurring window
+//	void focus --group <name>         - Alternate blocks and breaks (pomodoro)
+//	void watch                        - Follow daemon events live
+//	void audit --since 24h            - Show who changed which blocks, and why
+//	void cache flush                  - Forget the daemon's cached DNS answers
 //
 // Examples:
 //
 //	void block facebook.com           - Block facebook.com permanently (with confirmation)
 //	void block twitter.com 2h         - Block twitter.com for 2 hours
 //	void block youtube.com 30m        - Block youtube.com for 30 minutes
+//	void unblock twitter.com          - Remove the block on twitter.com
 //	void list                         - Show all currently blocked domains
 //
 // Durations use Go duration syntax ("1h", "30m", "2h30m", etc.). Omitting duration
@@ -22,6 +34,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -34,6 +47,9 @@ import (
 
 	"github.com/lc/void/internal/buildinfo"
 	"github.com/lc/void/internal/config"
+	"github.com/lc/void/internal/dnsresolver"
+	"github.com/lc/void/internal/engine"
+	"github.com/lc/void/pkg/api"
 	"github.com/lc/void/pkg/client"
 )
 
@@ -50,6 +66,11 @@ func main() {
 		Long: `Void is a site blocking tool that allows users to block distracting websites.
 The tool uses macOS packet filter (pf) to block domains at the network level.`,
 	}
+	var reason string
+	root.PersistentFlags().StringVar(&reason, "reason", "", "Why you are making this change (recorded in the audit log)")
+	root.PersistentPreRun = func(_ *cobra.Command, _ []string) {
+		cli.SetReason(reason)
+	}
 	// ---- version command ----
 	versionCmd := &cobra.Command{
 		Use:   "version",
@@ -61,6 +82,8 @@ The tool uses macOS packet filter (pf) to block domains at the network level.`,
 		},
 	}
 	// ---- block command ----
+	var blockGroup, lockFor, strategy string
+	var blockWait time.Duration
 	blockCmd := &cobra.Command{
 		Use:     "block <domain> [duration]",
 		Aliases: []string{"b"},
@@ -69,28 +92,62 @@ The tool uses macOS packet filter (pf) to block domains at the network level.`,
 If no duration is provided, the domain will be blocked permanently
 (requires confirmation).
 
+With --group, every domain of a named group (see "void group") is blocked
+with one shared expiry and only the duration may be given.
+
 Examples:
   void block facebook.com           Block facebook.com permanently (with confirmation)
   void block twitter.com 2h         Block twitter.com for 2 hours
   void block youtube.com 30m        Block youtube.com for 30 minutes
+  void block '*.reddit.com' 1h      Block reddit.com and all its subdomains for 1 hour
+  void block --group social 2h      Block every domain in the "social" group for 2 hours
+  void block youtube.com 4h --lock  Block youtube.com for 4 hours; no unblocking early
+  void block x.com --lock=24h       Block x.com permanently; no unblocking for 24 hours
+  void block netflix.com --strategy union
+                                    Block every address any configured resolver returns
+
+A locked rule cannot be unblocked or shortened until its lock runs out.
+--lock alone locks a temporary block for its whole duration; a permanent
+block needs an explicit lock duration (--lock=<duration>).
 
 Durations use Go duration syntax (e.g., "30s", "5m", "2h", "1h30m").`,
 		Example: "void block facebook.com 2h",
-		Args:    cobra.RangeArgs(1, 2),
+		Args: func(_ *cobra.Command, args []string) error {
+			if blockGroup != "" {
+				return cobra.MaximumNArgs(1)(nil, args)
+			}
+			return cobra.RangeArgs(1, 2)(nil, args)
+		},
 		RunE: func(_ *cobra.Command, args []string) error {
-			domain := args[0]
+			target := blockGroup
+			durArgs := args
+			if blockGroup == "" {
+				target, durArgs = args[0], args[1:]
+			}
 			var dur time.Duration
-			if len(args) == 2 {
+			if len(durArgs) == 1 {
 				var err error
-				dur, err = time.ParseDuration(args[1])
+				dur, err = time.ParseDuration(durArgs[0])
 				if err != nil {
 					return fmt.Errorf("invalid duration: %w", err)
 				}
 			}
+			lock, err := parseLock(lockFor, dur)
+			if err != nil {
+				return err
+			}
+			st, err := dnsresolver.ParseStrategy(strategy)
+			if err != nil {
+				return err
+			}
 			if dur == 0 {
+				what := target
+				if blockGroup != "" {
+					what = "every domain in group " + target
+				}
 				color.New(color.FgHiRed, color.Bold).Print("WARNING: ")
 				color.New(color.FgYellow).Printf("You are about to permanently block ")
-				color.New(color.FgHiYellow, color.Bold).Printf("%s\n", domain)
+				color.New(color.FgHiYellow, color.Bold).Printf("%s\n", what)
 				color.New(color.FgYellow).Println("This will block the domain until explicitly unblocked.")
 				color.New(color.FgHiWhite).Print("Are you sure you want to proceed? (y/yes/n/no): ")
 
@@ -106,36 +163,142 @@ Durations use Go duration syntax (e.g., "30s", "5m", "2h", "1h30m").`,
 					return fmt.Errorf("operation aborted")
 				}
 			}
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			// The daemon answers only once the domains are resolved and
+			// pf is synced, so wait for as many lookups as that takes.
+			wait := blockWait
+			if wait <= 0 {
+				domains := []string{target}
+				if blockGroup != "" {
+					domains = groupDomains(cli, blockGroup)
+				}
+				wait = blockTimeout(cfg, engine.LookupRounds(domains, cfg.Rules.WildcardPrefixes))
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), wait)
 			defer cancel()
 
-			if err := cli.Block(ctx, domain, dur); err != nil {
-				return err
+			var ids, pending []string
+			if blockGroup != "" {
+				resp, err := cli.BlockGroup(ctx, blockGroup, dur, lock, st)
+				if err != nil {
+					return blockError(err, wait)
+				}
+				for _, msg := range resp.Errors {
+					color.New(color.FgHiRed, color.Bold).Print("✗ ")
+					color.New(color.FgYellow).Println(msg)
+				}
+				ids, pending = resp.IDs, resp.Pending
+				target = fmt.Sprintf("group %s (%d domains)", blockGroup, len(ids))
+			} else {
+				resp, err := cli.Block(ctx, target, dur, lock, st)
+				if err != nil {
+					return blockError(err, wait)
+				}
+				ids, pending = []string{resp.ID}, resp.Pending
 			}
 
 			if dur == 0 {
 				color.New(color.FgGreen, color.Bold).Printf("✓ Successfully blocked ")
-				color.New(color.FgHiGreen, color.Bold).Printf("%s ", domain)
+				color.New(color.FgHiGreen, color.Bold).Printf("%s ", target)
 				color.New(color.FgGreen, color.Bold).Println("permanently")
+			} else {
+				color.New(color.FgGreen, color.Bold).Printf("✓ Successfully blocked ")
+				color.New(color.FgHiGreen, color.Bold).Printf("%s ", target)
+				color.New(color.FgGreen, color.Bold).Printf("for ")
+				color.New(color.FgHiYellow, color.Bold).Printf("%s\n", dur.String())
+			}
+			if lock > 0 {
+				color.New(color.FgYellow).Printf("  locked until %s\n", time.Now().Add(lock).Format(time.RFC1123))
+			}
+			for _, id := range ids {
+				color.New(color.FgHiBlack).Printf("  rule ID: %s\n", id)
+			}
+			for _, id := range pending {
+				color.New(color.FgYellow).Printf("  rule %s is pending: DNS failed, enforced once it resolves\n", id)
+			}
+
+			return nil
+		},
+	}
+	blockCmd.Flags().StringVarP(&blockGroup, "group", "g", "", "Block every domain of the named group")
+	blockCmd.Flags().StringVar(&lockFor, "lock", "", "Refuse unblocking or shortening for this long (default: the whole duration)")
+	blockCmd.Flags().Lookup("lock").NoOptDefVal = _lockWhole
+	blockCmd.Flags().DurationVar(&blockWait, "timeout", 0, "How long to wait for the daemon (default: derived from the DNS timeout, retries and domains to resolve)")
+	blockCmd.Flags().StringVar(&strategy, "strategy", "", `How to resolve the domain: "first" (healthiest resolver) or "union" (every resolver)`)
+
+	// ---- unblock command ----
+	var unblockGroup string
+	unblockCmd := &cobra.Command{
+		Use:     "unblock <domain|id|id-prefix>...",
+		Aliases: []string{"ub"},
+		Short:   "Remove blocking rules",
+		Long: `Remove one or more blocking rules. Each argument may be a blocked
+domain, a full rule ID, or a prefix of a rule ID that matches exactly one rule.
+
+Examples:
+  void unblock twitter.com            Unblock twitter.com
+  void unblock 3f2a                   Unblock the rule whose ID starts with 3f2a
+  void unblock x.com reddit.com       Unblock several domains at once
+  void unblock --group social         Unblock every rule of the "social" group`,
+		Example: "void unblock twitter.com",
+		Args: func(_ *cobra.Command, args []string) error {
+			if unblockGroup != "" {
+				return cobra.NoArgs(nil, args)
+			}
+			return cobra.MinimumNArgs(1)(nil, args)
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			var (
+				results []api.UnblockResult
+				err     error
+			)
+			if unblockGroup != "" {
+				results, err = cli.UnblockGroup(ctx, unblockGroup)
+			} else {
+				results, err = cli.Unblock(ctx, args...)
+			}
+			if err != nil {
+				return err
+			}
+			if unblockGroup != "" && len(results) == 0 {
+				color.Yellow("No active rules in group %s.", unblockGroup)
 				return nil
 			}
-			color.New(color.FgGreen, color.Bold).Printf("✓ Successfully blocked ")
-			color.New(color.FgHiGreen, color.Bold).Printf("%s ", domain)
-			color.New(color.FgGreen, color.Bold).Printf("for ")
-			color.New(color.FgHiYellow, color.Bold).Printf("%s\n", dur.String())
 
+			failed := 0
+			for _, r := range results {
+				if r.Error != "" {
+					failed++
+					color.New(color.FgHiRed, color.Bold).Printf("✗ %s: ", r.Target)
+					color.New(color.FgYellow).Println(r.Error)
+					continue
+				}
+				color.New(color.FgGreen, color.Bold).Printf("✓ Unblocked ")
+				color.New(color.FgHiGreen, color.Bold).Printf("%s ", r.Domain)
+				color.New(color.FgHiBlack).Printf("(rule %s)\n", r.ID)
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d targets not unblocked", failed, len(results))
+			}
 			return nil
 		},
 	}
 
+	unblockCmd.Flags().StringVarP(&unblockGroup, "group", "g", "", "Unblock every rule of the named group")
+
 	showPermanent := false
+	expand := false
 	// ---- list command ----
 	listCmd := &cobra.Command{
 		Use:     "list",
 		Aliases: []string{"ls"},
 		Short:   "List currently active rules",
 		Long: `List all currently active domain blocking rules.
-Shows domain, rule ID, whether the rule is permanent, and when it expires (if temporary).`,
+Shows domain, rule ID, whether the rule is permanent, when it expires (if temporary),
+and its status: "active", or "pending" while its domain has not resolved yet and
+nothing is blocked. The daemon keeps retrying pending rules with growing delays.`,
 		Example: "void list",
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -151,12 +314,13 @@ Shows domain, rule ID, whether the rule is permanent, and when it expires (if te
 
 			// Create a new table
 			table := tablewriter.NewWriter(os.Stdout)
-			table.SetHeader([]string{"Rule ID", "Domain", "Permanent", "Expires"})
+			table.SetHeader([]string{"Rule ID", "Domain", "Permanent", "Expires", "Status"})
 			table.SetHeaderColor(
 				tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiCyanColor},
 				tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiCyanColor},
 				tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiCyanColor},
 				tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiCyanColor},
+				tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiCyanColor},
 			)
 			table.SetBorder(false)
 			table.SetColumnColor(
@@ -164,10 +328,41 @@ Shows domain, rule ID, whether the rule is permanent, and when it expires (if te
 				tablewriter.Colors{tablewriter.FgGreenColor},
 				tablewriter.Colors{tablewriter.FgYellowColor},
 				tablewriter.Colors{tablewriter.FgHiWhiteColor},
+				tablewriter.Colors{tablewriter.FgHiWhiteColor},
 			)
 
+			// Group members are collapsed into a single row unless --expand.
+			type groupRow struct {
+				domains   int
+				pending   int
+				permanent bool
+				expires   time.Time
+			}
+			groups := make(map[string]*groupRow)
+			var groupOrder []string
+
 			// Add data to the table
 			for _, r := range rules {
+				if r.Group != "" && !expand {
+					g, ok := groups[r.Group]
+					if !ok {
+						g = &groupRow{permanent: true}
+						groups[r.Group] = g
+						groupOrder = append(groupOrder, r.Group)
+					}
+					g.domains++
+					if r.Pending {
+						g.pending++
+					}
+					if !r.Permanent {
+						g.permanent = false
+						if g.expires.IsZero() || r.Expires.Before(g.expires) {
+							g.expires = r.Expires // members normally share one expiry
+						}
+					}
+					continue
+				}
+
 				expires := "N/A"
 				if !r.Permanent {
 					expires = r.Expires.Format(time.RFC3339)
@@ -180,7 +375,33 @@ Shows domain, rule ID, whether the rule is permanent, and when it expires (if te
 				if r.Permanent && !showPermanent {
 					continue
 				}
-				table.Append([]string{r.ID, r.Domain, permanent, expires})
+				domain := r.Domain
+				if r.Wildcard() {
+					domain = fmt.Sprintf("%s (%d hosts)", r.Domain, len(r.Hosts))
+				}
+				if r.Locked(time.Now()) {
+					domain += " [locked until " + r.LockedUntil.Format(time.RFC3339) + "]"
+				}
+				status := "active"
+				if r.Pending {
+					status = "pending, retry " + r.NextAttempt.Format(time.TimeOnly)
+				}
+				table.Append([]string{r.ID, domain, permanent, expires, status})
+			}
+			for _, name := range groupOrder {
+				g := groups[name]
+				if g.permanent && !showPermanent {
+					continue
+				}
+				permanent, expires := "Yes", "N/A"
+				if !g.permanent {
+					permanent, expires = "No", g.expires.Format(time.RFC3339)
+				}
+				status := "active"
+				if g.pending > 0 {
+					status = fmt.Sprintf("%d pending", g.pending)
+				}
+				table.Append([]string{"group:" + name, fmt.Sprintf("%s (%d domains)", name, g.domains), permanent, expires, status})
 			}
 
 			color.New(color.Bold).Println("ACTIVE BLOCKING RULES:")
@@ -190,9 +411,82 @@ Shows domain, rule ID, whether the rule is permanent, and when it expires (if te
 	}
 
 	listCmd.Flags().BoolVarP(&showPermanent, "permanent", "p", false, "Show permanent rules only")
+	listCmd.Flags().BoolVarP(&expand, "expand", "e", false, "List group members individually")
 
-	root.AddCommand(blockCmd, listCmd, versionCmd)
+	root.AddCommand(blockCmd, unblockCmd, newExtendCmd(cli), newConvertCmd(cli), listCmd, newGroupCmd(cli), newFocusCmd(cli), newScheduleCmd(cli), newStatsCmd(cli), newShowCmd(cli), newWatchCmd(cli), newAuditCmd(cli), newCacheCmd(cli), versionCmd)
 	if err := root.Execute(); err != nil {
 		os.Exit(1)
 	}
 }
+
+// _syncAllowance is how long, beyond resolving domains, the daemon may
+// take to answer a block: syncing pf and finishing the command ahead of it.
+const _syncAllowance = 10 * time.Second
+
+// blockTimeout returns how long to wait for the daemon to answer a block
+// that takes rounds lookups in a row (see engine.LookupRounds). A lookup
+// ends once every attempt at its queries, dns.retries more than the first,
+// has timed out, and never outlasts the DNS timeout; with the union
+// strategy the upstreams are asked side by side, which takes no longer.
+// The command may be queued behind another that is resolving too, so one
+// more lookup is allowed for.
+func blockTimeout(cfg *config.Config, rounds int) time.Duration {
+	query := cfg.DNS.QueryTimeout
+	if query == 0 {
+		query = cfg.Rules.DNSTimeout
+	}
+	lookup := min(cfg.Rules.DNSTimeout, time.Duration(cfg.DNS.Retries+1)*query)
+	return time.Duration(max(rounds, 1)+1)*lookup + _syncAllowance
+}
+
+// groupDomains returns the domains of the named group, or none if the
+// daemon cannot tell; blocking the group then reports why.
+func groupDomains(cli *client.Client, name string) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	groups, err := cli.Groups(ctx)
+	if err != nil {
+		return nil
+	}
+	for _, g := range groups {
+		if g.Name == name {
+			return g.Domains
+		}
+	}
+	return nil
+}
+
+// blockError explains a block request that timed out after wait: the
+// daemon keeps processing it, so the block may still take effect.
+func blockError(err error, wait time.Duration) error {
+	if !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("no answer from the daemon within %v; the block may still be created, "+
+		"check with \"void list\" or retry with a longer --timeout: %w", wait, err)
+}
+
+// _lockWhole is the value --lock takes when given without a duration.
+const _lockWhole = "whole"
+
+// parseLock turns the --lock flag into a lock duration for a block of dur
+// (0 = permanent).
+func parseLock(flag string, dur time.Duration) (time.Duration, error) {
+	switch flag {
+	case "":
+		return 0, nil
+	case _lockWhole:
+		if dur == 0 {
+			return 0, fmt.Errorf("a permanent block needs an explicit lock duration, e.g. --lock=24h")
+		}
+		return dur, nil
+	}
+	lock, err := time.ParseDuration(flag)
+	if err != nil {
+		return 0, fmt.Errorf("invalid lock duration: %w", err)
+	}
+	if lock <= 0 || (dur > 0 && lock > dur) {
+		return 0, fmt.Errorf("lock must be positive and no longer than the block")
+	}
+	return lock, nil
+}
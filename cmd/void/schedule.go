@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/lc/void/pkg/api"
+	"github.com/lc/void/pkg/client"
+)
+
+// newScheduleCmd builds the `void schedule` command tree.
+func newScheduleCmd(cli *client.Client) *cobra.Command {
+	scheduleCmd := &cobra.Command{
+		Use:     "schedule",
+		Aliases: []string{"sched"},
+		Short:   "Manage recurring block windows",
+		Long: `Manage recurring block windows. While a window is open the domain is
+blocked; the block lifts automatically when the window closes.
+
+Windows use the daemon's local time and follow DST changes.`,
+	}
+
+	var days, from, to string
+	addCmd := &cobra.Command{
+		Use:   "add <domain>",
+		Short: "Block a domain during a recurring time window",
+		Long: `Block a domain on the given days between two local times of day.
+If --to is earlier than --from, the window runs past midnight.
+
+Examples:
+  void schedule add twitter.com --days mon-fri --from 09:00 --to 17:00
+  void schedule add reddit.com --days daily --from 22:00 --to 07:00
+  void schedule add '*.youtube.com' --days mon,wed,fri --from 13:00 --to 15:30`,
+		Example: "void schedule add twitter.com --days mon-fri --from 09:00 --to 17:00",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			sc, err := cli.AddSchedule(ctx, api.ScheduleRequest{
+				Domain: args[0],
+				Days:   days,
+				From:   from,
+				To:     to,
+			})
+			if err != nil {
+				return err
+			}
+
+			color.New(color.FgGreen, color.Bold).Printf("✓ Scheduled ")
+			color.New(color.FgHiGreen, color.Bold).Printf("%s ", sc.Domain)
+			color.New(color.FgGreen, color.Bold).Printf("on %s ", sc.Days)
+			color.New(color.FgHiYellow, color.Bold).Printf("%s-%s\n", sc.From, sc.To)
+			if sc.Active {
+				color.New(color.FgYellow).Println("  window is open: blocked now")
+			} else {
+				color.New(color.FgHiBlack).Printf("  next window: %s\n", sc.Next.Format(time.RFC1123))
+			}
+			color.New(color.FgHiBlack).Printf("  schedule ID: %s\n", sc.ID)
+			return nil
+		},
+	}
+	addCmd.Flags().StringVar(&days, "days", "daily", `Days of the week, e.g. "mon-fri", "sat,sun", "daily"`)
+	addCmd.Flags().StringVar(&from, "from", "", "Window start, local time (HH:MM)")
+	addCmd.Flags().StringVar(&to, "to", "", "Window end, local time (HH:MM)")
+	_ = addCmd.MarkFlagRequired("from")
+	_ = addCmd.MarkFlagRequired("to")
+
+	listCmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List recurring block windows",
+		Args:    cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+
+			list, err := cli.Schedules(ctx)
+			if err != nil {
+				return err
+			}
+			if len(list) == 0 {
+				color.Yellow("No schedules found.")
+				return nil
+			}
+
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Schedule ID", "Domain", "Days", "Window", "Status"})
+			table.SetBorder(false)
+			for _, sc := range list {
+				status := "next " + sc.Next.Format("Mon 15:04")
+				if sc.Active {
+					status = "active"
+				}
+				table.Append([]string{sc.ID, sc.Domain, sc.Days.String(), fmt.Sprintf("%s-%s", sc.From, sc.To), status})
+			}
+			color.New(color.Bold).Println("SCHEDULES:")
+			table.Render()
+			return nil
+		},
+	}
+
+	rmCmd := &cobra.Command{
+		Use:     "rm <schedule-id>",
+		Aliases: []string{"remove"},
+		Short:   "Remove a schedule (and lift its active block)",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			sc, err := cli.RemoveSchedule(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			color.New(color.FgGreen, color.Bold).Printf("✓ Removed schedule for ")
+			color.New(color.FgHiGreen, color.Bold).Printf("%s ", sc.Domain)
+			color.New(color.FgHiBlack).Printf("(%s)\n", sc.ID)
+			return nil
+		},
+	}
+
+	scheduleCmd.AddCommand(addCmd, listCmd, rmCmd)
+	return scheduleCmd
+}
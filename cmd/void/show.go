@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/lc/void/pkg/api"
+	"github.com/lc/void/pkg/client"
+)
+
+// newShowCmd builds the `void show` command.
+func newShowCmd(cli *client.Client) *cobra.Command {
+	showCmd := &cobra.Command{
+		Use:   "show <domain|id|id-prefix>",
+		Short: "Show everything about one block",
+		Long: `Show one rule in detail: the addresses it blocks and which upstream
+returned each, the aliases and hosts it covers, when it expires, and
+the DNS route and upstreams its names are resolved with.
+
+Examples:
+  void show twitter.com    Show the twitter.com block
+  void show 3f2a           Show the rule whose ID starts with 3f2a`,
+		Example: "void show twitter.com",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			rule, err := cli.Rule(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			printRule(rule)
+			return nil
+		},
+	}
+	return showCmd
+}
+
+// printRule prints d as a list of labelled fields.
+func printRule(d api.RuleDetail) {
+	label := color.New(color.FgHiCyan, color.Bold)
+	field := func(name, value string) {
+		label.Printf("%-11s", name+":")
+		color.New(color.FgHiWhite).Println(value)
+	}
+
+	field("Rule ID", d.ID)
+	field("Domain", d.Domain)
+	if d.Group != "" {
+		field("Group", d.Group)
+	}
+	if len(d.Hosts) > 0 {
+		field("Hosts", strings.Join(d.Hosts, ", "))
+	}
+	if len(d.CNAMEs) > 0 {
+		field("CNAMEs", strings.Join(d.CNAMEs, ", "))
+	}
+	if len(d.Targets) > 0 {
+		field("Targets", strings.Join(d.Targets, ", "))
+	}
+
+	label.Println("IPs:")
+	if len(d.IPs) == 0 {
+		color.New(color.FgYellow).Println("  none")
+	}
+	for _, ip := range d.IPs {
+		color.New(color.FgGreen).Printf("  %s", ip.String())
+		if src := d.Sources[ip.String()]; len(src) > 0 {
+			color.New(color.FgHiBlack).Printf("  (%s)", strings.Join(src, ", "))
+		}
+		color.New().Println()
+	}
+
+	if d.Pending {
+		label.Printf("%-11s", "Status:")
+		color.New(color.FgYellow).Printf("pending, attempt %d failed: %s\n", d.Attempts, d.LastError)
+		field("Retry", d.NextAttempt.Format(time.RFC3339))
+	} else {
+		field("Status", "active")
+	}
+	field("Created", d.Created.Format(time.RFC3339))
+	if d.Permanent {
+		field("Expires", "never")
+	} else {
+		field("Expires", d.Expires.Format(time.RFC3339))
+	}
+	if !d.LockedUntil.IsZero() {
+		field("Locked", "until "+d.LockedUntil.Format(time.RFC3339))
+	}
+	if !d.ResolvedAt.IsZero() {
+		field("Resolved", d.ResolvedAt.Format(time.RFC3339))
+	}
+	if d.TTL > 0 {
+		field("DNS TTL", d.TTL.String())
+	}
+
+	strategy := d.Strategy
+	if strategy == "" {
+		strategy = "default"
+	}
+	field("Strategy", strategy)
+	route := d.Route
+	if route == "" {
+		route = "default"
+	}
+	field("Route", route)
+	if len(d.Upstreams) > 0 {
+		field("Upstreams", strings.Join(d.Upstreams, ", "))
+	}
+
+	if c := d.Counters; c != nil {
+		field("Packets", strconv.FormatUint(c.Packets, 10))
+		field("Bytes", formatBytes(c.Bytes))
+	}
+}
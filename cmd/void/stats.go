@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/lc/void/pkg/client"
+)
+
+// newStatsCmd builds the `void stats` command.
+func newStatsCmd(cli *client.Client) *cobra.Command {
+	statsCmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show how much traffic each block is catching",
+		Long: `Show pf's counters for every rule: packets and bytes blocked and states
+created since the rule was last loaded into pf. Busiest rules come first.
+A dash means pf has no counters for the rule (e.g. it was just added).`,
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			list, err := cli.Stats(ctx)
+			if err != nil {
+				return err
+			}
+			if len(list) == 0 {
+				color.Yellow("No active blocking rules found.")
+				return nil
+			}
+			sort.SliceStable(list, func(i, j int) bool {
+				var a, b uint64
+				if list[i].Counters != nil {
+					a = list[i].Counters.Packets
+				}
+				if list[j].Counters != nil {
+					b = list[j].Counters.Packets
+				}
+				return a > b
+			})
+
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Rule ID", "Domain", "Packets", "Bytes", "States"})
+			table.SetBorder(false)
+			for _, r := range list {
+				row := []string{r.ID, r.Domain, "-", "-", "-"}
+				if c := r.Counters; c != nil {
+					row[2] = strconv.FormatUint(c.Packets, 10)
+					row[3] = formatBytes(c.Bytes)
+					row[4] = strconv.FormatUint(c.States, 10)
+				}
+				table.Append(row)
+			}
+			color.New(color.Bold).Println("RULE HIT COUNTERS:")
+			table.Render()
+			return nil
+		},
+	}
+	return statsCmd
+}
+
+// formatBytes renders n in binary units, e.g. "1.5 KiB".
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/lc/void/internal/events"
+	"github.com/lc/void/pkg/api"
+	"github.com/lc/void/pkg/client"
+)
+
+// newWatchCmd builds the `void watch` command.
+func newWatchCmd(cli *client.Client) *cobra.Command {
+	var asJSON bool
+	watchCmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Follow what the daemon is doing, live",
+		Long: `Stream daemon events as they happen: rules being added, updated,
+expired and removed, DNS refresh results and pf syncs. Press Ctrl-C to stop.
+
+Examples:
+  void watch
+  void watch --json | jq 'select(.type == "rule.expired")'`,
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			enc := json.NewEncoder(os.Stdout)
+			err := cli.Watch(ctx, func(ev events.Event) error {
+				if asJSON {
+					return enc.Encode(ev)
+				}
+				printEvent(ev)
+				return nil
+			})
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		},
+	}
+	watchCmd.Flags().BoolVar(&asJSON, "json", false, "Print raw newline-delimited JSON events")
+	return watchCmd
+}
+
+// printEvent writes one event as a colored log line.
+func printEvent(ev events.Event) {
+	var c *color.Color
+	switch {
+	case ev.Error != "", ev.Type == api.StreamDropped:
+		c = color.New(color.FgHiRed, color.Bold)
+	case ev.Type == events.RuleAdded:
+		c = color.New(color.FgGreen, color.Bold)
+	case ev.Type == events.RuleExpired, ev.Type == events.RuleRemoved, ev.Type == events.RulePending:
+		c = color.New(color.FgYellow, color.Bold)
+	default:
+		c = color.New(color.FgCyan)
+	}
+
+	color.New(color.FgHiBlack).Printf("%s ", ev.Time.Local().Format("15:04:05"))
+	c.Printf("%-18s ", ev.Type)
+	if ev.Domain != "" {
+		color.New(color.FgHiWhite, color.Bold).Printf("%s ", ev.Domain)
+	}
+	var extra []string
+	if ev.Detail != "" {
+		extra = append(extra, ev.Detail)
+	}
+	if ev.RuleID != "" {
+		extra = append(extra, "rule "+ev.RuleID)
+	}
+	if ev.Actor != nil {
+		extra = append(extra, "by "+ev.Actor.String())
+	}
+	if ev.Reason != "" {
+		extra = append(extra, fmt.Sprintf("%q", ev.Reason))
+	}
+	if len(extra) > 0 {
+		color.New(color.FgHiBlack).Printf("(%s)", strings.Join(extra, ", "))
+	}
+	if ev.Error != "" {
+		color.New(color.FgHiRed).Printf(" %s", ev.Error)
+	}
+	fmt.Println()
+}
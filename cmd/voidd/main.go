@@ -2,16 +2,23 @@ package main
 
 import (
 	"context"
+	"crypto/x509"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/lc/void/internal/audit"
 	"github.com/lc/void/internal/config"
 	"github.com/lc/void/internal/dnsresolver"
 	"github.com/lc/void/internal/engine"
+	"github.com/lc/void/internal/focus"
+	"github.com/lc/void/internal/group"
 	"github.com/lc/void/internal/log"
 	"github.com/lc/void/internal/pf"
+	"github.com/lc/void/internal/rules"
+	"github.com/lc/void/internal/schedule"
 	"github.com/lc/void/pkg/api"
 )
 
@@ -28,11 +35,31 @@ func main() {
 	}
 
 	// build deps
-	res := dnsresolver.New(cfg.Rules.DNSTimeout)
+	res, err := newResolver(cfg)
+	if err != nil {
+		log.Fatalf("dns config error: %v", err)
+	}
 	pfMgr := pf.New()
+	store, err := rules.NewFileStore(rules.DefaultStatePath)
+	if err != nil {
+		log.Fatalf("failed to open rule state: %v", err)
+	}
+	auditLog, err := audit.Open(audit.DefaultPath)
+	if err != nil {
+		log.Fatalf("failed to open audit log: %v", err)
+	}
+	defer auditLog.Close()
 
 	ctx, cancel := context.WithCancel(context.Background())
-	eng := engine.New(pfMgr, res, cfg.Rules.RefreshInterval)
+	eng := engine.New(pfMgr, res, cfg.Rules.RefreshInterval,
+		engine.WithStore(store),
+		engine.WithRefreshBounds(cfg.Rules.MinRefresh, cfg.Rules.MaxRefresh),
+		engine.WithWildcardPrefixes(cfg.Rules.WildcardPrefixes),
+		engine.WithScheduleStore(schedule.NewFileStore(schedule.DefaultPath)),
+		engine.WithGroupStore(group.NewFileStore(group.DefaultPath)),
+		engine.WithSessionStore(focus.NewFileStore(focus.DefaultPath)),
+		engine.WithAuditLog(auditLog),
+	)
 	eng.Run(ctx)
 
 	// start the api over unix socket
@@ -61,3 +88,62 @@ func main() {
 	cancel()
 	eng.Close()
 }
+
+// newResolver builds the DNS resolver described by cfg. Without configured
+// upstreams it resolves like the rest of the system, so the blocked
+// addresses are the ones applications actually connect to. Answers are
+// cached unless the config turns the cache off, but never for longer than
+// the longest refresh interval. The engine's refreshes skip the cache (see
+// dnsresolver.ContextWithRefresh), so they always reach the network.
+func newResolver(cfg *config.Config) (dnsresolver.Clienter, error) {
+	upstreams := cfg.DNS.Upstreams
+	if len(upstreams) == 0 {
+		upstreams = []string{dnsresolver.System}
+	}
+	queryTimeout := cfg.DNS.QueryTimeout
+	if queryTimeout == 0 {
+		queryTimeout = cfg.Rules.DNSTimeout
+	}
+
+	opts := []dnsresolver.Opt{
+		dnsresolver.WithResolvers(upstreams),
+		dnsresolver.WithTimeout(cfg.Rules.DNSTimeout),
+		dnsresolver.WithRetries(cfg.DNS.Retries),
+		dnsresolver.WithStrategy(dnsresolver.Strategy(cfg.DNS.Strategy)),
+		// Browsers connect to the addresses in HTTPS records too.
+		dnsresolver.WithServiceHints(),
+	}
+	if cfg.DNS.Race {
+		opts = append(opts, dnsresolver.WithRace())
+	}
+	if len(cfg.DNS.Routes) > 0 {
+		routes := make([]dnsresolver.Route, 0, len(cfg.DNS.Routes))
+		for _, rt := range cfg.DNS.Routes {
+			routes = append(routes, dnsresolver.Route{Suffix: rt.Suffix, Upstreams: rt.Upstreams})
+		}
+		opts = append(opts, dnsresolver.WithRoutes(routes))
+	}
+	if cfg.DNS.RootCAs != "" {
+		pem, err := os.ReadFile(cfg.DNS.RootCAs)
+		if err != nil {
+			return nil, fmt.Errorf("reading root CAs: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.DNS.RootCAs)
+		}
+		opts = append(opts, dnsresolver.WithRootCAs(roots))
+	}
+	client := dnsresolver.New(queryTimeout, opts...)
+	if cfg.DNS.CacheSize < 0 {
+		return client, nil
+	}
+	maxTTL := cfg.Rules.MaxRefresh
+	if maxTTL == 0 {
+		maxTTL = cfg.Rules.RefreshInterval
+	}
+	return dnsresolver.NewCache(client,
+		dnsresolver.WithCacheSize(cfg.DNS.CacheSize),
+		dnsresolver.WithMaxCacheTTL(maxTTL),
+	), nil
+}
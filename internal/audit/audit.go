@@ -0,0 +1,145 @@
+// Package audit keeps an append-only record of every change the Void
+// daemon makes to its rules and who asked for it, so that on a shared
+// machine "who unblocked this and when" has an answer.
+//
+// Entries are engine events (see package events) written as one JSON
+// object per line. The caller's identity comes from the kernel's peer
+// credentials on the API socket and is carried through request contexts
+// together with an optional free-form reason.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lc/void/internal/events"
+	"github.com/lc/void/internal/socket"
+)
+
+// DefaultPath is where the daemon appends its audit log.
+const DefaultPath = "/var/log/void/audit.log"
+
+// Audited reports whether events of type t belong in the audit log: rule
+// changes and pf syncs. Routine DNS refresh results are left to the event
+// stream; a refresh that changes addresses shows up as a rule update.
+func Audited(t events.Type) bool {
+	switch t {
+	case events.RuleAdded, events.RulePending, events.RuleUpdated, events.RuleExpired, events.RuleRemoved,
+		events.PFSynced, events.PFSyncFailed:
+		return true
+	}
+	return false
+}
+
+type (
+	actorKey  struct{}
+	reasonKey struct{}
+)
+
+// WithActor returns a context carrying the actor behind a request.
+func WithActor(ctx context.Context, a events.Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, a)
+}
+
+// ActorFrom returns the actor stored by WithActor, if any.
+func ActorFrom(ctx context.Context) (events.Actor, bool) {
+	a, ok := ctx.Value(actorKey{}).(events.Actor)
+	return a, ok
+}
+
+// WithReason returns a context carrying the caller's reason for a change.
+func WithReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, reasonKey{}, reason)
+}
+
+// ReasonFrom returns the reason stored by WithReason, if any.
+func ReasonFrom(ctx context.Context) string {
+	r, _ := ctx.Value(reasonKey{}).(string)
+	return r
+}
+
+// ActorFor turns socket peer credentials into an Actor, resolving the
+// user name when possible.
+func ActorFor(cred socket.PeerCred) events.Actor {
+	a := events.Actor{UID: cred.UID, GID: cred.GID, PID: cred.PID}
+	if u, err := user.LookupId(strconv.FormatUint(uint64(cred.UID), 10)); err == nil {
+		a.User = u.Username
+	}
+	return a
+}
+
+// Log is an append-only audit log file. It is safe for concurrent use.
+type Log struct {
+	mu   sync.Mutex
+	f    *os.File
+	path string
+}
+
+// Open opens (creating if needed) the audit log at path for appending.
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("creating audit log directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log: %w", err)
+	}
+	return &Log{f: f, path: path}, nil
+}
+
+// Record appends ev and syncs it to disk. Each entry is written with a
+// single write so a crash can at worst lose the last, partial line.
+func (l *Log) Record(ev events.Event) error {
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+	return l.f.Sync()
+}
+
+// Since returns the entries recorded at or after t, oldest first. Lines
+// that cannot be decoded (e.g. one torn by a crash) are skipped.
+func (l *Log) Since(t time.Time) ([]events.Event, error) {
+	f, err := os.Open(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("reading audit log: %w", err)
+	}
+	defer f.Close()
+
+	var out []events.Event
+	scan := bufio.NewScanner(f)
+	scan.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scan.Scan() {
+		var ev events.Event
+		if json.Unmarshal(scan.Bytes(), &ev) != nil {
+			continue
+		}
+		if !ev.Time.Before(t) {
+			out = append(out, ev)
+		}
+	}
+	if err := scan.Err(); err != nil {
+		return nil, fmt.Errorf("reading audit log: %w", err)
+	}
+	return out, nil
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
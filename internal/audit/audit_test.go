@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/lc/void/internal/events"
+)
+
+type AuditTestSuite struct {
+	suite.Suite
+	path string
+}
+
+func (s *AuditTestSuite) SetupTest() {
+	s.path = filepath.Join(s.T().TempDir(), "void", "audit.log")
+}
+
+func (s *AuditTestSuite) TestRecordAndSince() {
+	l, err := Open(s.path)
+	s.Require().NoError(err)
+	defer l.Close()
+
+	base := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	actor := &events.Actor{UID: 501, GID: 20, PID: 4242, User: "alice"}
+	s.Require().NoError(l.Record(events.Event{Seq: 1, Time: base, Type: events.RuleAdded, Domain: "x.com"}))
+	s.Require().NoError(l.Record(events.Event{
+		Seq: 2, Time: base.Add(time.Hour), Type: events.RuleRemoved, Domain: "x.com",
+		Actor: actor, Reason: "done for the day",
+	}))
+
+	all, err := l.Since(time.Time{})
+	s.Require().NoError(err)
+	s.Len(all, 2)
+
+	recent, err := l.Since(base.Add(30 * time.Minute))
+	s.Require().NoError(err)
+	s.Require().Len(recent, 1)
+	s.Equal(events.RuleRemoved, recent[0].Type)
+	s.Equal(actor, recent[0].Actor)
+	s.Equal("done for the day", recent[0].Reason)
+}
+
+func (s *AuditTestSuite) TestAppendsAcrossOpens() {
+	l, err := Open(s.path)
+	s.Require().NoError(err)
+	s.Require().NoError(l.Record(events.Event{Seq: 1, Type: events.PFSynced}))
+	s.Require().NoError(l.Close())
+
+	// Simulate a crash mid-write followed by a restart.
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0)
+	s.Require().NoError(err)
+	_, err = f.WriteString(`{"seq":2,"ty`)
+	s.Require().NoError(err)
+	s.Require().NoError(f.Close())
+
+	l, err = Open(s.path)
+	s.Require().NoError(err)
+	defer l.Close()
+	s.Require().NoError(l.Record(events.Event{Seq: 3, Type: events.PFSynced}))
+
+	got, err := l.Since(time.Time{})
+	s.Require().NoError(err)
+	s.Require().Len(got, 1) // the torn line swallows the entry appended after it
+	s.Equal(uint64(1), got[0].Seq)
+}
+
+func (s *AuditTestSuite) TestContext() {
+	ctx := context.Background()
+	_, ok := ActorFrom(ctx)
+	s.False(ok)
+	s.Empty(ReasonFrom(ctx))
+
+	ctx = WithReason(WithActor(ctx, events.Actor{UID: 7}), "testing")
+	a, ok := ActorFrom(ctx)
+	s.True(ok)
+	s.Equal(uint32(7), a.UID)
+	s.Equal("testing", ReasonFrom(ctx))
+}
+
+func (s *AuditTestSuite) TestAudited() {
+	s.True(Audited(events.RuleExpired))
+	s.True(Audited(events.PFSynced))
+	s.False(Audited(events.DNSRefreshed))
+}
+
+func TestAuditSuite(t *testing.T) {
+	suite.Run(t, new(AuditTestSuite))
+}
@@ -13,6 +13,7 @@ import (
 
 	"gopkg.in/yaml.v3"
 
+	"github.com/lc/void/internal/dnsresolver"
 	"github.com/lc/void/internal/filesys"
 )
 
@@ -32,12 +33,17 @@ const (
 	DefaultRefreshInterval = 5 * time.Minute
 	// DefaultDNSTimeout is the default timeout for DNS resolution.
 	DefaultDNSTimeout = 5 * time.Second
+	// MinRefreshFloor is the lowest allowed dns_min_refresh.
+	MinRefreshFloor = 5 * time.Second
+	// MaxDNSRetries is the highest allowed dns.retries.
+	MaxDNSRetries = 10
 )
 
 // Config holds the application configuration.
 type Config struct {
 	Socket SocketConfig `yaml:"socket"`
 	Rules  RulesConfig  `yaml:"rules"`
+	DNS    DNSConfig    `yaml:"dns,omitempty"`
 }
 
 // SocketConfig holds socket-related configuration.
@@ -49,6 +55,52 @@ type SocketConfig struct {
 type RulesConfig struct {
 	RefreshInterval time.Duration `yaml:"dns_refresh_interval"`
 	DNSTimeout      time.Duration `yaml:"dns_timeout"`
+	// MinRefresh and MaxRefresh bound the refresh interval derived from
+	// each rule's DNS TTL. Zero means 30s and RefreshInterval respectively;
+	// rules with an unknown TTL are refreshed every RefreshInterval.
+	MinRefresh time.Duration `yaml:"dns_min_refresh,omitempty"`
+	MaxRefresh time.Duration `yaml:"dns_max_refresh,omitempty"`
+	// WildcardPrefixes are the subdomain labels resolved for "*.zone" rules.
+	// Empty means the engine's built-in list.
+	WildcardPrefixes []string `yaml:"wildcard_prefixes,omitempty"`
+}
+
+// DNSConfig holds the resolver configuration. The zero value resolves
+// through the host's own resolver configuration.
+type DNSConfig struct {
+	// Upstreams are the resolvers to query: "system" for the host's
+	// resolv.conf, "udp://host[:port]", "tcp://host[:port]" or "host:port"
+	// for plain DNS, "tls://host[:port][#server-name]" for DNS-over-TLS and
+	// "https://…" URLs for DNS-over-HTTPS. Empty means "system".
+	Upstreams []string `yaml:"upstreams,omitempty"`
+	// Retries is how many more times a failed query is tried, each time
+	// at the next upstream.
+	Retries uint `yaml:"retries,omitempty"`
+	// Strategy is "first" (the default) to ask the healthiest upstream or
+	// "union" to ask every upstream and block all the addresses they return.
+	Strategy string `yaml:"strategy,omitempty"`
+	// Race sends every query to the two healthiest upstreams at once.
+	Race bool `yaml:"race,omitempty"`
+	// QueryTimeout bounds each query to a single upstream. Zero means
+	// rules.dns_timeout, which bounds a whole lookup.
+	QueryTimeout time.Duration `yaml:"query_timeout,omitempty"`
+	// RootCAs is a PEM file of CA certificates to verify DNS-over-TLS and
+	// DNS-over-HTTPS upstreams with instead of the system roots.
+	RootCAs string `yaml:"root_cas,omitempty"`
+	// Routes send names under a domain suffix to their own upstreams, such
+	// as an internal zone to the VPN's resolver. The longest suffix wins.
+	Routes []DNSRoute `yaml:"routes,omitempty"`
+	// CacheSize is how many names the daemon keeps answers for, for as
+	// long as their TTL allows. Zero means dnsresolver.DefaultCacheSize; a
+	// negative size turns the cache off.
+	CacheSize int `yaml:"cache_size,omitempty"`
+}
+
+// DNSRoute resolves names under Suffix with Upstreams instead of the
+// default upstreams.
+type DNSRoute struct {
+	Suffix    string   `yaml:"suffix"`
+	Upstreams []string `yaml:"upstreams"`
 }
 
 // Provider defines the interface for loading configuration.
@@ -131,6 +183,60 @@ func (c *Config) Validate() error {
 	if c.Rules.DNSTimeout < time.Second {
 		return errors.New("DNS timeout must be at least 1 second")
 	}
+	if c.Rules.MinRefresh != 0 && c.Rules.MinRefresh < MinRefreshFloor {
+		return fmt.Errorf("DNS min refresh must be at least %v", MinRefreshFloor)
+	}
+	if c.Rules.MaxRefresh != 0 && c.Rules.MaxRefresh < max(c.Rules.MinRefresh, MinRefreshFloor) {
+		return errors.New("DNS max refresh must not be below the min refresh")
+	}
+	for _, p := range c.Rules.WildcardPrefixes {
+		if strings.TrimSpace(p) == "" || strings.ContainsAny(p, "* ") ||
+			strings.HasPrefix(p, ".") || strings.HasSuffix(p, ".") {
+			return fmt.Errorf("invalid wildcard prefix %q", p)
+		}
+	}
+	return c.DNS.validate(c.Rules.DNSTimeout)
+}
+
+// validate checks the resolver configuration; lookupTimeout is the
+// configured rules.dns_timeout.
+func (d DNSConfig) validate(lookupTimeout time.Duration) error {
+	for _, u := range d.Upstreams {
+		if err := dnsresolver.ValidateUpstream(u); err != nil {
+			return err
+		}
+	}
+	if d.Retries > MaxDNSRetries {
+		return fmt.Errorf("DNS retries must be at most %d", MaxDNSRetries)
+	}
+	if _, err := dnsresolver.ParseStrategy(d.Strategy); err != nil {
+		return err
+	}
+	if d.QueryTimeout < 0 || d.QueryTimeout > lookupTimeout {
+		return errors.New("DNS query timeout must not be negative or exceed the DNS timeout")
+	}
+	if d.RootCAs != "" && !filepath.IsAbs(d.RootCAs) {
+		return fmt.Errorf("DNS root CAs path %q must be absolute", d.RootCAs)
+	}
+	seen := make(map[string]bool, len(d.Routes))
+	for _, rt := range d.Routes {
+		suffix := strings.ToLower(strings.Trim(strings.TrimPrefix(rt.Suffix, "*."), "."))
+		if suffix == "" || strings.ContainsAny(suffix, "* ") {
+			return fmt.Errorf("invalid DNS route suffix %q", rt.Suffix)
+		}
+		if seen[suffix] {
+			return fmt.Errorf("duplicate DNS route for %q", suffix)
+		}
+		seen[suffix] = true
+		if len(rt.Upstreams) == 0 {
+			return fmt.Errorf("DNS route for %q has no upstreams", suffix)
+		}
+		for _, u := range rt.Upstreams {
+			if err := dnsresolver.ValidateUpstream(u); err != nil {
+				return fmt.Errorf("DNS route for %q: %w", suffix, err)
+			}
+		}
+	}
 	return nil
 }
 
@@ -231,6 +231,243 @@ func (s *ConfigTestSuite) TestValidation() {
 			expectedErr: "",
 		},
 
+		// Wildcard Prefix Validation
+		{
+			name: "wildcard prefixes valid",
+			config: config.Config{
+				Socket: config.SocketConfig{Path: "/tmp/socket"},
+				Rules: config.RulesConfig{
+					RefreshInterval:  time.Hour,
+					DNSTimeout:       time.Second,
+					WildcardPrefixes: []string{"www", "static.cdn"},
+				},
+			},
+			expectedErr: "",
+		},
+		{
+			name: "wildcard prefix with asterisk",
+			config: config.Config{
+				Socket: config.SocketConfig{Path: "/tmp/socket"},
+				Rules: config.RulesConfig{
+					RefreshInterval:  time.Hour,
+					DNSTimeout:       time.Second,
+					WildcardPrefixes: []string{"*.www"},
+				},
+			},
+			expectedErr: "invalid wildcard prefix",
+		},
+
+		// DNS Refresh Bounds Validation
+		{
+			name: "refresh bounds valid",
+			config: config.Config{
+				Socket: config.SocketConfig{Path: "/tmp/socket"},
+				Rules: config.RulesConfig{
+					RefreshInterval: time.Hour,
+					DNSTimeout:      time.Second,
+					MinRefresh:      10 * time.Second,
+					MaxRefresh:      6 * time.Hour,
+				},
+			},
+			expectedErr: "",
+		},
+		{
+			name: "min refresh too short",
+			config: config.Config{
+				Socket: config.SocketConfig{Path: "/tmp/socket"},
+				Rules: config.RulesConfig{
+					RefreshInterval: time.Hour,
+					DNSTimeout:      time.Second,
+					MinRefresh:      time.Second,
+				},
+			},
+			expectedErr: "DNS min refresh must be at least 5s",
+		},
+		{
+			name: "max refresh below min refresh",
+			config: config.Config{
+				Socket: config.SocketConfig{Path: "/tmp/socket"},
+				Rules: config.RulesConfig{
+					RefreshInterval: time.Hour,
+					DNSTimeout:      time.Second,
+					MinRefresh:      time.Minute,
+					MaxRefresh:      30 * time.Second,
+				},
+			},
+			expectedErr: "DNS max refresh must not be below the min refresh",
+		},
+
+		// DNS Validation
+		{
+			name: "DNS upstreams of every kind",
+			config: config.Config{
+				Socket: config.SocketConfig{Path: "/tmp/socket"},
+				Rules: config.RulesConfig{
+					RefreshInterval: time.Hour,
+					DNSTimeout:      time.Second * 5,
+				},
+				DNS: config.DNSConfig{Upstreams: []string{"system", "udp://10.0.0.53", "tls://1.1.1.1#cloudflare-dns.com", "https://dns.google/dns-query"}},
+			},
+			expectedErr: "",
+		},
+		{
+			name: "DNS upstream without port",
+			config: config.Config{
+				Socket: config.SocketConfig{Path: "/tmp/socket"},
+				Rules: config.RulesConfig{
+					RefreshInterval: time.Hour,
+					DNSTimeout:      time.Second * 5,
+				},
+				DNS: config.DNSConfig{Upstreams: []string{"1.1.1.1"}},
+			},
+			expectedErr: "invalid upstream address",
+		},
+		{
+			name: "DNS upstream with unknown scheme",
+			config: config.Config{
+				Socket: config.SocketConfig{Path: "/tmp/socket"},
+				Rules: config.RulesConfig{
+					RefreshInterval: time.Hour,
+					DNSTimeout:      time.Second * 5,
+				},
+				DNS: config.DNSConfig{Upstreams: []string{"quic://dns.adguard.com"}},
+			},
+			expectedErr: "unknown scheme",
+		},
+		{
+			name: "DNS retries too high",
+			config: config.Config{
+				Socket: config.SocketConfig{Path: "/tmp/socket"},
+				Rules: config.RulesConfig{
+					RefreshInterval: time.Hour,
+					DNSTimeout:      time.Second * 5,
+				},
+				DNS: config.DNSConfig{Retries: 11},
+			},
+			expectedErr: "DNS retries must be at most 10",
+		},
+		{
+			name: "DNS strategy unknown",
+			config: config.Config{
+				Socket: config.SocketConfig{Path: "/tmp/socket"},
+				Rules: config.RulesConfig{
+					RefreshInterval: time.Hour,
+					DNSTimeout:      time.Second * 5,
+				},
+				DNS: config.DNSConfig{Strategy: "fastest"},
+			},
+			expectedErr: "unknown resolution strategy",
+		},
+		{
+			name: "DNS strategy union",
+			config: config.Config{
+				Socket: config.SocketConfig{Path: "/tmp/socket"},
+				Rules: config.RulesConfig{
+					RefreshInterval: time.Hour,
+					DNSTimeout:      time.Second * 5,
+				},
+				DNS: config.DNSConfig{Strategy: "union", Race: true},
+			},
+			expectedErr: "",
+		},
+		{
+			name: "DNS query timeout above lookup timeout",
+			config: config.Config{
+				Socket: config.SocketConfig{Path: "/tmp/socket"},
+				Rules: config.RulesConfig{
+					RefreshInterval: time.Hour,
+					DNSTimeout:      time.Second * 5,
+				},
+				DNS: config.DNSConfig{QueryTimeout: 10 * time.Second},
+			},
+			expectedErr: "DNS query timeout must not be negative or exceed the DNS timeout",
+		},
+		{
+			name: "DNS query timeout negative",
+			config: config.Config{
+				Socket: config.SocketConfig{Path: "/tmp/socket"},
+				Rules: config.RulesConfig{
+					RefreshInterval: time.Hour,
+					DNSTimeout:      time.Second * 5,
+				},
+				DNS: config.DNSConfig{QueryTimeout: -time.Second},
+			},
+			expectedErr: "DNS query timeout must not be negative or exceed the DNS timeout",
+		},
+		{
+			name: "DNS root CAs relative",
+			config: config.Config{
+				Socket: config.SocketConfig{Path: "/tmp/socket"},
+				Rules: config.RulesConfig{
+					RefreshInterval: time.Hour,
+					DNSTimeout:      time.Second * 5,
+				},
+				DNS: config.DNSConfig{RootCAs: "ca.pem"},
+			},
+			expectedErr: "must be absolute",
+		},
+
+		{
+			name: "DNS routes valid",
+			config: config.Config{
+				Socket: config.SocketConfig{Path: "/tmp/socket"},
+				Rules: config.RulesConfig{
+					RefreshInterval: time.Hour,
+					DNSTimeout:      time.Second * 5,
+				},
+				DNS: config.DNSConfig{Routes: []config.DNSRoute{{Suffix: "corp.example", Upstreams: []string{"udp://10.8.0.1"}}, {Suffix: "*.lab.corp.example", Upstreams: []string{"system"}}}},
+			},
+			expectedErr: "",
+		},
+		{
+			name: "DNS route without suffix",
+			config: config.Config{
+				Socket: config.SocketConfig{Path: "/tmp/socket"},
+				Rules: config.RulesConfig{
+					RefreshInterval: time.Hour,
+					DNSTimeout:      time.Second * 5,
+				},
+				DNS: config.DNSConfig{Routes: []config.DNSRoute{{Suffix: ".", Upstreams: []string{"udp://10.8.0.1"}}}},
+			},
+			expectedErr: "invalid DNS route suffix",
+		},
+		{
+			name: "DNS route duplicate",
+			config: config.Config{
+				Socket: config.SocketConfig{Path: "/tmp/socket"},
+				Rules: config.RulesConfig{
+					RefreshInterval: time.Hour,
+					DNSTimeout:      time.Second * 5,
+				},
+				DNS: config.DNSConfig{Routes: []config.DNSRoute{{Suffix: "corp.example", Upstreams: []string{"udp://10.8.0.1"}}, {Suffix: "Corp.Example.", Upstreams: []string{"udp://10.8.0.2"}}}},
+			},
+			expectedErr: "duplicate DNS route",
+		},
+		{
+			name: "DNS route without upstreams",
+			config: config.Config{
+				Socket: config.SocketConfig{Path: "/tmp/socket"},
+				Rules: config.RulesConfig{
+					RefreshInterval: time.Hour,
+					DNSTimeout:      time.Second * 5,
+				},
+				DNS: config.DNSConfig{Routes: []config.DNSRoute{{Suffix: "corp.example"}}},
+			},
+			expectedErr: "has no upstreams",
+		},
+		{
+			name: "DNS route with bad upstream",
+			config: config.Config{
+				Socket: config.SocketConfig{Path: "/tmp/socket"},
+				Rules: config.RulesConfig{
+					RefreshInterval: time.Hour,
+					DNSTimeout:      time.Second * 5,
+				},
+				DNS: config.DNSConfig{Routes: []config.DNSRoute{{Suffix: "corp.example", Upstreams: []string{"10.8.0.1"}}}},
+			},
+			expectedErr: "invalid upstream address",
+		},
+
 		// Combined Validation
 		{
 			name: "multiple validation errors",
@@ -291,6 +528,45 @@ func (s *ConfigTestSuite) TestValidation() {
 	}
 }
 
+func (s *ConfigTestSuite) TestLoadDNSConfig() {
+	// Given a config file with a dns section
+	s.fs.files["test/config.yaml"] = `
+socket:
+  path: /custom/socket
+rules:
+  dns_refresh_interval: 1h
+  dns_timeout: 5s
+dns:
+  upstreams:
+    - system
+    - https://cloudflare-dns.com/dns-query
+  retries: 2
+  strategy: union
+  query_timeout: 2s
+  root_cas: /etc/void/ca.pem
+  cache_size: 512
+  routes:
+    - suffix: corp.example
+      upstreams: [udp://10.8.0.1]
+`
+	// When loading configuration
+	cfg, err := s.provider.Load()
+
+	// Then the resolver options should be loaded
+	s.Require().NoError(err)
+	s.Equal(config.DNSConfig{
+		Upstreams:    []string{"system", "https://cloudflare-dns.com/dns-query"},
+		Retries:      2,
+		Strategy:     "union",
+		QueryTimeout: 2 * time.Second,
+		RootCAs:      "/etc/void/ca.pem",
+		CacheSize:    512,
+		Routes: []config.DNSRoute{
+			{Suffix: "corp.example", Upstreams: []string{"udp://10.8.0.1"}},
+		},
+	}, cfg.DNS)
+}
+
 func (s *ConfigTestSuite) TestLoadInvalidYAML() {
 	// Given an invalid YAML file
 	s.fs.files["test/config.yaml"] = `
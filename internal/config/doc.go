@@ -12,6 +12,23 @@
 //	rules:
 //	  dns_refresh_interval: 1h            # How often to refresh DNS records
 //	  dns_timeout: 5s                 # Timeout for DNS queries
+//	  dns_min_refresh: 30s            # Refresh no sooner than this, whatever the TTL (optional)
+//	  dns_max_refresh: 1h             # Refresh at least this often (optional)
+//	  wildcard_prefixes: [www, m]     # Subdomains probed for "*.zone" rules (optional)
+//	dns:                              # Resolver options (optional)
+//	  upstreams:                      # Default: [system]
+//	    - system                      # The host's resolv.conf
+//	    - tls://1.1.1.1#cloudflare-dns.com
+//	    - https://dns.google/dns-query
+//	  retries: 1                      # Further tries, each at the next upstream
+//	  strategy: first                 # Or "union" to block every upstream's answers
+//	  race: false                     # Query the two healthiest upstreams at once
+//	  query_timeout: 2s               # Per upstream query; default dns_timeout
+//	  root_cas: /etc/void/ca.pem      # Trust these CAs for tls:// and https://
+//	  cache_size: 4096                # Names to cache answers for; -1 turns it off
+//	  routes:                         # Per-suffix upstreams; longest suffix wins
+//	    - suffix: corp.example
+//	      upstreams: [udp://10.8.0.1]
 //
 // # Basic Usage
 //
@@ -37,6 +54,13 @@
 //   - Socket path must not be empty
 //   - Refresh interval must be at least 1 minute
 //   - DNS timeout must be at least 1 second
+//   - DNS min refresh must be at least 5 seconds, and DNS max refresh
+//     not below it
+//   - Wildcard prefixes must be non-empty labels without "*", spaces or edge dots
+//   - DNS upstreams must be "system" or addresses with a known scheme
+//   - DNS retries must be at most 10 and the strategy "first" or "union"
+//   - DNS query timeout must not exceed the DNS timeout
+//   - DNS routes need a unique suffix and at least one valid upstream
 //
 // # Default Configuration
 //
@@ -44,6 +68,16 @@
 //   - Socket Path: /var/run/voidd.socket
 //   - Refresh Interval: 1 hour
 //   - DNS Timeout: 5 seconds
+//   - DNS Min Refresh: 30 seconds
+//   - DNS Max Refresh: the refresh interval
+//
+// # DNS Refresh
+//
+// Each rule is re-resolved shortly before the lowest TTL of its DNS records
+// runs out, with that TTL clamped between dns_min_refresh and
+// dns_max_refresh: short-lived records are not queried more often than the
+// former, long-lived ones at least as often as the latter. Rules whose TTL
+// is unknown are re-resolved every dns_refresh_interval.
 //
 // # Thread Safety
 //
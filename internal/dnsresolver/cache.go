@@ -0,0 +1,305 @@
+package dnsresolver
+
+import (
+	"context"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// DefaultCacheSize is the number of names a Cache holds by default.
+	DefaultCacheSize = 4096
+	// DefaultMaxCacheTTL caps how long a Cache keeps an answer by default,
+	// however long its TTL.
+	DefaultMaxCacheTTL = time.Hour
+	// _maxNegativeTTL caps how long a negative answer is cached, as RFC
+	// 2308 section 5 recommends.
+	_maxNegativeTTL = 3 * time.Hour
+)
+
+var _ Clienter = (*Cache)(nil)
+
+// Cache is a Clienter that keeps the resolutions of another Clienter for
+// as long as their TTL allows. Answers that a name does not exist or has
+// no addresses are kept too, for the TTL their SOA record gives (RFC 2308).
+// Lookups of a name that is already being resolved wait for that lookup
+// instead of querying again. Answers without a TTL and failures are never
+// cached.
+//
+// Names are cached per resolution strategy (see ContextWithStrategy).
+// Lookups under ContextWithRefresh skip the cached answer.
+type Cache struct {
+	next   Clienter
+	size   int
+	maxTTL time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[cacheKey]cacheEntry
+	gen     uint64 // bumped by Flush so lookups in flight are not stored
+	flight  singleflight.Group
+}
+
+type refreshKey struct{}
+
+// ContextWithRefresh returns a copy of ctx under which a Cache resolves
+// names with the underlying Clienter even while it holds an answer, and
+// keeps the new answer. Refreshing a rule before its records' TTL runs
+// out would otherwise be handed back the very answer it means to replace.
+func ContextWithRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, refreshKey{}, true)
+}
+
+type cacheKey struct {
+	name     string
+	strategy Strategy
+}
+
+type cacheEntry struct {
+	res     Resolution
+	err     error // a *NegativeError, possibly aggregated
+	expires time.Time
+}
+
+// CacheOpt is a function option for configuring a Cache.
+type CacheOpt func(c *Cache)
+
+// WithCacheSize returns an option to hold at most n names. When the cache
+// is full, the entry closest to expiring makes room. The default is
+// DefaultCacheSize.
+func WithCacheSize(n int) CacheOpt {
+	return func(c *Cache) {
+		if n > 0 {
+			c.size = n
+		}
+	}
+}
+
+// WithMaxCacheTTL returns an option to keep answers at most d, so that a
+// name is asked again at least that often whatever its TTL. The default
+// is DefaultMaxCacheTTL.
+func WithMaxCacheTTL(d time.Duration) CacheOpt {
+	return func(c *Cache) {
+		if d > 0 {
+			c.maxTTL = d
+		}
+	}
+}
+
+// NewCache returns a Cache in front of next. next must bound its own
+// lookups in time: a lookup shared by several callers runs to completion
+// even if the caller that started it gives up.
+func NewCache(next Clienter, opts ...CacheOpt) *Cache {
+	c := &Cache{
+		next:    next,
+		size:    DefaultCacheSize,
+		maxTTL:  DefaultMaxCacheTTL,
+		now:     time.Now,
+		entries: make(map[cacheKey]cacheEntry),
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// LookupHost resolves hostname like Resolve and returns its addresses.
+func (c *Cache) LookupHost(ctx context.Context, hostname string) ([]net.IPAddr, error) {
+	res, err := c.Resolve(ctx, hostname)
+	if err != nil {
+		return nil, err
+	}
+	return res.Addrs, nil
+}
+
+// Resolve returns the cached resolution of hostname, or resolves it with
+// the underlying Clienter. The TTL of a cached resolution is what is left
+// of it, so callers refresh no later than they would have otherwise.
+func (c *Cache) Resolve(ctx context.Context, hostname string) (Resolution, error) {
+	key := cacheKey{name: strings.ToLower(strings.TrimSuffix(hostname, "."))}
+	if s, ok := ctx.Value(strategyKey{}).(Strategy); ok {
+		key.strategy = s
+	}
+	if refresh, _ := ctx.Value(refreshKey{}).(bool); !refresh {
+		if e, ok := c.get(key); ok {
+			return e.res, e.err
+		}
+	}
+
+	ch := c.flight.DoChan(string(key.strategy)+" "+key.name, func() (any, error) {
+		c.mu.Lock()
+		gen := c.gen
+		c.mu.Unlock()
+		// The lookup is shared, so it must not end with the first caller.
+		res, err := c.next.Resolve(context.WithoutCancel(ctx), hostname)
+		c.put(key, gen, res, err)
+		return res, err
+	})
+	select {
+	case r := <-ch:
+		if r.Err != nil {
+			return Resolution{}, r.Err
+		}
+		return cloneResolution(r.Val.(Resolution)), nil
+	case <-ctx.Done():
+		return Resolution{}, ctx.Err()
+	}
+}
+
+// Flush drops every cached answer and returns how many there were.
+func (c *Cache) Flush() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := len(c.entries)
+	clear(c.entries)
+	c.gen++
+	return n
+}
+
+// Len returns the number of cached answers, including expired ones not
+// yet dropped.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Health returns the upstream health of the underlying Clienter, if it
+// tracks it.
+func (c *Cache) Health() []UpstreamHealth {
+	if h, ok := c.next.(interface{ Health() []UpstreamHealth }); ok {
+		return h.Health()
+	}
+	return nil
+}
+
+// UpstreamsFor returns the route and upstreams the underlying Clienter
+// uses for host, if it routes.
+func (c *Cache) UpstreamsFor(host string) (suffix string, upstreams []string) {
+	if rt, ok := c.next.(interface {
+		UpstreamsFor(host string) (string, []string)
+	}); ok {
+		return rt.UpstreamsFor(host)
+	}
+	return "", nil
+}
+
+// get returns a copy of the live entry for key, if any, with the TTL of
+// its resolution set to what is left of it.
+func (c *Cache) get(key cacheKey) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return cacheEntry{}, false
+	}
+	left := e.expires.Sub(c.now())
+	if left <= 0 {
+		delete(c.entries, key)
+		return cacheEntry{}, false
+	}
+	if e.err == nil {
+		e.res = cloneResolution(e.res)
+		e.res.TTL = (left + time.Second - 1).Truncate(time.Second) // round up, as seconds
+	}
+	return e, true
+}
+
+// put caches the outcome of resolving key, if it can be cached and the
+// cache was not flushed since generation gen began resolving it.
+func (c *Cache) put(key cacheKey, gen uint64, res Resolution, err error) {
+	ttl := min(res.TTL, c.maxTTL)
+	if err != nil {
+		neg, ok := negativeTTL(err)
+		if !ok {
+			return
+		}
+		ttl = min(neg, c.maxTTL, _maxNegativeTTL)
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+	now := c.now()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.size {
+		c.evict(now)
+	}
+	c.entries[key] = cacheEntry{res: cloneResolution(res), err: err, expires: now.Add(ttl)}
+}
+
+// evict makes room for one entry: it drops every expired entry or, if
+// there are none, the one closest to expiring. c.mu must be held.
+func (c *Cache) evict(now time.Time) {
+	var (
+		soonest cacheKey
+		first   time.Time
+	)
+	for k, e := range c.entries {
+		if !e.expires.After(now) {
+			delete(c.entries, k)
+			continue
+		}
+		if first.IsZero() || e.expires.Before(first) {
+			soonest, first = k, e.expires
+		}
+	}
+	if len(c.entries) >= c.size {
+		delete(c.entries, soonest)
+	}
+}
+
+// negativeTTL reports whether err says that a name has no addresses, as
+// opposed to a failure to find out, and for how long that may be cached.
+// An error joining several must consist of negative answers only, such as
+// NODATA for both A and AAAA, and is cached for the lowest of their TTLs.
+func negativeTTL(err error) (time.Duration, bool) {
+	switch e := err.(type) {
+	case *NegativeError:
+		return e.TTL, e.TTL > 0
+	case interface{ Unwrap() []error }:
+		errs := e.Unwrap()
+		if len(errs) == 0 {
+			return 0, false
+		}
+		var ttl time.Duration
+		for _, err := range errs {
+			t, ok := negativeTTL(err)
+			if !ok {
+				return 0, false
+			}
+			ttl = minTTL(ttl, t)
+		}
+		return ttl, true
+	case interface{ Unwrap() error }:
+		return negativeTTL(e.Unwrap())
+	}
+	return 0, false
+}
+
+// cloneResolution returns a copy of res that shares no memory with it.
+func cloneResolution(res Resolution) Resolution {
+	res.Addrs = slices.Clone(res.Addrs)
+	res.CNAMEs = slices.Clone(res.CNAMEs)
+	res.Targets = slices.Clone(res.Targets)
+	if res.Sources != nil {
+		res.Sources = maps.Clone(res.Sources)
+		for ip, src := range res.Sources {
+			res.Sources[ip] = slices.Clone(src)
+		}
+	}
+	return res
+}
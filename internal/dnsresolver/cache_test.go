@@ -0,0 +1,275 @@
+package dnsresolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/multierr"
+)
+
+// fakeClienter answers every name with answer and counts the lookups.
+// While gate is non-nil, lookups block until it is closed.
+type fakeClienter struct {
+	mu     sync.Mutex
+	answer func(host string) (Resolution, error)
+	gate   chan struct{}
+	calls  atomic.Int32
+}
+
+func (f *fakeClienter) LookupHost(ctx context.Context, host string) ([]net.IPAddr, error) {
+	res, err := f.Resolve(ctx, host)
+	return res.Addrs, err
+}
+
+func (f *fakeClienter) Resolve(_ context.Context, host string) (Resolution, error) {
+	f.calls.Add(1)
+	f.mu.Lock()
+	gate, answer := f.gate, f.answer
+	f.mu.Unlock()
+	if gate != nil {
+		<-gate
+	}
+	return answer(host)
+}
+
+type CacheTestSuite struct {
+	suite.Suite
+	next  *fakeClienter
+	cache *Cache
+	now   time.Time
+}
+
+func (s *CacheTestSuite) SetupTest() {
+	s.now = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.next = &fakeClienter{answer: func(string) (Resolution, error) {
+		return Resolution{Addrs: []net.IPAddr{{IP: net.ParseIP("192.0.2.1")}}, TTL: 5 * time.Minute}, nil
+	}}
+	s.cache = NewCache(s.next)
+	s.cache.now = func() time.Time { return s.now }
+}
+
+func (s *CacheTestSuite) TestRespectsTTL() {
+	ctx := context.Background()
+	res, err := s.cache.Resolve(ctx, "example.com")
+	s.Require().NoError(err)
+	s.Equal(5*time.Minute, res.TTL)
+
+	s.now = s.now.Add(2 * time.Minute)
+	res, err = s.cache.Resolve(ctx, "Example.COM.")
+	s.Require().NoError(err)
+	s.Equal(3*time.Minute, res.TTL, "a cached answer carries the TTL it has left")
+	s.Equal(int32(1), s.next.calls.Load())
+
+	s.now = s.now.Add(3 * time.Minute)
+	_, err = s.cache.Resolve(ctx, "example.com")
+	s.Require().NoError(err)
+	s.Equal(int32(2), s.next.calls.Load(), "an expired answer is resolved again")
+}
+
+func (s *CacheTestSuite) TestRefreshSkipsCache() {
+	ctx := context.Background()
+	_, err := s.cache.Resolve(ctx, "example.com")
+	s.Require().NoError(err)
+
+	// The addresses rotate before the cached answer expires.
+	s.now = s.now.Add(time.Minute)
+	s.next.answer = func(string) (Resolution, error) {
+		return Resolution{Addrs: []net.IPAddr{{IP: net.ParseIP("192.0.2.2")}}, TTL: 5 * time.Minute}, nil
+	}
+	res, err := s.cache.Resolve(ctx, "example.com")
+	s.Require().NoError(err)
+	s.Equal("192.0.2.1", res.Addrs[0].IP.String())
+
+	res, err = s.cache.Resolve(ContextWithRefresh(ctx), "example.com")
+	s.Require().NoError(err)
+	s.Equal("192.0.2.2", res.Addrs[0].IP.String())
+	s.Equal(5*time.Minute, res.TTL)
+	s.Equal(int32(2), s.next.calls.Load())
+
+	// The fresh answer replaces the cached one.
+	res, err = s.cache.Resolve(ctx, "example.com")
+	s.Require().NoError(err)
+	s.Equal("192.0.2.2", res.Addrs[0].IP.String())
+	s.Equal(int32(2), s.next.calls.Load())
+}
+
+func (s *CacheTestSuite) TestMaxTTL() {
+	s.cache = NewCache(s.next, WithMaxCacheTTL(time.Minute))
+	s.cache.now = func() time.Time { return s.now }
+
+	_, err := s.cache.Resolve(context.Background(), "example.com")
+	s.Require().NoError(err)
+	s.now = s.now.Add(time.Minute)
+	_, err = s.cache.Resolve(context.Background(), "example.com")
+	s.Require().NoError(err)
+	s.Equal(int32(2), s.next.calls.Load())
+}
+
+func (s *CacheTestSuite) TestNegativeCaching() {
+	nx := &NegativeError{Name: "gone.example", Err: ErrNXDomain, TTL: time.Minute}
+	nodata := &NegativeError{Name: "v4.example", Err: ErrNoRecords, TTL: 30 * time.Second}
+	s.next.answer = func(host string) (Resolution, error) {
+		switch host {
+		case "gone.example":
+			return Resolution{}, fmt.Errorf("dns lookup for %q: %w", host, nx)
+		case "v4.example":
+			return Resolution{}, multierr.Combine(nodata, &NegativeError{Name: host, Err: ErrNoRecords, TTL: time.Hour})
+		case "noSOA.example":
+			return Resolution{}, &NegativeError{Name: host, Err: ErrNXDomain}
+		case "mixed.example":
+			return Resolution{}, multierr.Combine(nodata, ErrUpstream)
+		}
+		return Resolution{}, errTimeout
+	}
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		host   string
+		ttl    time.Duration // 0 = not cached
+		target error
+	}{
+		{host: "gone.example", ttl: time.Minute, target: ErrNXDomain},
+		{host: "v4.example", ttl: 30 * time.Second, target: ErrNoRecords},
+		{host: "noSOA.example", target: ErrNXDomain},
+		{host: "mixed.example", target: ErrUpstream},
+		{host: "down.example", target: errTimeout},
+	} {
+		s.Run(tc.host, func() {
+			s.next.calls.Store(0)
+			for range 2 {
+				_, err := s.cache.Resolve(ctx, tc.host)
+				s.ErrorIs(err, tc.target)
+			}
+			if tc.ttl == 0 {
+				s.Equal(int32(2), s.next.calls.Load(), "not cached")
+				return
+			}
+			s.Equal(int32(1), s.next.calls.Load(), "cached")
+
+			s.now = s.now.Add(tc.ttl)
+			_, err := s.cache.Resolve(ctx, tc.host)
+			s.ErrorIs(err, tc.target)
+			s.Equal(int32(2), s.next.calls.Load(), "expired after the SOA TTL")
+		})
+	}
+}
+
+func (s *CacheTestSuite) TestDeduplicatesInFlightLookups() {
+	gate := make(chan struct{})
+	s.next.gate = gate
+
+	const callers = 8
+	var wg sync.WaitGroup
+	results := make([]Resolution, callers)
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = s.cache.Resolve(context.Background(), "example.com")
+		}()
+	}
+	s.Eventually(func() bool { return s.next.calls.Load() == 1 }, time.Second, time.Millisecond)
+	close(gate)
+	wg.Wait()
+
+	s.Equal(int32(1), s.next.calls.Load())
+	for _, res := range results {
+		s.Equal("192.0.2.1", res.Addrs[0].IP.String())
+	}
+	results[0].Addrs[0].IP = net.ParseIP("198.51.100.1")
+	s.Equal("192.0.2.1", results[1].Addrs[0].IP.String(), "callers get their own copy")
+}
+
+func (s *CacheTestSuite) TestAbandonedLookupStillCompletes() {
+	gate := make(chan struct{})
+	s.next.gate = gate
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := s.cache.Resolve(ctx, "example.com")
+		done <- err
+	}()
+	s.Eventually(func() bool { return s.next.calls.Load() == 1 }, time.Second, time.Millisecond)
+	cancel()
+	s.ErrorIs(<-done, context.Canceled)
+
+	close(gate)
+	s.Eventually(func() bool { return s.cache.Len() == 1 }, time.Second, time.Millisecond)
+}
+
+func (s *CacheTestSuite) TestKeyedByStrategy() {
+	ctx := context.Background()
+	_, err := s.cache.Resolve(ctx, "example.com")
+	s.Require().NoError(err)
+	_, err = s.cache.Resolve(ContextWithStrategy(ctx, StrategyUnion), "example.com")
+	s.Require().NoError(err)
+	s.Equal(int32(2), s.next.calls.Load())
+}
+
+func (s *CacheTestSuite) TestFlush() {
+	ctx := context.Background()
+	for _, host := range []string{"a.example", "b.example"} {
+		_, err := s.cache.Resolve(ctx, host)
+		s.Require().NoError(err)
+	}
+	s.Equal(2, s.cache.Flush())
+	s.Zero(s.cache.Len())
+
+	_, err := s.cache.Resolve(ctx, "a.example")
+	s.Require().NoError(err)
+	s.Equal(int32(3), s.next.calls.Load())
+}
+
+func (s *CacheTestSuite) TestEvictsSoonestToExpire() {
+	ttls := map[string]time.Duration{"short.example": time.Minute, "long.example": time.Hour, "new.example": time.Hour}
+	s.next.answer = func(host string) (Resolution, error) {
+		return Resolution{Addrs: []net.IPAddr{{IP: net.ParseIP("192.0.2.1")}}, TTL: ttls[host]}, nil
+	}
+	s.cache = NewCache(s.next, WithCacheSize(2), WithMaxCacheTTL(24*time.Hour))
+	s.cache.now = func() time.Time { return s.now }
+
+	ctx := context.Background()
+	for _, host := range []string{"short.example", "long.example", "new.example"} {
+		_, err := s.cache.Resolve(ctx, host)
+		s.Require().NoError(err)
+	}
+	s.Equal(2, s.cache.Len())
+
+	s.next.calls.Store(0)
+	_, err := s.cache.Resolve(ctx, "long.example")
+	s.Require().NoError(err)
+	s.Equal(int32(0), s.next.calls.Load())
+}
+
+func (s *CacheTestSuite) TestNegativeAnswersFromClient() {
+	client := new(mockClient)
+	r := New(time.Second, WithResolvers([]string{"8.8.8.8:53"}))
+	r.Client = client
+	r.Retries = 2
+
+	soa := &dns.SOA{Hdr: dns.RR_Header{Name: "example.", Rrtype: dns.TypeSOA, Ttl: 600}, Minttl: 60}
+	nx := &dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeNameError}, Ns: []dns.RR{soa}}
+	client.On("ExchangeContext", mock.Anything, mock.Anything, "8.8.8.8:53").
+		Return(nx, time.Duration(0), nil)
+
+	_, err := NewCache(r).Resolve(context.Background(), "gone.example")
+	s.Require().ErrorIs(err, ErrNXDomain)
+	var neg *NegativeError
+	s.Require().True(errors.As(err, &neg))
+	s.Equal(time.Minute, neg.TTL, "the lower of the SOA TTL and MINIMUM")
+	client.AssertNumberOfCalls(s.T(), "ExchangeContext", 2) // A and AAAA, no retries
+}
+
+func TestCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(CacheTestSuite))
+}
@@ -8,8 +8,12 @@
 // # Features
 //
 //   - Concurrent A and AAAA record resolution
+//   - CNAME chain following, including incomplete chains (see Resolve)
 //   - Configurable timeout and retry mechanisms
-//   - Support for multiple DNS resolvers with random selection
+//   - Multiple DNS resolvers ranked by health, with failover and optional racing
+//   - Union resolution across every resolver, with per-address provenance
+//   - DNS-over-HTTPS (RFC 8484) and DNS-over-TLS (RFC 7858) upstreams
+//   - The host's own resolv.conf and macOS /etc/resolver files as an upstream
 //   - Proper error aggregation and handling
 //   - Thread-safe operations
 //
@@ -37,6 +41,95 @@
 //		dnsresolver.WithTimeout(3 * time.Second),
 //	)
 //
+// # Encrypted Upstreams
+//
+// Resolver addresses that are https:// URLs are queried with DNS-over-HTTPS
+// (see DoH), tls:// addresses with DNS-over-TLS (see DoT), and plain
+// "host:port" addresses with UDP/TCP DNS. All kinds may be mixed:
+//
+//	resolver := dnsresolver.New(
+//		5 * time.Second,
+//		dnsresolver.WithResolvers([]string{
+//			"https://cloudflare-dns.com/dns-query",
+//			"tls://1.1.1.1#cloudflare-dns.com",
+//			"8.8.8.8:53",
+//		}),
+//	)
+//
+// A DoT address may name the certificate to expect after a "#". Use
+// WithRootCAs to trust a private CA instead of the system roots.
+//
+// # System Configuration
+//
+// The resolver address System uses the host's configuration instead of a
+// fixed server, so the addresses found are the ones applications see even
+// behind split-horizon DNS or a VPN:
+//
+//	resolver := dnsresolver.New(
+//		5 * time.Second,
+//		dnsresolver.WithResolvers([]string{dnsresolver.System}),
+//	)
+//
+// Nameservers, the search list and the ndots and rotate options come from
+// /etc/resolv.conf; names under a domain with a file in /etc/resolver go to
+// that file's nameservers instead (see SystemSource). The files are read
+// again when they change.
+//
+// # Split-Horizon Routing
+//
+// WithRoutes sends names under a domain suffix to their own upstreams. The
+// longest matching suffix wins, and names that match no route use the
+// default resolvers. CNAME targets are routed by their own name:
+//
+//	resolver := dnsresolver.New(5*time.Second, dnsresolver.WithRoutes([]dnsresolver.Route{
+//		{Suffix: "corp.example", Upstreams: []string{"udp://10.8.0.1"}},
+//	}))
+//
+// UpstreamsFor reports which route and upstreams a name would use.
+//
+// # HTTPS Records
+//
+// Browsers also look up a name's HTTPS record (RFC 9460) and may connect to
+// the addresses in its ipv4hint and ipv6hint parameters, or to another
+// target name, over HTTP/3. WithServiceHints queries HTTPS records next to
+// A and AAAA and adds those addresses to the result; the target names are
+// reported in Resolution.Targets. A name without an HTTPS record resolves
+// as before. Since many upstreams are slow to answer or drop HTTPS
+// questions, a lookup waits for them only briefly once A and AAAA have
+// answered, and their failures do not count against an upstream's health.
+//
+// # Caching
+//
+// A Cache in front of a Clienter keeps each resolution until its TTL runs
+// out, and NXDOMAIN and NODATA answers (see NegativeError) for the TTL of
+// their SOA record, as RFC 2308 describes. Concurrent lookups of the same
+// name share one query. Lookups under ContextWithRefresh always query,
+// and replace the cached answer. Flush empties the cache:
+//
+//	cache := dnsresolver.NewCache(dnsresolver.New(5 * time.Second))
+//	res, err := cache.Resolve(ctx, "example.com") // res.TTL is what is left
+//	res, err = cache.Resolve(dnsresolver.ContextWithRefresh(ctx), "example.com")
+//
+// # Upstream Health
+//
+// A Pool records the latency and error rate of every resolver. Each query
+// goes to the fastest healthy one; a retry moves on to the next. SERVFAIL
+// and REFUSED answers count as failures. A resolver that fails three times
+// in a row is left alone for a while, starting at 5s and doubling up to
+// 5m, then gets a single probe query. WithRace sends each query to the two
+// best resolvers at once. Client.Health reports what the Pool has seen.
+//
+// # Union Resolution
+//
+// CDN-backed names resolve to different addresses depending on who asks.
+// StrategyUnion queries every resolver and returns all the addresses they
+// gave, with Resolution.Sources naming the resolvers behind each one. It
+// can be the default (WithStrategy) or chosen per lookup:
+//
+//	ctx = dnsresolver.ContextWithStrategy(ctx, dnsresolver.StrategyUnion)
+//	res, err := resolver.Resolve(ctx, "cdn.example.com")
+//	// res.Sources["192.0.2.1"] == []string{"1.1.1.1:53", "8.8.8.8:53"}
+//
 // # Concurrent Resolution
 //
 // The resolver performs A and AAAA lookups concurrently:
@@ -45,12 +138,28 @@
 //   - Returns all successful results, even if some queries fail
 //   - Aggregates errors when all queries fail
 //
+// # CNAME Chains
+//
+// Resolve reports the alias chain alongside the addresses:
+//
+//	res, err := resolver.Resolve(ctx, "www.example.com")
+//	// res.CNAMEs == []string{"example.edgekey.net", "e1.akamaiedge.net"}
+//
+// When an upstream answers with aliases but no address records for the
+// final target, that target is queried directly. At most MaxCNAMEDepth
+// aliases are followed before ErrCNAMEDepth is returned.
+//
 // # Error Handling
 //
 // The package defines several error types:
 //   - ErrNoRecords: No DNS records found for the hostname
 //   - ErrEmptyMsg: Empty DNS response received
 //   - ErrEmptyHostname: Empty hostname provided
+//   - ErrCNAMEDepth: CNAME chain longer than MaxCNAMEDepth
+//   - ErrNXDomain: The name does not exist
+//
+// NXDOMAIN and NODATA answers are final and not retried; they come back as
+// a *NegativeError wrapping ErrNXDomain or ErrNoRecords.
 //
 // Multiple errors are aggregated using go.uber.org/multierr when appropriate.
 //
@@ -84,7 +193,7 @@
 //   - Uses github.com/miekg/dns for low-level DNS operations
 //   - Implements connection pooling and reuse
 //   - Supports both IPv4 and IPv6 resolution
-//   - Random resolver selection for load distribution
+//   - Health-based resolver selection with back-off for failing upstreams
 //   - Efficient handling of IP-address inputs
 //
 // Example configuration and usage:
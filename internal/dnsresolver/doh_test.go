@@ -0,0 +1,132 @@
+package dnsresolver
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/suite"
+)
+
+type DoHTestSuite struct {
+	suite.Suite
+	srv    *httptest.Server
+	mu     sync.Mutex
+	proto  []int    // HTTP major version of every request seen
+	method []string // method of every request seen
+	status int      // status to answer with; 0 means 200
+}
+
+func (s *DoHTestSuite) SetupTest() {
+	s.proto, s.method, s.status = nil, nil, 0
+	s.srv = httptest.NewUnstartedServer(http.HandlerFunc(s.serve))
+	s.srv.EnableHTTP2 = true
+	s.srv.StartTLS()
+}
+
+func (s *DoHTestSuite) TearDownTest() {
+	s.srv.Close()
+}
+
+// serve answers every A query with 192.0.2.1 and every AAAA query with
+// 2001:db8::1, both with a TTL of 60 seconds.
+func (s *DoHTestSuite) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.proto = append(s.proto, r.ProtoMajor)
+	s.method = append(s.method, r.Method)
+	s.mu.Unlock()
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+
+	var wire []byte
+	switch r.Method {
+	case http.MethodGet:
+		var err error
+		if wire, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodPost:
+		if r.Header.Get("Content-Type") != _dohMediaType {
+			http.Error(w, "bad content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		wire, _ = io.ReadAll(r.Body)
+	}
+	q := new(dns.Msg)
+	if err := q.Unpack(wire); err != nil || len(q.Question) != 1 || q.Id != 0 {
+		http.Error(w, "bad query", http.StatusBadRequest)
+		return
+	}
+
+	reply := new(dns.Msg)
+	reply.SetReply(q)
+	hdr := dns.RR_Header{Name: q.Question[0].Name, Rrtype: q.Question[0].Qtype, Class: dns.ClassINET, Ttl: 60}
+	switch q.Question[0].Qtype {
+	case dns.TypeA:
+		reply.Answer = append(reply.Answer, &dns.A{Hdr: hdr, A: net.ParseIP("192.0.2.1")})
+	case dns.TypeAAAA:
+		reply.Answer = append(reply.Answer, &dns.AAAA{Hdr: hdr, AAAA: net.ParseIP("2001:db8::1")})
+	}
+	out, _ := reply.Pack()
+	w.Header().Set("Content-Type", _dohMediaType)
+	_, _ = w.Write(out)
+}
+
+func (s *DoHTestSuite) doh(useGET bool) *DoH {
+	return &DoH{HTTPClient: s.srv.Client(), UseGET: useGET}
+}
+
+func (s *DoHTestSuite) TestExchange() {
+	for _, useGET := range []bool{false, true} {
+		m := new(dns.Msg)
+		m.SetQuestion("example.com.", dns.TypeA)
+		m.Id = 4242
+
+		reply, _, err := s.doh(useGET).ExchangeContext(context.Background(), m, s.srv.URL+"/dns-query")
+		s.Require().NoError(err)
+		s.Equal(uint16(4242), reply.Id, "caller's ID is restored")
+		s.Require().Len(reply.Answer, 1)
+		s.Equal("192.0.2.1", reply.Answer[0].(*dns.A).A.String())
+		s.Equal(uint16(4242), m.Id, "query is left untouched")
+	}
+	s.Equal([]string{http.MethodPost, http.MethodGet}, s.method)
+	s.Equal([]int{2, 2}, s.proto, "DoH runs over HTTP/2")
+}
+
+func (s *DoHTestSuite) TestBadStatus() {
+	s.status = http.StatusBadGateway
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+
+	_, _, err := s.doh(false).ExchangeContext(context.Background(), m, s.srv.URL)
+	s.ErrorIs(err, ErrDoHStatus)
+}
+
+func (s *DoHTestSuite) TestResolveThroughTransport() {
+	r := New(5*time.Second, WithResolvers([]string{s.srv.URL + "/dns-query"}))
+	r.Client = &Transport{Plain: &dns.Client{}, HTTPS: s.doh(false)}
+
+	res, err := r.Resolve(context.Background(), "example.com")
+	s.Require().NoError(err)
+	ips := make([]string, 0, len(res.Addrs))
+	for _, a := range res.Addrs {
+		ips = append(ips, a.IP.String())
+	}
+	s.ElementsMatch([]string{"192.0.2.1", "2001:db8::1"}, ips)
+	s.Equal(time.Minute, res.TTL)
+	s.Len(s.method, 2, "A and AAAA both went over DoH")
+}
+
+func TestDoHSuite(t *testing.T) {
+	suite.Run(t, new(DoHTestSuite))
+}
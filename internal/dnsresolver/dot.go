@@ -0,0 +1,294 @@
+package dnsresolver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// _dotPort is the IANA port for DNS over TLS (RFC 7858).
+	_dotPort = "853"
+	// _dotIdleTimeout closes a pooled connection nobody has used for this long.
+	_dotIdleTimeout = 30 * time.Second
+)
+
+// ErrDoTClosed is returned for queries still waiting when their DoT
+// connection is closed.
+var ErrDoTClosed = errors.New("dot: connection closed")
+
+var _ Exchanger = (*DoT)(nil)
+
+// DoT is an Exchanger that sends queries over DNS-over-TLS (RFC 7858).
+// The address passed to ExchangeContext has the form
+// "tls://host[:port][#server-name]": the port defaults to 853 and the
+// certificate is verified against server-name, or host if none is given,
+// as in "tls://1.1.1.1#cloudflare-dns.com".
+//
+// One connection per address is kept open and shared by concurrent
+// queries, so the A and AAAA lookups for a name cost a single handshake.
+type DoT struct {
+	// RootCAs verifies server certificates; nil means the system roots.
+	RootCAs *x509.CertPool
+	// Timeout bounds dialing and the TLS handshake.
+	Timeout time.Duration
+
+	mu    sync.Mutex
+	conns map[string]*dotConn // keyed by address
+	dials singleflight.Group  // one dial per address at a time
+}
+
+// NewDoT returns a DoT exchanger that dials with the given timeout and
+// verifies servers against the system roots.
+func NewDoT(timeout time.Duration) *DoT {
+	return &DoT{Timeout: timeout}
+}
+
+// ExchangeContext sends m to the DoT resolver at addr and returns its reply.
+func (d *DoT) ExchangeContext(ctx context.Context, m *dns.Msg, addr string) (*dns.Msg, time.Duration, error) {
+	for attempt := 0; ; attempt++ {
+		c, reused, err := d.conn(ctx, addr)
+		if err != nil {
+			return nil, 0, err
+		}
+		reply, rtt, err := c.exchange(ctx, m)
+		// The server may have closed an idle connection just as we used it.
+		if err != nil && reused && attempt == 0 && ctx.Err() == nil {
+			d.drop(addr, c)
+			continue
+		}
+		return reply, rtt, err
+	}
+}
+
+// Close closes every pooled connection.
+func (d *DoT) Close() error {
+	d.mu.Lock()
+	conns := d.conns
+	d.conns = nil
+	d.mu.Unlock()
+	for _, c := range conns {
+		c.close(ErrDoTClosed)
+	}
+	return nil
+}
+
+// conn returns the pooled connection for addr, dialing one if needed.
+// reused reports whether the connection was already open. Concurrent
+// queries for addr share one dial, which does not hold up queries for
+// other addresses.
+func (d *DoT) conn(ctx context.Context, addr string) (c *dotConn, reused bool, err error) {
+	if pc, ok := d.pooled(addr); ok {
+		return pc, true, nil
+	}
+	ch := d.dials.DoChan(addr, func() (any, error) {
+		// A query that finished while this one waited may have dialed already.
+		if pc, ok := d.pooled(addr); ok {
+			return pc, nil
+		}
+		// The dial is shared, so it must not end with the first caller;
+		// Timeout bounds it instead.
+		dialCtx := ctx
+		if d.Timeout > 0 {
+			dialCtx = context.WithoutCancel(ctx)
+		}
+		nc, err := d.dial(dialCtx, addr)
+		if err != nil {
+			return nil, err
+		}
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if d.conns == nil {
+			d.conns = make(map[string]*dotConn)
+		}
+		d.conns[addr] = nc
+		return nc, nil
+	})
+	select {
+	case r := <-ch:
+		if r.Err != nil {
+			return nil, false, r.Err
+		}
+		return r.Val.(*dotConn), false, nil
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+// pooled returns the open pooled connection for addr, if any.
+func (d *DoT) pooled(addr string) (*dotConn, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c, ok := d.conns[addr]
+	return c, ok && c.alive()
+}
+
+// dial opens a new connection to addr and completes the TLS handshake.
+func (d *DoT) dial(ctx context.Context, addr string) (*dotConn, error) {
+	hostport, serverName, err := parseDoTAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: d.Timeout},
+		Config: &tls.Config{
+			ServerName: serverName,
+			RootCAs:    d.RootCAs,
+			MinVersion: tls.VersionTLS12,
+		},
+	}
+	raw, err := dialer.DialContext(ctx, "tcp", hostport)
+	if err != nil {
+		return nil, fmt.Errorf("dot: %w", err)
+	}
+	return newDoTConn(raw), nil
+}
+
+// drop closes c and forgets it if it is still the pooled connection for addr.
+func (d *DoT) drop(addr string, c *dotConn) {
+	c.close(ErrDoTClosed)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.conns[addr] == c {
+		delete(d.conns, addr)
+	}
+}
+
+// parseDoTAddr splits "tls://host[:port][#server-name]" into a dialable
+// host:port and the name to verify the certificate against.
+func parseDoTAddr(addr string) (hostport, serverName string, err error) {
+	rest, ok := strings.CutPrefix(addr, "tls://")
+	if !ok {
+		return "", "", fmt.Errorf("dot: address %q is not tls://", addr)
+	}
+	rest, serverName, _ = strings.Cut(rest, "#")
+	host, port, err := net.SplitHostPort(rest)
+	if err != nil {
+		host, port = strings.Trim(rest, "[]"), _dotPort
+	}
+	if host == "" {
+		return "", "", fmt.Errorf("dot: address %q has no host", addr)
+	}
+	if serverName == "" {
+		serverName = host
+	}
+	return net.JoinHostPort(host, port), serverName, nil
+}
+
+// dotConn is one TLS connection carrying pipelined queries. Replies are
+// matched to queries by message ID, which the connection assigns itself.
+type dotConn struct {
+	conn *dns.Conn
+	wmu  sync.Mutex // serializes writes
+
+	mu      sync.Mutex
+	nextID  uint16
+	pending map[uint16]chan *dns.Msg
+	err     error         // why the connection closed
+	done    chan struct{} // closed with the connection
+}
+
+func newDoTConn(raw net.Conn) *dotConn {
+	c := &dotConn{
+		conn:    &dns.Conn{Conn: raw},
+		pending: make(map[uint16]chan *dns.Msg),
+		done:    make(chan struct{}),
+	}
+	_ = raw.SetReadDeadline(time.Now().Add(_dotIdleTimeout))
+	go c.readLoop()
+	return c
+}
+
+func (c *dotConn) alive() bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// exchange sends m and waits for the matching reply.
+func (c *dotConn) exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, time.Duration, error) {
+	ch := make(chan *dns.Msg, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, 0, c.err
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	q := m.Copy()
+	q.Id = id
+	start := time.Now()
+	c.wmu.Lock()
+	if dl, ok := ctx.Deadline(); ok {
+		_ = c.conn.SetWriteDeadline(dl)
+	}
+	// Keep the connection open while it is in use.
+	_ = c.conn.SetReadDeadline(time.Now().Add(_dotIdleTimeout))
+	err := c.conn.WriteMsg(q)
+	c.wmu.Unlock()
+	if err != nil {
+		c.close(err)
+		return nil, 0, fmt.Errorf("dot: %w", err)
+	}
+
+	select {
+	case reply := <-ch:
+		reply.Id = m.Id
+		return reply, time.Since(start), nil
+	case <-c.done:
+		return nil, 0, fmt.Errorf("dot: %w", c.err)
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+}
+
+// readLoop delivers replies to waiting queries until the connection fails
+// or sits idle for _dotIdleTimeout.
+func (c *dotConn) readLoop() {
+	for {
+		reply, err := c.conn.ReadMsg()
+		if err != nil {
+			c.close(err)
+			return
+		}
+		c.mu.Lock()
+		ch, ok := c.pending[reply.Id]
+		delete(c.pending, reply.Id)
+		c.mu.Unlock()
+		if ok {
+			ch <- reply
+		}
+	}
+}
+
+// close shuts the connection down; waiting queries fail with err.
+func (c *dotConn) close(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.done)
+	_ = c.conn.Close()
+}
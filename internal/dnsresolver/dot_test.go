@@ -0,0 +1,182 @@
+package dnsresolver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/suite"
+)
+
+// countingListener counts accepted connections.
+type countingListener struct {
+	net.Listener
+	accepted atomic.Int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err == nil {
+		l.accepted.Add(1)
+	}
+	return c, err
+}
+
+type DoTTestSuite struct {
+	suite.Suite
+	ln    *countingListener
+	srv   *dns.Server
+	roots *x509.CertPool
+	addr  string
+}
+
+func (s *DoTTestSuite) SetupTest() {
+	// Borrow httptest's self-signed certificate, valid for 127.0.0.1 and
+	// example.com.
+	hts := httptest.NewUnstartedServer(nil)
+	hts.StartTLS()
+	cert := hts.TLS.Certificates[0]
+	s.roots = x509.NewCertPool()
+	s.roots.AddCert(hts.Certificate())
+	hts.Close()
+
+	tl, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	s.Require().NoError(err)
+	s.ln = &countingListener{Listener: tl}
+	s.addr = "tls://" + tl.Addr().String()
+
+	srv := &dns.Server{Listener: s.ln, Net: "tcp-tls", Handler: dns.HandlerFunc(answer)}
+	s.srv = srv
+	go func() { _ = srv.ActivateAndServe() }()
+}
+
+func (s *DoTTestSuite) TearDownTest() {
+	_ = s.srv.Shutdown()
+}
+
+// answer replies to A queries with 192.0.2.1 and AAAA with 2001:db8::1.
+func answer(w dns.ResponseWriter, q *dns.Msg) {
+	reply := new(dns.Msg)
+	reply.SetReply(q)
+	hdr := dns.RR_Header{Name: q.Question[0].Name, Rrtype: q.Question[0].Qtype, Class: dns.ClassINET, Ttl: 60}
+	switch q.Question[0].Qtype {
+	case dns.TypeA:
+		reply.Answer = append(reply.Answer, &dns.A{Hdr: hdr, A: net.ParseIP("192.0.2.1")})
+	case dns.TypeAAAA:
+		reply.Answer = append(reply.Answer, &dns.AAAA{Hdr: hdr, AAAA: net.ParseIP("2001:db8::1")})
+	}
+	_ = w.WriteMsg(reply)
+}
+
+func (s *DoTTestSuite) TestReusesConnection() {
+	dot := &DoT{RootCAs: s.roots, Timeout: time.Second}
+	defer dot.Close()
+	r := New(5*time.Second, WithResolvers([]string{s.addr}))
+	r.Client = dot
+
+	for range 2 {
+		res, err := r.Resolve(context.Background(), "example.com")
+		s.Require().NoError(err)
+		s.Len(res.Addrs, 2)
+	}
+	s.Equal(int32(1), s.ln.accepted.Load(), "A and AAAA lookups share one connection")
+}
+
+func (s *DoTTestSuite) TestRedialsClosedConnection() {
+	dot := &DoT{RootCAs: s.roots, Timeout: time.Second}
+	defer dot.Close()
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+
+	_, _, err := dot.ExchangeContext(context.Background(), m, s.addr)
+	s.Require().NoError(err)
+	for _, c := range dot.conns {
+		c.close(ErrDoTClosed) // as if the server hung up
+	}
+	reply, _, err := dot.ExchangeContext(context.Background(), m, s.addr)
+	s.Require().NoError(err)
+	s.Equal(m.Id, reply.Id)
+	s.Equal(int32(2), s.ln.accepted.Load())
+}
+
+func (s *DoTTestSuite) TestSlowDialDoesNotBlockOthers() {
+	// Accepts connections but never completes a handshake.
+	hole, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer hole.Close()
+	go func() {
+		for {
+			c, err := hole.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+
+	dot := &DoT{RootCAs: s.roots, Timeout: 2 * time.Second}
+	defer dot.Close()
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	_, _, err = dot.ExchangeContext(context.Background(), m, s.addr)
+	s.Require().NoError(err)
+
+	stuck := make(chan error, 1)
+	go func() {
+		_, _, err := dot.ExchangeContext(context.Background(), m, "tls://"+hole.Addr().String())
+		stuck <- err
+	}()
+	time.Sleep(50 * time.Millisecond) // let the dial start
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, _, err = dot.ExchangeContext(ctx, m, s.addr)
+	s.NoError(err, "the pooled connection is usable while another address dials")
+	s.Error(<-stuck, "the handshake times out")
+}
+
+func (s *DoTTestSuite) TestVerifiesServer() {
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	ctx := context.Background()
+
+	untrusted := &DoT{Timeout: time.Second}
+	_, _, err := untrusted.ExchangeContext(ctx, m, s.addr)
+	s.Error(err, "self-signed certificate is not in the system roots")
+
+	dot := &DoT{RootCAs: s.roots, Timeout: time.Second}
+	defer dot.Close()
+	_, _, err = dot.ExchangeContext(ctx, m, s.addr+"#dns.invalid")
+	s.Error(err, "certificate is not valid for dns.invalid")
+	_, _, err = dot.ExchangeContext(ctx, m, s.addr+"#example.com")
+	s.NoError(err)
+}
+
+func (s *DoTTestSuite) TestParseAddr() {
+	tests := []struct {
+		in, hostport, name string
+	}{
+		{"tls://1.1.1.1", "1.1.1.1:853", "1.1.1.1"},
+		{"tls://1.1.1.1:8853#cloudflare-dns.com", "1.1.1.1:8853", "cloudflare-dns.com"},
+		{"tls://dns.google", "dns.google:853", "dns.google"},
+		{"tls://[2606:4700:4700::1111]", "[2606:4700:4700::1111]:853", "2606:4700:4700::1111"},
+	}
+	for _, tt := range tests {
+		hostport, name, err := parseDoTAddr(tt.in)
+		s.Require().NoError(err, tt.in)
+		s.Equal(tt.hostport, hostport, tt.in)
+		s.Equal(tt.name, name, tt.in)
+	}
+	_, _, err := parseDoTAddr("1.1.1.1:853")
+	s.Error(err)
+}
+
+func TestDoTSuite(t *testing.T) {
+	suite.Run(t, new(DoTTestSuite))
+}
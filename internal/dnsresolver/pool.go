@@ -0,0 +1,178 @@
+package dnsresolver
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// _healthWeight is how much the latest query moves an upstream's
+	// average latency and error rate.
+	_healthWeight = 0.3
+	// _downAfter consecutive failures take an upstream out of rotation.
+	_downAfter = 3
+	// _minBackoff is how long an upstream stays down after _downAfter
+	// failures; each further failure doubles it, up to _maxBackoff.
+	_minBackoff = 5 * time.Second
+	_maxBackoff = 5 * time.Minute
+	// _failurePenalty is the latency an error rate of 1 is worth when
+	// ranking upstreams.
+	_failurePenalty = time.Second
+)
+
+// UpstreamHealth is the health of one upstream resolver as seen by a Pool.
+type UpstreamHealth struct {
+	Address   string        `json:"address"`
+	Latency   time.Duration `json:"latency"`    // moving average of successful queries
+	ErrorRate float64       `json:"error_rate"` // moving average, 0 to 1
+	Queries   uint64        `json:"queries"`
+	Failures  uint64        `json:"failures"`
+	DownUntil time.Time     `json:"down_until,omitempty"` // zero unless backed off
+	LastError string        `json:"last_error,omitempty"`
+}
+
+// Pool tracks the latency and error rate of upstream resolvers and ranks
+// them so that queries go to the fastest healthy one. An upstream that
+// fails _downAfter times in a row is backed off with exponentially growing
+// delays; when a delay runs out it gets one query to prove itself again.
+// A nil *Pool ranks nothing and records nothing.
+type Pool struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	upstreams map[string]*upstream
+}
+
+// upstream is what a Pool knows about one resolver.
+type upstream struct {
+	latency   time.Duration
+	errRate   float64
+	queries   uint64
+	failures  uint64
+	streak    int // consecutive failures
+	downUntil time.Time
+	lastErr   string
+}
+
+// NewPool returns an empty Pool.
+func NewPool() *Pool {
+	return &Pool{now: time.Now, upstreams: make(map[string]*upstream)}
+}
+
+// Rank returns addrs ordered best first: upstreams that are not backed off
+// before those that are, then by latency plus a penalty for errors. Upstreams
+// never queried, or whose back-off just ran out, come first so they are
+// measured.
+func (p *Pool) Rank(addrs []string) []string {
+	out := slices.Clone(addrs)
+	if p == nil {
+		return out
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	slices.SortStableFunc(out, func(a, b string) int {
+		ua, ub := p.upstreams[a], p.upstreams[b]
+		if da, db := ua.down(now), ub.down(now); da != db {
+			if da {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Compare(ua.score(), ub.score())
+	})
+	return out
+}
+
+// Report records the outcome of one query to addr that took rtt.
+func (p *Pool) Report(addr string, rtt time.Duration, err error) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	u, ok := p.upstreams[addr]
+	if !ok {
+		u = &upstream{}
+		p.upstreams[addr] = u
+	}
+	u.queries++
+	if err != nil {
+		u.failures++
+		u.streak++
+		u.lastErr = err.Error()
+		u.errRate = average(u.errRate, 1)
+		if u.streak >= _downAfter {
+			u.downUntil = p.now().Add(backoff(u.streak))
+		}
+		return
+	}
+	u.streak = 0
+	u.downUntil = time.Time{}
+	u.errRate = average(u.errRate, 0)
+	if u.latency == 0 {
+		u.latency = rtt
+	} else {
+		u.latency = time.Duration(average(float64(u.latency), float64(rtt)))
+	}
+}
+
+// Health returns the health of every upstream queried so far, by address.
+func (p *Pool) Health() []UpstreamHealth {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	out := make([]UpstreamHealth, 0, len(p.upstreams))
+	for addr, u := range p.upstreams {
+		h := UpstreamHealth{
+			Address:   addr,
+			Latency:   u.latency,
+			ErrorRate: u.errRate,
+			Queries:   u.queries,
+			Failures:  u.failures,
+			LastError: u.lastErr,
+		}
+		if u.down(now) {
+			h.DownUntil = u.downUntil
+		}
+		out = append(out, h)
+	}
+	slices.SortFunc(out, func(a, b UpstreamHealth) int { return cmp.Compare(a.Address, b.Address) })
+	return out
+}
+
+// down reports whether u is backed off at now. Unknown upstreams are not.
+func (u *upstream) down(now time.Time) bool {
+	return u != nil && now.Before(u.downUntil)
+}
+
+// score is u's latency plus a penalty for its error rate; lower is better.
+// An upstream that is unknown or due a probe after backing off scores 0.
+func (u *upstream) score() float64 {
+	if u == nil || u.streak >= _downAfter {
+		return 0
+	}
+	return float64(u.latency) + u.errRate*float64(_failurePenalty)
+}
+
+// backoff returns how long an upstream that failed streak times in a row
+// stays down.
+func backoff(streak int) time.Duration {
+	d := _minBackoff
+	for i := _downAfter; i < streak && d < _maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, _maxBackoff)
+}
+
+// average folds sample into the moving average avg.
+func average(avg, sample float64) float64 {
+	return avg + _healthWeight*(sample-avg)
+}
@@ -0,0 +1,145 @@
+package dnsresolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+var errTimeout = errors.New("i/o timeout")
+
+type PoolTestSuite struct {
+	suite.Suite
+	pool *Pool
+	now  time.Time
+}
+
+func (s *PoolTestSuite) SetupTest() {
+	s.now = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.pool = NewPool()
+	s.pool.now = func() time.Time { return s.now }
+}
+
+func (s *PoolTestSuite) TestPrefersFastHealthyUpstreams() {
+	s.pool.Report("slow:53", 80*time.Millisecond, nil)
+	s.pool.Report("fast:53", 10*time.Millisecond, nil)
+	s.pool.Report("flaky:53", 5*time.Millisecond, nil)
+	s.pool.Report("flaky:53", 0, errTimeout)
+	s.pool.Report("flaky:53", 0, errTimeout)
+
+	s.Equal([]string{"new:53", "fast:53", "slow:53", "flaky:53"},
+		s.pool.Rank([]string{"slow:53", "flaky:53", "fast:53", "new:53"}),
+		"unknown upstreams are tried first so they get measured")
+}
+
+func (s *PoolTestSuite) TestBacksOffDeadUpstreams() {
+	s.pool.Report("ok:53", 50*time.Millisecond, nil)
+	for range _downAfter {
+		s.pool.Report("dead:53", 0, errTimeout)
+	}
+	s.Equal([]string{"ok:53", "dead:53"}, s.pool.Rank([]string{"dead:53", "ok:53"}))
+	s.Equal(s.now.Add(_minBackoff), s.health("dead:53").DownUntil)
+
+	// Once the back-off runs out the upstream is probed first.
+	s.now = s.now.Add(_minBackoff)
+	s.Equal([]string{"dead:53", "ok:53"}, s.pool.Rank([]string{"ok:53", "dead:53"}))
+
+	// Another failure doubles the back-off.
+	s.pool.Report("dead:53", 0, errTimeout)
+	s.Equal(s.now.Add(2*_minBackoff), s.health("dead:53").DownUntil)
+
+	// A success brings it back for good.
+	s.now = s.now.Add(2 * _minBackoff)
+	s.pool.Report("dead:53", 20*time.Millisecond, nil)
+	h := s.health("dead:53")
+	s.Zero(h.DownUntil)
+	s.Equal(uint64(_downAfter+2), h.Queries)
+	s.Equal(uint64(_downAfter+1), h.Failures)
+	s.Equal(errTimeout.Error(), h.LastError)
+}
+
+func (s *PoolTestSuite) TestBackoffIsCapped() {
+	s.Equal(_minBackoff, backoff(_downAfter))
+	s.Equal(4*_minBackoff, backoff(_downAfter+2))
+	s.Equal(_maxBackoff, backoff(100))
+}
+
+func (s *PoolTestSuite) TestNilPool() {
+	var p *Pool
+	p.Report("a:53", time.Millisecond, nil)
+	s.Equal([]string{"b:53", "a:53"}, p.Rank([]string{"b:53", "a:53"}))
+	s.Nil(p.Health())
+}
+
+func (s *PoolTestSuite) health(addr string) UpstreamHealth {
+	for _, h := range s.pool.Health() {
+		if h.Address == addr {
+			return h
+		}
+	}
+	s.FailNow("no health for " + addr)
+	return UpstreamHealth{}
+}
+
+func answerA(name, ip string) *dns.Msg {
+	return &dns.Msg{Answer: []dns.RR{&dns.A{
+		Hdr: dns.RR_Header{Name: dns.Fqdn(name), Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+		A:   net.ParseIP(ip),
+	}}}
+}
+
+func (s *PoolTestSuite) TestClientFailsOverOnServerFailure() {
+	client := new(mockClient)
+	r := New(5*time.Second, WithResolvers([]string{"broken:53", "good:53"}))
+	r.Client = client
+	r.Retries = 1
+
+	client.On("ExchangeContext", mock.Anything, mock.Anything, "broken:53").
+		Return(&dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeServerFailure}}, time.Duration(0), nil)
+	client.On("ExchangeContext", mock.Anything, mock.Anything, "good:53").
+		Return(answerA("example.com", "192.0.2.1"), time.Duration(0), nil)
+
+	addrs, err := r.LookupHost(context.Background(), "example.com")
+	s.Require().NoError(err)
+	s.Equal("192.0.2.1", addrs[0].IP.String())
+
+	for _, h := range r.Pool.Health() {
+		if h.Address == "broken:53" {
+			s.NotZero(h.Failures)
+			s.Contains(h.LastError, "SERVFAIL")
+		}
+	}
+	ranked, err := r.serversFor("example.com")
+	s.Require().NoError(err)
+	s.Equal([]string{"good:53", "broken:53"}, ranked)
+}
+
+func (s *PoolTestSuite) TestClientRaces() {
+	client := new(mockClient)
+	r := New(5*time.Second, WithResolvers([]string{"stuck:53", "quick:53"}), WithRace())
+	r.Client = client
+
+	client.On("ExchangeContext", mock.Anything, mock.Anything, "stuck:53").
+		Run(func(args mock.Arguments) { <-args.Get(0).(context.Context).Done() }).
+		Return(nil, time.Duration(0), context.Canceled)
+	client.On("ExchangeContext", mock.Anything, mock.Anything, "quick:53").
+		Return(answerA("example.com", "192.0.2.1"), time.Duration(0), nil)
+
+	addrs, err := r.LookupHost(context.Background(), "example.com")
+	s.Require().NoError(err)
+	s.Equal("192.0.2.1", addrs[0].IP.String())
+
+	for _, h := range r.Pool.Health() {
+		s.Zero(h.Failures, "the loser of a race is not held against %s", h.Address)
+	}
+}
+
+func TestPoolTestSuite(t *testing.T) {
+	suite.Run(t, new(PoolTestSuite))
+}
@@ -6,9 +6,12 @@ package dnsresolver
 import (
 	"context"
 	"crypto/rand"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"math/big"
 	"net"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -25,16 +28,82 @@ var (
 	ErrEmptyMsg = fmt.Errorf("empty message")
 	// ErrEmptyHostname is returned when an empty hostname is provided.
 	ErrEmptyHostname = fmt.Errorf("empty hostname")
+	// ErrCNAMEDepth is returned when a CNAME chain exceeds MaxCNAMEDepth.
+	ErrCNAMEDepth = fmt.Errorf("cname chain too long")
+	// ErrUpstream is returned when a resolver answers SERVFAIL or REFUSED.
+	ErrUpstream = fmt.Errorf("upstream failure")
+	// ErrNXDomain is returned when a resolver answers that a name does not exist.
+	ErrNXDomain = fmt.Errorf("no such domain")
 )
 
+// NegativeError is a resolver's definite answer that Name does not exist
+// (ErrNXDomain) or has no records of the queried type (ErrNoRecords). TTL
+// is how long the answer may be cached as RFC 2308 defines it, or 0 if the
+// response carried no SOA record to tell.
+type NegativeError struct {
+	Name string
+	Err  error
+	TTL  time.Duration
+}
+
+// Error implements error.
+func (e *NegativeError) Error() string { return fmt.Sprintf("%s: %v", e.Name, e.Err) }
+
+// Unwrap returns ErrNXDomain or ErrNoRecords.
+func (e *NegativeError) Unwrap() error { return e.Err }
+
+// NotFound reports whether err is a definite answer that the names looked
+// up do not exist: a NegativeError for ErrNXDomain, or an error joining
+// only such answers, as for both A and AAAA or every name of a search
+// list. Failures to find out, and names without addresses, are not.
+func NotFound(err error) bool {
+	switch e := err.(type) {
+	case *NegativeError:
+		return errors.Is(e.Err, ErrNXDomain)
+	case interface{ Unwrap() []error }:
+		errs := e.Unwrap()
+		if len(errs) == 0 {
+			return false
+		}
+		for _, err := range errs {
+			if !NotFound(err) {
+				return false
+			}
+		}
+		return true
+	case interface{ Unwrap() error }:
+		return NotFound(e.Unwrap())
+	}
+	return false
+}
+
 var _defaultResolver = "1.1.1.1:53"
 
+// MaxCNAMEDepth is the maximum number of aliases followed for one name.
+const MaxCNAMEDepth = 8
+
 var _ Clienter = (*Client)(nil)
 
 // Clienter defines the interface for DNS resolution.
 type Clienter interface {
 	// LookupHost resolves a hostname to IPv4 & IPv6 addresses.
 	LookupHost(ctx context.Context, hostname string) ([]net.IPAddr, error)
+	// Resolve is like LookupHost but also reports how the name resolved.
+	Resolve(ctx context.Context, hostname string) (Resolution, error)
+}
+
+// Resolution is the detailed result of resolving a hostname.
+type Resolution struct {
+	Addrs  []net.IPAddr  // IPv4 & IPv6 addresses of the final name
+	CNAMEs []string      // alias chain followed from the queried name, in order
+	TTL    time.Duration // lowest TTL of the records behind Addrs; 0 if unknown
+	// Sources lists the upstreams that returned each address, keyed by
+	// IP. It is only set by StrategyUnion.
+	Sources map[string][]string
+	// Targets are the service names the HTTPS records of the name point
+	// to, whose addresses are part of Addrs. It is only set with
+	// WithServiceHints.
+	Targets []string
 }
 
 // Exchanger defines the interface for DNS message exchange.
@@ -48,6 +117,19 @@ type Client struct {
 	Timeout   time.Duration
 	Resolvers []string
 	Retries   uint
+	// System supplies the servers and search list for the System resolver.
+	System *SystemSource
+	// Pool ranks Resolvers by health; nil means they are tried in order.
+	Pool *Pool
+	// Race sends every query to the two best resolvers at once.
+	Race bool
+	// ServiceHints also looks up HTTPS records; see WithServiceHints.
+	ServiceHints bool
+	// Routes send names under a suffix to their own resolvers; see WithRoutes.
+	Routes []Route
+	// Strategy is used for lookups whose context names none; empty means
+	// StrategyFirst. See ContextWithStrategy.
+	Strategy Strategy
 
 	mu sync.Mutex
 }
@@ -59,10 +141,10 @@ type Opt func(r *Client)
 // The returned Client is ready to use for DNS lookups.
 func New(timeout time.Duration, opts ...Opt) *Client {
 	res := &Client{
-		Client: &dns.Client{
-			Timeout: timeout,
-		},
+		Client:  NewTransport(timeout),
 		Timeout: timeout,
+		System:  NewSystemSource(DefaultResolvConf, DefaultResolverDir),
+		Pool:    NewPool(),
 	}
 
 	for _, o := range opts {
@@ -72,7 +154,11 @@ func New(timeout time.Duration, opts ...Opt) *Client {
 	return res
 }
 
-// WithResolvers returns an option to set custom DNS resolvers.
+// WithResolvers returns an option to set custom DNS resolvers, each either
+// a plain "host:port" address, "udp://host[:port]" or "tcp://host[:port]",
+// a DNS-over-HTTPS URL such as "https://cloudflare-dns.com/dns-query" or a
+// DNS-over-TLS address such as "tls://1.1.1.1#cloudflare-dns.com". The address System stands for the
+// nameservers of the host's resolver configuration.
 // If not provided, the default resolver (1.1.1.1:53) will be used.
 func WithResolvers(resolvers []string) Opt {
 	return func(r *Client) {
@@ -80,6 +166,34 @@ func WithResolvers(resolvers []string) Opt {
 	}
 }
 
+// WithRootCAs returns an option to verify DNS-over-HTTPS and DNS-over-TLS
+// servers against roots instead of the system roots. It has no effect if
+// the Client's Exchanger has been replaced.
+func WithRootCAs(roots *x509.CertPool) Opt {
+	return func(r *Client) {
+		if t, ok := r.Client.(*Transport); ok {
+			t.SetRootCAs(roots)
+		}
+	}
+}
+
+// WithRetries returns an option to retry a failed query n more times,
+// each time at the next resolver.
+func WithRetries(n uint) Opt {
+	return func(r *Client) {
+		r.Retries = n
+	}
+}
+
+// WithRace returns an option to send every query to the two healthiest
+// resolvers at once and use whichever answers first, trading upstream load
+// for latency.
+func WithRace() Opt {
+	return func(r *Client) {
+		r.Race = true
+	}
+}
+
 // WithTimeout returns an option to set a custom timeout for DNS queries.
 // This overrides the timeout provided to New.
 func WithTimeout(timeout time.Duration) Opt {
@@ -88,46 +202,91 @@ func WithTimeout(timeout time.Duration) Opt {
 	}
 }
 
+// Health returns the health of every resolver queried so far.
+func (r *Client) Health() []UpstreamHealth {
+	return r.Pool.Health()
+}
+
 // LookupHost resolves a hostname to a slice of IP addresses.
 // It handles both IPv4 and IPv6 addresses and returns them as net.IPAddr.
 // If the hostname is already an IP address, it returns it directly.
 // Returns an error if the hostname is empty or if DNS resolution fails.
 func (r *Client) LookupHost(ctx context.Context, hostname string) ([]net.IPAddr, error) {
-	var (
-		addr []net.IPAddr
-		err  error
-	)
+	res, err := r.Resolve(ctx, hostname)
+	if err != nil {
+		return nil, err
+	}
+	return res.Addrs, nil
+}
 
+// Resolve resolves a hostname like LookupHost and additionally reports the
+// CNAME chain that was followed. When a resolver answers with an incomplete
+// chain (aliases but no addresses), the final target is queried directly,
+// up to MaxCNAMEDepth aliases in total.
+func (r *Client) Resolve(ctx context.Context, hostname string) (Resolution, error) {
 	// ensure we have a hostname
 	if strings.TrimSpace(hostname) == "" {
-		return nil, ErrEmptyHostname
+		return Resolution{}, ErrEmptyHostname
 	}
 
 	// if hostname is an IP, return it as is.
 	if ip := net.ParseIP(hostname); ip != nil {
-		addr = append(addr, net.IPAddr{IP: ip})
-		return addr, nil
+		return Resolution{Addrs: []net.IPAddr{{IP: ip}}}, nil
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
 	defer cancel()
 
-	addr, err = r.lookupIPs(ctx, hostname)
+	if !r.usesSystem() {
+		return r.resolveName(ctx, hostname)
+	}
+	cfg, err := r.System.Config()
 	if err != nil {
-		return nil, err
+		return Resolution{}, err
+	}
+	// Try the search list like the system resolver would, so a short name
+	// blocks what applications reach under it.
+	var errs error
+	for _, name := range cfg.Names(hostname) {
+		res, err := r.resolveName(ctx, name)
+		if err == nil {
+			return res, nil
+		}
+		errs = multierr.Append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
 	}
+	return Resolution{}, errs
+}
 
-	return addr, nil
+// resolveName resolves one fully expanded name with the strategy that
+// applies to ctx.
+func (r *Client) resolveName(ctx context.Context, host string) (Resolution, error) {
+	if r.strategyFor(ctx) == StrategyUnion {
+		return r.lookupUnion(ctx, host)
+	}
+	return r.lookupIPs(ctx, host, "")
 }
 
 // lookupIPs resolves A and AAAA records concurrently.
 // It returns every address that succeeded, or an aggregated
-// error if *both* queries fail.
-func (r *Client) lookupIPs(ctx context.Context, host string) ([]net.IPAddr, error) {
+// error if *both* queries fail. A non-empty server pins every query,
+// including those for alias targets, to that upstream. With ServiceHints
+// the HTTPS records are looked up alongside, but only waited for briefly
+// once both queries have answered.
+func (r *Client) lookupIPs(ctx context.Context, host, server string) (Resolution, error) {
+	var svcResult <-chan Resolution
+	if r.ServiceHints {
+		var cancel context.CancelFunc
+		svcResult, cancel = r.startService(ctx, host, server)
+		defer cancel()
+	}
+
 	grp, ctx := errgroup.WithContext(ctx)
 
 	var (
-		ips  []net.IPAddr
+		res  Resolution
 		errs error
 	)
 
@@ -135,15 +294,17 @@ func (r *Client) lookupIPs(ctx context.Context, host string) ([]net.IPAddr, erro
 		qt := qt // capture loop variable per Uber guidance
 
 		grp.Go(func() error {
-			addrs, err := r.lookup(ctx, host, qt)
+			addrs, chain, ttl, err := r.lookupChain(ctx, host, server, qt)
 			r.mu.Lock()
 			defer r.mu.Unlock()
 
+			res.CNAMEs = mergeNames(res.CNAMEs, chain)
 			if err != nil {
 				errs = multierr.Append(errs, err) // collect but don’t cancel peer
 				return nil
 			}
-			ips = append(ips, addrs...)
+			res.Addrs = append(res.Addrs, addrs...)
+			res.TTL = minTTL(res.TTL, ttl)
 			return nil
 		})
 	}
@@ -154,21 +315,65 @@ func (r *Client) lookupIPs(ctx context.Context, host string) ([]net.IPAddr, erro
 		errs = multierr.Append(errs, err)
 	}
 
-	if len(ips) == 0 {
+	if svc := awaitService(svcResult); len(svc.Addrs) > 0 {
+		res.Addrs = mergeAddrs(res.Addrs, svc.Addrs)
+		res.Targets = svc.Targets
+		res.TTL = minTTL(res.TTL, svc.TTL)
+	}
+	if len(res.Addrs) == 0 {
 		// Both lookups failed – return the aggregated error list.
-		return nil, fmt.Errorf("dns lookup for %q: %w", host, errs)
+		return Resolution{}, fmt.Errorf("dns lookup for %q: %w", host, errs)
+	}
+	return res, nil
+}
+
+// lookupChain resolves qtype for host, chasing the CNAME chain when the
+// answer stops at an alias without any address records for its target.
+// The TTL is the lowest of every answer along the way.
+func (r *Client) lookupChain(ctx context.Context, host, server string, qtype uint16) ([]net.IPAddr, []string, time.Duration, error) {
+	var (
+		chain []string
+		ttl   time.Duration
+	)
+	name := host
+	for {
+		ips, aliases, answerTTL, err := r.lookup(ctx, name, server, qtype)
+		chain = append(chain, aliases...)
+		ttl = minTTL(ttl, answerTTL)
+		switch {
+		case err != nil:
+			return nil, chain, 0, err
+		case len(chain) > MaxCNAMEDepth:
+			return nil, chain, 0, fmt.Errorf("%w: %q", ErrCNAMEDepth, host)
+		case len(ips) > 0:
+			return ips, chain, ttl, nil
+		case len(aliases) == 0:
+			return nil, chain, 0, ErrNoRecords
+		}
+		// Incomplete answer: ask for the final target directly.
+		name = aliases[len(aliases)-1]
 	}
-	return ips, nil
 }
 
 // lookup resolves qtype (A, AAAA, …) for host and returns the parsed
-// IP answers. It retries r.Retries additional times before giving up.
-func (r *Client) lookup(ctx context.Context, host string, qtype uint16) ([]net.IPAddr, error) {
+// IP answers along with any CNAME chain starting at host and the lowest
+// TTL in the answer. It retries r.Retries additional times before giving up,
+// moving down the ranked resolvers with each attempt unless server pins
+// the query to one upstream.
+func (r *Client) lookup(ctx context.Context, host, server string, qtype uint16) ([]net.IPAddr, []string, time.Duration, error) {
+	servers := []string{server}
+	if server == "" {
+		var err error
+		if servers, err = r.serversFor(host); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
 	var lastErr error
 	for attempt := uint(0); attempt <= r.Retries; attempt++ {
 		// check if caller cancellation
 		if err := ctx.Err(); err != nil {
-			return nil, err
+			return nil, nil, 0, err
 		}
 
 		// Fresh request each attempt: ExchangeContext mutates *dns.Msg
@@ -176,27 +381,84 @@ func (r *Client) lookup(ctx context.Context, host string, qtype uint16) ([]net.I
 		req := &dns.Msg{}
 		req.SetQuestion(domain, qtype)
 
-		resp, _, err := r.Client.ExchangeContext(ctx, req, r.getResolver())
+		resp, err := r.exchange(ctx, req, r.attemptServers(servers, attempt))
 		if err != nil {
 			lastErr = err
 			continue // retry
 		}
 		if resp == nil {
-			return nil, ErrEmptyMsg
+			return nil, nil, 0, ErrEmptyMsg
 		}
 
+		chain := parseCNAMEs(resp, domain)
 		ips, err := parseIPs(resp)
-		if err != nil {
+		if err != nil && len(chain) == 0 {
+			// NXDOMAIN and NODATA are answers, not failures: asking
+			// again would only get the same reply.
+			switch resp.Rcode {
+			case dns.RcodeNameError:
+				return nil, nil, 0, &NegativeError{Name: host, Err: ErrNXDomain, TTL: soaTTL(resp)}
+			case dns.RcodeSuccess:
+				if len(resp.Answer) == 0 {
+					return nil, nil, 0, &NegativeError{Name: host, Err: ErrNoRecords, TTL: soaTTL(resp)}
+				}
+			}
 			lastErr = err
 			continue // retry
 		}
-		return ips, nil
+		return ips, chain, answerTTL(resp), nil
 	}
 
 	if lastErr == nil {
 		lastErr = fmt.Errorf("dns lookup failed for %q", host)
 	}
-	return nil, lastErr
+	return nil, nil, 0, lastErr
+}
+
+// answerTTL returns the lowest TTL of the address and alias records in
+// resp, or 0 if it has none.
+func answerTTL(resp *dns.Msg) time.Duration {
+	var ttl time.Duration
+	for _, rr := range resp.Answer {
+		switch rr.(type) {
+		case *dns.A, *dns.AAAA, *dns.CNAME:
+			ttl = minTTL(ttl, time.Duration(rr.Header().Ttl)*time.Second)
+		}
+	}
+	return ttl
+}
+
+// soaTTL returns how long the negative answer resp may be cached: the
+// lower of the TTL and MINIMUM fields of the SOA record in its authority
+// section (RFC 2308, section 5), or 0 if it has none.
+func soaTTL(resp *dns.Msg) time.Duration {
+	for _, rr := range resp.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return time.Duration(min(soa.Hdr.Ttl, soa.Minttl)) * time.Second
+		}
+	}
+	return 0
+}
+
+// mergeAddrs appends the addresses in b that are not already in a.
+func mergeAddrs(a, b []net.IPAddr) []net.IPAddr {
+	for _, ip := range b {
+		if !slices.ContainsFunc(a, func(x net.IPAddr) bool { return x.IP.Equal(ip.IP) }) {
+			a = append(a, ip)
+		}
+	}
+	return a
+}
+
+// minTTL returns the lower of two TTLs, treating 0 as unknown.
+func minTTL(a, b time.Duration) time.Duration {
+	switch {
+	case a == 0:
+		return b
+	case b == 0:
+		return a
+	}
+	return min(a, b)
 }
 
 // parseIPs parses the DNS response and returns a slice of IPv4 & v6 addresses.
@@ -222,18 +484,154 @@ func parseIPs(resp *dns.Msg) ([]net.IPAddr, error) {
 	return ips, nil
 }
 
-// getResolver returns a random resolver from the list of resolvers.
-func (r *Client) getResolver() string {
-	if len(r.Resolvers) == 0 {
-		return _defaultResolver
+// parseCNAMEs follows the CNAME records in resp starting at qname and
+// returns the alias targets in order, without trailing dots. Loops and
+// chains longer than MaxCNAMEDepth are cut short.
+func parseCNAMEs(resp *dns.Msg, qname string) []string {
+	if resp == nil {
+		return nil
+	}
+	targets := make(map[string]string)
+	for _, rr := range resp.Answer {
+		if c, ok := rr.(*dns.CNAME); ok {
+			targets[strings.ToLower(c.Hdr.Name)] = c.Target
+		}
 	}
 
-	// Use crypto/rand for secure random selection
-	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(r.Resolvers))))
+	var chain []string
+	name := strings.ToLower(qname)
+	for len(chain) <= MaxCNAMEDepth {
+		target, ok := targets[name]
+		if !ok {
+			break
+		}
+		delete(targets, name) // guards against loops
+		chain = append(chain, strings.TrimSuffix(target, "."))
+		name = strings.ToLower(target)
+	}
+	return chain
+}
+
+// mergeNames appends the names in b that are not already in a.
+func mergeNames(a, b []string) []string {
+	for _, n := range b {
+		if !slices.Contains(a, n) {
+			a = append(a, n)
+		}
+	}
+	return a
+}
+
+// usesSystem reports whether the System resolver is configured, among
+// the Client's resolvers or the upstreams of any route.
+func (r *Client) usesSystem() bool {
+	if r.System == nil {
+		return false
+	}
+	if slices.Contains(r.Resolvers, System) {
+		return true
+	}
+	return slices.ContainsFunc(r.Routes, func(rt Route) bool {
+		return slices.Contains(rt.Upstreams, System)
+	})
+}
+
+// serversFor returns the resolvers to ask about host, best first as
+// ranked by r.Pool. They are those of the route for host, if any, or else
+// r.Resolvers. A System entry expands to the servers the host would ask
+// about host, rotated to a random start if the host's configuration says so.
+func (r *Client) serversFor(host string) ([]string, error) {
+	_, upstreams := r.UpstreamsFor(host)
+	if r.System == nil || !slices.Contains(upstreams, System) {
+		return r.Pool.Rank(upstreams), nil
+	}
+	cfg, err := r.System.Config()
 	if err != nil {
-		// Fall back to first resolver on error
-		return r.Resolvers[0]
+		return nil, err
+	}
+	var servers []string
+	for _, addr := range upstreams {
+		if addr == System {
+			servers = append(servers, cfg.ServersFor(host)...)
+		} else {
+			servers = append(servers, addr)
+		}
+	}
+	if cfg.Rotate {
+		i := randIndex(len(servers))
+		servers = append(servers[i:], servers[:i]...)
+	}
+	return r.Pool.Rank(servers), nil
+}
+
+// attemptServers returns the servers for one attempt: the next one in
+// ranked order, plus the one after it when racing.
+func (r *Client) attemptServers(ranked []string, attempt uint) []string {
+	n := uint(len(ranked))
+	first := ranked[attempt%n]
+	if !r.Race || n < 2 {
+		return []string{first}
+	}
+	return []string{first, ranked[(attempt+1)%n]}
+}
+
+// exchange sends m to every server at once and returns the first good
+// reply, or all their errors if none answered.
+func (r *Client) exchange(ctx context.Context, m *dns.Msg, servers []string) (*dns.Msg, error) {
+	if len(servers) == 1 {
+		return r.exchangeOne(ctx, m, servers[0])
 	}
 
-	return r.Resolvers[n.Int64()]
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // the losers of the race
+
+	type reply struct {
+		msg *dns.Msg
+		err error
+	}
+	replies := make(chan reply, len(servers))
+	for _, server := range servers {
+		go func() {
+			msg, err := r.exchangeOne(ctx, m.Copy(), server)
+			replies <- reply{msg, err}
+		}()
+	}
+	var errs error
+	for range servers {
+		rep := <-replies
+		if rep.err == nil {
+			return rep.msg, nil
+		}
+		errs = multierr.Append(errs, rep.err)
+	}
+	return nil, errs
+}
+
+// exchangeOne sends m to server and reports the outcome to r.Pool. A reply
+// saying the server failed counts as an error, so that another upstream is
+// tried. Queries cut short by ctx, and those of HTTPS lookups, are not held
+// against the server.
+func (r *Client) exchangeOne(ctx context.Context, m *dns.Msg, server string) (*dns.Msg, error) {
+	start := time.Now()
+	resp, _, err := r.Client.ExchangeContext(ctx, m, server)
+	if err == nil && resp != nil && (resp.Rcode == dns.RcodeServerFailure || resp.Rcode == dns.RcodeRefused) {
+		err = fmt.Errorf("%w: %s from %s", ErrUpstream, dns.RcodeToString[resp.Rcode], server)
+		resp = nil
+	}
+	unreported, _ := ctx.Value(unreportedKey{}).(bool)
+	if err == nil || (ctx.Err() == nil && !unreported) {
+		r.Pool.Report(server, time.Since(start), err)
+	}
+	return resp, err
+}
+
+// randIndex returns a random index into a list of n > 0 elements.
+func randIndex(n int) int {
+	// Use crypto/rand for secure random selection
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		// Fall back to the first element on error
+		return 0
+	}
+	return int(i.Int64())
 }
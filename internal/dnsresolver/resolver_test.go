@@ -2,6 +2,7 @@ package dnsresolver
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"sort"
 	"testing"
@@ -10,6 +11,7 @@ import (
 	"github.com/miekg/dns"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"go.uber.org/multierr"
 )
 
 type mockClient struct {
@@ -268,38 +270,204 @@ func (s *ResolverTestSuite) TestLookupHost() {
 	}
 }
 
-func (s *ResolverTestSuite) TestGetResolver() {
+func (s *ResolverTestSuite) TestResolveCNAMEChain() {
+	cname := func(name, target string) dns.RR {
+		return &dns.CNAME{
+			Hdr:    dns.RR_Header{Name: dns.Fqdn(name), Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 60},
+			Target: dns.Fqdn(target),
+		}
+	}
+	a := func(name, ip string) dns.RR {
+		return &dns.A{
+			Hdr: dns.RR_Header{Name: dns.Fqdn(name), Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP(ip),
+		}
+	}
+	matchQuery := func(name string, qtype uint16) interface{} {
+		return mock.MatchedBy(func(msg *dns.Msg) bool {
+			return len(msg.Question) > 0 &&
+				msg.Question[0].Qtype == qtype &&
+				msg.Question[0].Name == dns.Fqdn(name)
+		})
+	}
+	matchName := func(name string) interface{} {
+		return mock.MatchedBy(func(msg *dns.Msg) bool {
+			return len(msg.Question) > 0 && msg.Question[0].Name == dns.Fqdn(name)
+		})
+	}
+
+	testCases := []struct {
+		name           string
+		setupMock      func(*mockClient)
+		expectedIPs    []string
+		expectedCNAMEs []string
+		expectedErr    error
+	}{
+		{
+			name: "complete chain in one answer",
+			setupMock: func(m *mockClient) {
+				resp := &dns.Msg{Answer: []dns.RR{
+					cname("www.example.com", "example.edgekey.net"),
+					cname("example.edgekey.net", "e1.akamaiedge.net"),
+					a("e1.akamaiedge.net", "23.1.2.3"),
+				}}
+				m.On("ExchangeContext", mock.Anything, matchQuery("www.example.com", dns.TypeA), mock.Anything).
+					Return(resp, time.Duration(0), nil)
+				m.On("ExchangeContext", mock.Anything, matchQuery("www.example.com", dns.TypeAAAA), mock.Anything).
+					Return(nil, time.Duration(0), ErrNoRecords)
+			},
+			expectedIPs:    []string{"23.1.2.3"},
+			expectedCNAMEs: []string{"example.edgekey.net", "e1.akamaiedge.net"},
+		},
+		{
+			name: "partial chain is chased",
+			setupMock: func(m *mockClient) {
+				partial := &dns.Msg{Answer: []dns.RR{
+					cname("www.example.com", "example.cdn.net"),
+				}}
+				final := &dns.Msg{Answer: []dns.RR{
+					a("example.cdn.net", "198.51.100.7"),
+				}}
+				m.On("ExchangeContext", mock.Anything, matchQuery("www.example.com", dns.TypeA), mock.Anything).
+					Return(partial, time.Duration(0), nil)
+				m.On("ExchangeContext", mock.Anything, matchQuery("example.cdn.net", dns.TypeA), mock.Anything).
+					Return(final, time.Duration(0), nil)
+				m.On("ExchangeContext", mock.Anything, matchQuery("www.example.com", dns.TypeAAAA), mock.Anything).
+					Return(nil, time.Duration(0), ErrNoRecords)
+			},
+			expectedIPs:    []string{"198.51.100.7"},
+			expectedCNAMEs: []string{"example.cdn.net"},
+		},
+		{
+			name: "alias loop is bounded",
+			setupMock: func(m *mockClient) {
+				loop := &dns.Msg{Answer: []dns.RR{
+					cname("www.example.com", "loop.example.com"),
+				}}
+				back := &dns.Msg{Answer: []dns.RR{
+					cname("loop.example.com", "www.example.com"),
+				}}
+				m.On("ExchangeContext", mock.Anything, matchName("www.example.com"), mock.Anything).
+					Return(loop, time.Duration(0), nil)
+				m.On("ExchangeContext", mock.Anything, matchName("loop.example.com"), mock.Anything).
+					Return(back, time.Duration(0), nil)
+			},
+			expectedErr: ErrCNAMEDepth,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			s.SetupTest()
+			tc.setupMock(s.client)
+
+			res, err := s.resolver.Resolve(context.Background(), "www.example.com")
+			if tc.expectedErr != nil {
+				s.ErrorIs(err, tc.expectedErr)
+				return
+			}
+
+			s.NoError(err)
+			ips := make([]string, 0, len(res.Addrs))
+			for _, addr := range res.Addrs {
+				ips = append(ips, addr.IP.String())
+			}
+			s.ElementsMatch(tc.expectedIPs, ips)
+			s.Equal(tc.expectedCNAMEs, res.CNAMEs)
+		})
+	}
+}
+
+func (s *ResolverTestSuite) TestResolveTTL() {
+	rr := func(typ uint16, name string, ttl uint32, data string) dns.RR {
+		hdr := dns.RR_Header{Name: dns.Fqdn(name), Rrtype: typ, Class: dns.ClassINET, Ttl: ttl}
+		switch typ {
+		case dns.TypeCNAME:
+			return &dns.CNAME{Hdr: hdr, Target: dns.Fqdn(data)}
+		case dns.TypeAAAA:
+			return &dns.AAAA{Hdr: hdr, AAAA: net.ParseIP(data)}
+		}
+		return &dns.A{Hdr: hdr, A: net.ParseIP(data)}
+	}
+	query := func(qtype uint16) interface{} {
+		return mock.MatchedBy(func(msg *dns.Msg) bool {
+			return len(msg.Question) > 0 && msg.Question[0].Qtype == qtype
+		})
+	}
+
+	// The lowest TTL wins across the alias chain and both address families.
+	s.client.On("ExchangeContext", mock.Anything, query(dns.TypeA), mock.Anything).
+		Return(&dns.Msg{Answer: []dns.RR{
+			rr(dns.TypeCNAME, "www.example.com", 3600, "edge.cdn.net"),
+			rr(dns.TypeA, "edge.cdn.net", 120, "198.51.100.7"),
+			rr(dns.TypeA, "edge.cdn.net", 300, "198.51.100.8"),
+		}}, time.Duration(0), nil)
+	s.client.On("ExchangeContext", mock.Anything, query(dns.TypeAAAA), mock.Anything).
+		Return(&dns.Msg{Answer: []dns.RR{
+			rr(dns.TypeCNAME, "www.example.com", 3600, "edge.cdn.net"),
+			rr(dns.TypeAAAA, "edge.cdn.net", 30, "2001:db8::7"),
+		}}, time.Duration(0), nil)
+
+	res, err := s.resolver.Resolve(context.Background(), "www.example.com")
+	s.Require().NoError(err)
+	s.Equal(30*time.Second, res.TTL)
+
+	lit, err := s.resolver.Resolve(context.Background(), "192.0.2.1")
+	s.Require().NoError(err)
+	s.Zero(lit.TTL, "an IP literal has no TTL")
+}
+
+func (s *ResolverTestSuite) TestServersFor() {
 	testCases := []struct {
 		name      string
 		resolvers []string
-		expected  string
+		expected  []string
 	}{
 		{
 			name:     "no resolvers configured",
-			expected: _defaultResolver,
+			expected: []string{_defaultResolver},
 		},
 		{
 			name:      "single resolver",
 			resolvers: []string{"8.8.8.8:53"},
-			expected:  "8.8.8.8:53",
+			expected:  []string{"8.8.8.8:53"},
 		},
 		{
 			name:      "multiple resolvers",
 			resolvers: []string{"8.8.8.8:53", "8.8.4.4:53"},
-			expected:  "", // Will be checked differently due to randomness
+			expected:  []string{"8.8.8.8:53", "8.8.4.4:53"}, // unknown upstreams keep their order
 		},
 	}
 
 	for _, tc := range testCases {
 		s.Run(tc.name, func() {
 			s.resolver.Resolvers = tc.resolvers
-			resolver := s.resolver.getResolver()
+			servers, err := s.resolver.serversFor("example.com")
+			s.Require().NoError(err)
+			s.Equal(tc.expected, servers)
+		})
+	}
+}
 
-			if len(tc.resolvers) > 1 {
-				s.Contains(tc.resolvers, resolver)
-			} else {
-				s.Equal(tc.expected, resolver)
-			}
+func (s *ResolverTestSuite) TestNotFound() {
+	nx := func(name string) error { return &NegativeError{Name: name, Err: ErrNXDomain} }
+	nodata := &NegativeError{Name: "v4.example", Err: ErrNoRecords}
+	testCases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil},
+		{name: "nxdomain", err: nx("gone.example"), want: true},
+		{name: "wrapped", err: fmt.Errorf("dns lookup for %q: %w", "gone.example", nx("gone.example")), want: true},
+		{name: "A and AAAA", err: multierr.Combine(nx("a.example"), nx("a.example")), want: true},
+		{name: "no addresses", err: nodata},
+		{name: "nxdomain and timeout", err: multierr.Combine(nx("a.example"), context.DeadlineExceeded)},
+		{name: "upstream failure", err: ErrUpstream},
+	}
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			s.Equal(tc.want, NotFound(tc.err))
 		})
 	}
 }
@@ -0,0 +1,57 @@
+package dnsresolver
+
+import "strings"
+
+// Route sends lookups for names under a domain suffix to their own
+// upstreams, such as an internal zone that only the VPN's resolver knows.
+type Route struct {
+	Suffix    string   // e.g. "corp.example"; covers the name itself and every name below it
+	Upstreams []string // resolver addresses as accepted by WithResolvers
+}
+
+// Matches reports whether host is the route's suffix or a name below it.
+func (rt Route) Matches(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	return host == rt.Suffix || strings.HasSuffix(host, "."+rt.Suffix)
+}
+
+// WithRoutes returns an option to resolve names under each route's suffix
+// with the route's upstreams instead of the Client's resolvers. When
+// several routes match a name, the one with the longest suffix wins.
+func WithRoutes(routes []Route) Opt {
+	return func(r *Client) {
+		r.Routes = make([]Route, 0, len(routes))
+		for _, rt := range routes {
+			rt.Suffix = strings.ToLower(strings.Trim(strings.TrimPrefix(rt.Suffix, "*."), "."))
+			r.Routes = append(r.Routes, rt)
+		}
+	}
+}
+
+// UpstreamsFor returns the resolvers configured for host, before health
+// ranking and before System is expanded, along with the suffix of the
+// route that chose them. The suffix is empty when the Client's own
+// resolvers apply.
+func (r *Client) UpstreamsFor(host string) (suffix string, upstreams []string) {
+	if rt, ok := r.routeFor(host); ok {
+		return rt.Suffix, rt.Upstreams
+	}
+	if len(r.Resolvers) == 0 {
+		return "", []string{_defaultResolver}
+	}
+	return "", r.Resolvers
+}
+
+// routeFor returns the route with the longest suffix matching host.
+func (r *Client) routeFor(host string) (Route, bool) {
+	var (
+		best  Route
+		found bool
+	)
+	for _, rt := range r.Routes {
+		if rt.Matches(host) && (!found || len(rt.Suffix) > len(best.Suffix)) {
+			best, found = rt, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,85 @@
+package dnsresolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type RouteTestSuite struct {
+	suite.Suite
+	resolver *Client
+}
+
+func (s *RouteTestSuite) SetupTest() {
+	s.resolver = New(5*time.Second,
+		WithResolvers([]string{"https://dns.example/dns-query"}),
+		WithRoutes([]Route{
+			{Suffix: "corp.example", Upstreams: []string{"udp://10.8.0.1"}},
+			{Suffix: ".Lab.Corp.Example.", Upstreams: []string{"udp://10.9.0.1"}},
+		}),
+	)
+}
+
+func (s *RouteTestSuite) TestUpstreamsFor() {
+	testCases := []struct {
+		host      string
+		suffix    string
+		upstreams []string
+	}{
+		{"corp.example", "corp.example", []string{"udp://10.8.0.1"}},
+		{"wiki.corp.example.", "corp.example", []string{"udp://10.8.0.1"}},
+		{"ci.LAB.corp.example", "lab.corp.example", []string{"udp://10.9.0.1"}},
+		{"notcorp.example", "", []string{"https://dns.example/dns-query"}},
+		{"example.com", "", []string{"https://dns.example/dns-query"}},
+	}
+	for _, tc := range testCases {
+		s.Run(tc.host, func() {
+			suffix, upstreams := s.resolver.UpstreamsFor(tc.host)
+			s.Equal(tc.suffix, suffix)
+			s.Equal(tc.upstreams, upstreams)
+		})
+	}
+}
+
+func (s *RouteTestSuite) TestLookupUsesRoute() {
+	client := new(mockClient)
+	s.resolver.Client = client
+	client.On("ExchangeContext", mock.Anything, mock.Anything, "udp://10.9.0.1").
+		Return(answerA("ci.lab.corp.example", "10.9.2.2"), time.Duration(0), nil)
+
+	addrs, err := s.resolver.LookupHost(context.Background(), "ci.lab.corp.example")
+	s.Require().NoError(err)
+	s.Equal("10.9.2.2", addrs[0].IP.String())
+	client.AssertNotCalled(s.T(), "ExchangeContext", mock.Anything, mock.Anything, "https://dns.example/dns-query")
+	client.AssertNotCalled(s.T(), "ExchangeContext", mock.Anything, mock.Anything, "udp://10.8.0.1")
+}
+
+func (s *RouteTestSuite) TestAliasTargetsAreRoutedToo() {
+	client := new(mockClient)
+	s.resolver.Client = client
+	alias := &dns.Msg{Answer: []dns.RR{&dns.CNAME{
+		Hdr:    dns.RR_Header{Name: "app.corp.example.", Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 60},
+		Target: "app.cdn.example.",
+	}}}
+	query := func(name string) any {
+		return mock.MatchedBy(func(m *dns.Msg) bool { return m.Question[0].Name == name })
+	}
+	client.On("ExchangeContext", mock.Anything, query("app.corp.example."), "udp://10.8.0.1").
+		Return(alias, time.Duration(0), nil)
+	client.On("ExchangeContext", mock.Anything, query("app.cdn.example."), "https://dns.example/dns-query").
+		Return(answerA("app.cdn.example", "203.0.113.5"), time.Duration(0), nil)
+
+	res, err := s.resolver.Resolve(context.Background(), "app.corp.example")
+	s.Require().NoError(err)
+	s.Equal([]string{"app.cdn.example"}, res.CNAMEs)
+	s.Equal("203.0.113.5", res.Addrs[0].IP.String())
+}
+
+func TestRouteTestSuite(t *testing.T) {
+	suite.Run(t, new(RouteTestSuite))
+}
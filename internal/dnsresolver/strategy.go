@@ -0,0 +1,114 @@
+package dnsresolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.uber.org/multierr"
+)
+
+// ErrUnknownStrategy is returned by ParseStrategy for an unknown name.
+var ErrUnknownStrategy = errors.New("unknown resolution strategy")
+
+// Strategy selects how a name is resolved when several upstreams are
+// configured.
+type Strategy string
+
+const (
+	// StrategyFirst asks the healthiest upstream and moves on to the
+	// others only if it fails. It is the default.
+	StrategyFirst Strategy = "first"
+	// StrategyUnion asks every upstream and returns the union of their
+	// answers, so that a block covers each address a CDN hands out to any
+	// of them, along with which upstream returned which address.
+	StrategyUnion Strategy = "union"
+)
+
+// ParseStrategy returns the Strategy named s. The empty string is returned
+// as is and means the Client's default.
+func ParseStrategy(s string) (Strategy, error) {
+	switch st := Strategy(s); st {
+	case "", StrategyFirst, StrategyUnion:
+		return st, nil
+	}
+	return "", fmt.Errorf("%w %q (want %q or %q)", ErrUnknownStrategy, s, StrategyFirst, StrategyUnion)
+}
+
+// WithStrategy returns an option to set the strategy used for lookups
+// whose context does not carry one. The default is StrategyFirst.
+func WithStrategy(s Strategy) Opt {
+	return func(r *Client) {
+		r.Strategy = s
+	}
+}
+
+type strategyKey struct{}
+
+// ContextWithStrategy returns a copy of ctx under which lookups use s
+// instead of the Client's strategy. An empty s leaves ctx unchanged.
+func ContextWithStrategy(ctx context.Context, s Strategy) context.Context {
+	if s == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, strategyKey{}, s)
+}
+
+// strategyFor returns the strategy for a lookup under ctx.
+func (r *Client) strategyFor(ctx context.Context) Strategy {
+	if s, ok := ctx.Value(strategyKey{}).(Strategy); ok {
+		return s
+	}
+	if r.Strategy == "" {
+		return StrategyFirst
+	}
+	return r.Strategy
+}
+
+// lookupUnion resolves host at every upstream at once and merges what they
+// return. It fails only if no upstream returned an address.
+func (r *Client) lookupUnion(ctx context.Context, host string) (Resolution, error) {
+	servers, err := r.serversFor(host)
+	if err != nil {
+		return Resolution{}, err
+	}
+
+	var (
+		wg      sync.WaitGroup
+		results = make([]Resolution, len(servers))
+		errs    = make([]error, len(servers))
+	)
+	for i, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = r.lookupIPs(ctx, host, server)
+		}()
+	}
+	wg.Wait()
+
+	var out Resolution
+	for i, res := range results {
+		if errs[i] != nil {
+			continue
+		}
+		if out.Sources == nil {
+			out.Sources = make(map[string][]string)
+		}
+		out.CNAMEs = mergeNames(out.CNAMEs, res.CNAMEs)
+		out.Targets = mergeNames(out.Targets, res.Targets)
+		out.TTL = minTTL(out.TTL, res.TTL)
+		for _, ip := range res.Addrs {
+			key := ip.String()
+			if _, seen := out.Sources[key]; !seen {
+				out.Addrs = append(out.Addrs, ip)
+			}
+			out.Sources[key] = append(out.Sources[key], servers[i])
+		}
+	}
+	if len(out.Addrs) == 0 {
+		return Resolution{}, multierr.Combine(errs...)
+	}
+	return out, nil
+}
@@ -0,0 +1,96 @@
+package dnsresolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type StrategyTestSuite struct {
+	suite.Suite
+	client   *mockClient
+	resolver *Client
+}
+
+func (s *StrategyTestSuite) SetupTest() {
+	s.client = new(mockClient)
+	s.resolver = New(5*time.Second, WithResolvers([]string{"eu:53", "us:53", "down:53"}))
+	s.resolver.Client = s.client
+
+	answer := func(ips ...string) *dns.Msg {
+		m := new(dns.Msg)
+		for _, ip := range ips {
+			m.Answer = append(m.Answer, answerA("cdn.example", ip).Answer...)
+		}
+		return m
+	}
+	isA := mock.MatchedBy(func(m *dns.Msg) bool { return m.Question[0].Qtype == dns.TypeA })
+	isAAAA := mock.MatchedBy(func(m *dns.Msg) bool { return m.Question[0].Qtype == dns.TypeAAAA })
+	s.client.On("ExchangeContext", mock.Anything, isA, "eu:53").
+		Return(answer("192.0.2.1", "192.0.2.2"), time.Duration(0), nil)
+	s.client.On("ExchangeContext", mock.Anything, isA, "us:53").
+		Return(answer("192.0.2.2", "198.51.100.9"), time.Duration(0), nil)
+	s.client.On("ExchangeContext", mock.Anything, isA, "down:53").
+		Return(nil, time.Duration(0), errTimeout)
+	s.client.On("ExchangeContext", mock.Anything, isAAAA, mock.Anything).
+		Return(new(dns.Msg), time.Duration(0), nil)
+}
+
+func (s *StrategyTestSuite) TestFirstAsksOneUpstream() {
+	res, err := s.resolver.Resolve(context.Background(), "cdn.example")
+	s.Require().NoError(err)
+	s.Len(res.Addrs, 2)
+	s.Nil(res.Sources)
+}
+
+func (s *StrategyTestSuite) TestUnion() {
+	ctx := ContextWithStrategy(context.Background(), StrategyUnion)
+	res, err := s.resolver.Resolve(ctx, "cdn.example")
+	s.Require().NoError(err)
+
+	var ips []string
+	for _, ip := range res.Addrs {
+		ips = append(ips, ip.String())
+	}
+	s.ElementsMatch([]string{"192.0.2.1", "192.0.2.2", "198.51.100.9"}, ips)
+	s.Equal([]string{"eu:53"}, res.Sources["192.0.2.1"])
+	s.ElementsMatch([]string{"eu:53", "us:53"}, res.Sources["192.0.2.2"])
+	s.Equal([]string{"us:53"}, res.Sources["198.51.100.9"])
+	s.Equal(time.Minute, res.TTL)
+}
+
+func (s *StrategyTestSuite) TestUnionByDefault() {
+	s.resolver.Strategy = StrategyUnion
+	res, err := s.resolver.Resolve(context.Background(), "cdn.example")
+	s.Require().NoError(err)
+	s.Len(res.Addrs, 3)
+
+	// A per-lookup strategy overrides the default.
+	res, err = s.resolver.Resolve(ContextWithStrategy(context.Background(), StrategyFirst), "cdn.example")
+	s.Require().NoError(err)
+	s.Len(res.Addrs, 2)
+}
+
+func (s *StrategyTestSuite) TestUnionFailsOnlyIfAllFail() {
+	s.resolver.Resolvers = []string{"down:53"}
+	_, err := s.resolver.Resolve(ContextWithStrategy(context.Background(), StrategyUnion), "cdn.example")
+	s.ErrorIs(err, errTimeout)
+}
+
+func (s *StrategyTestSuite) TestParseStrategy() {
+	for _, in := range []string{"", "first", "union"} {
+		st, err := ParseStrategy(in)
+		s.NoError(err)
+		s.Equal(Strategy(in), st)
+	}
+	_, err := ParseStrategy("fastest")
+	s.ErrorIs(err, ErrUnknownStrategy)
+}
+
+func TestStrategyTestSuite(t *testing.T) {
+	suite.Run(t, new(StrategyTestSuite))
+}
@@ -0,0 +1,177 @@
+package dnsresolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// _maxServiceTargets is the most target names of one name's HTTPS records
+// that are resolved.
+const _maxServiceTargets = 4
+
+// _serviceGrace is how long a lookup waits for the HTTPS records of a name
+// once its A and AAAA queries have answered.
+const _serviceGrace = 200 * time.Millisecond
+
+// unreportedKey marks queries whose failures say nothing about the health
+// of an upstream.
+type unreportedKey struct{}
+
+// startService looks up the HTTPS records of host in the background, within
+// a single query timeout, and returns the channel its result arrives on.
+// Many upstreams drop or stall type 65 questions, so its failures are not
+// reported to r.Pool, and the caller need not wait for it: cancel stops it.
+func (r *Client) startService(ctx context.Context, host, server string) (result <-chan Resolution, cancel context.CancelFunc) {
+	ctx = context.WithValue(ctx, unreportedKey{}, true)
+	if r.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	out := make(chan Resolution, 1)
+	go func() {
+		// Most names publish no HTTPS record; that is not an error.
+		svc, _ := r.lookupService(ctx, host, server)
+		out <- svc
+	}()
+	return out, cancel
+}
+
+// awaitService returns the result of startService, or nothing if it does
+// not arrive within _serviceGrace.
+func awaitService(result <-chan Resolution) Resolution {
+	if result == nil {
+		return Resolution{}
+	}
+	t := time.NewTimer(_serviceGrace)
+	defer t.Stop()
+	select {
+	case svc := <-result:
+		return svc
+	case <-t.C:
+		return Resolution{}
+	}
+}
+
+// WithServiceHints returns an option to also look up the HTTPS records
+// (RFC 9460) of every name. Browsers use them to connect, over HTTP/3 in
+// particular, to the addresses in their ipv4hint and ipv6hint parameters
+// and to alternative target names, which need not match the name's A and
+// AAAA records. With this option those addresses are resolved too and
+// become part of Resolution.Addrs, and the targets are listed in
+// Resolution.Targets.
+func WithServiceHints() Opt {
+	return func(r *Client) {
+		r.ServiceHints = true
+	}
+}
+
+// lookupService looks up the HTTPS records of host and returns the
+// addresses they hint at together with those of the target names they
+// point to. A non-empty server pins every query to that upstream.
+func (r *Client) lookupService(ctx context.Context, host, server string) (Resolution, error) {
+	resp, err := r.query(ctx, host, server, dns.TypeHTTPS)
+	if err != nil {
+		return Resolution{}, err
+	}
+
+	var out Resolution
+	hints, targets, ttl := parseHTTPS(resp, host)
+	out.Addrs, out.TTL = hints, ttl
+	for _, target := range targets[:min(len(targets), _maxServiceTargets)] {
+		found := false
+		for _, qt := range [...]uint16{dns.TypeA, dns.TypeAAAA} {
+			addrs, _, ttl, err := r.lookupChain(ctx, target, server, qt)
+			if err != nil {
+				continue
+			}
+			found = true
+			out.Addrs = mergeAddrs(out.Addrs, addrs)
+			out.TTL = minTTL(out.TTL, ttl)
+		}
+		if found {
+			out.Targets = append(out.Targets, target)
+		}
+	}
+	return out, nil
+}
+
+// query sends one question about host and returns the answer, retrying
+// r.Retries additional times on the next ranked resolvers if the exchange
+// fails. A non-empty server pins the query to that upstream.
+func (r *Client) query(ctx context.Context, host, server string, qtype uint16) (*dns.Msg, error) {
+	servers := []string{server}
+	if server == "" {
+		var err error
+		if servers, err = r.serversFor(host); err != nil {
+			return nil, err
+		}
+	}
+
+	var lastErr error
+	for attempt := uint(0); attempt <= r.Retries; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		req := &dns.Msg{}
+		req.SetQuestion(dns.Fqdn(host), qtype)
+
+		resp, err := r.exchange(ctx, req, r.attemptServers(servers, attempt))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp == nil {
+			return nil, ErrEmptyMsg
+		}
+		return resp, nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("dns query failed for %q", host)
+	}
+	return nil, lastErr
+}
+
+// parseHTTPS returns the ipv4hint and ipv6hint addresses of the HTTPS and
+// SVCB records in resp, the target names other than host they point to,
+// without trailing dots, and the lowest TTL of those records. A target of
+// "." stands for the record's owner name, which host already resolves to.
+func parseHTTPS(resp *dns.Msg, host string) (hints []net.IPAddr, targets []string, ttl time.Duration) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, rr := range resp.Answer {
+		var svcb *dns.SVCB
+		switch rec := rr.(type) {
+		case *dns.HTTPS:
+			svcb = &rec.SVCB
+		case *dns.SVCB:
+			svcb = rec
+		default:
+			continue
+		}
+		ttl = minTTL(ttl, time.Duration(svcb.Hdr.Ttl)*time.Second)
+
+		target := strings.ToLower(strings.TrimSuffix(svcb.Target, "."))
+		if target != "" && target != host && !slices.Contains(targets, target) {
+			targets = append(targets, target)
+		}
+		for _, kv := range svcb.Value {
+			var ips []net.IP
+			switch v := kv.(type) {
+			case *dns.SVCBIPv4Hint:
+				ips = v.Hint
+			case *dns.SVCBIPv6Hint:
+				ips = v.Hint
+			}
+			for _, ip := range ips {
+				hints = mergeAddrs(hints, []net.IPAddr{{IP: ip}})
+			}
+		}
+	}
+	return hints, targets, ttl
+}
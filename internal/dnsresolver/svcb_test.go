@@ -0,0 +1,130 @@
+package dnsresolver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ServiceHintsTestSuite struct {
+	suite.Suite
+	client   *mockClient
+	resolver *Client
+}
+
+func (s *ServiceHintsTestSuite) SetupTest() {
+	s.client = new(mockClient)
+	s.resolver = New(5*time.Second, WithResolvers([]string{"8.8.8.8:53"}), WithServiceHints())
+	s.resolver.Client = s.client
+}
+
+// answer makes the mock reply to qtype questions about name with rrs.
+func (s *ServiceHintsTestSuite) answer(name string, qtype uint16, rrs ...dns.RR) {
+	s.client.On("ExchangeContext", mock.Anything, mock.MatchedBy(func(m *dns.Msg) bool {
+		return m.Question[0].Name == dns.Fqdn(name) && m.Question[0].Qtype == qtype
+	}), "8.8.8.8:53").Return(&dns.Msg{Answer: rrs}, time.Duration(0), nil)
+}
+
+func httpsRR(name, target string, ttl uint32, values ...dns.SVCBKeyValue) *dns.HTTPS {
+	return &dns.HTTPS{SVCB: dns.SVCB{
+		Hdr:      dns.RR_Header{Name: dns.Fqdn(name), Rrtype: dns.TypeHTTPS, Class: dns.ClassINET, Ttl: ttl},
+		Priority: 1,
+		Target:   target,
+		Value:    values,
+	}}
+}
+
+func aRR(name, ip string, ttl uint32) *dns.A {
+	return &dns.A{Hdr: dns.RR_Header{Name: dns.Fqdn(name), Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl}, A: net.ParseIP(ip)}
+}
+
+func (s *ServiceHintsTestSuite) TestAddsHintsAndTargets() {
+	s.answer("example.com", dns.TypeA, aRR("example.com", "192.0.2.1", 300))
+	s.answer("example.com", dns.TypeAAAA)
+	s.answer("example.com", dns.TypeHTTPS,
+		httpsRR("example.com", ".", 120,
+			&dns.SVCBIPv4Hint{Hint: []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2")}},
+			&dns.SVCBIPv6Hint{Hint: []net.IP{net.ParseIP("2001:db8::2")}},
+		),
+		httpsRR("example.com", "svc.example.net.", 600),
+	)
+	s.answer("svc.example.net", dns.TypeA, aRR("svc.example.net", "198.51.100.7", 60))
+	s.answer("svc.example.net", dns.TypeAAAA)
+
+	res, err := s.resolver.Resolve(context.Background(), "example.com")
+	s.Require().NoError(err)
+
+	var ips []string
+	for _, a := range res.Addrs {
+		ips = append(ips, a.IP.String())
+	}
+	s.ElementsMatch([]string{"192.0.2.1", "192.0.2.2", "2001:db8::2", "198.51.100.7"}, ips,
+		"hints and target addresses are added once each")
+	s.Equal([]string{"svc.example.net"}, res.Targets)
+	s.Equal(time.Minute, res.TTL, "the lowest TTL among every record used")
+}
+
+func (s *ServiceHintsTestSuite) TestNoHTTPSRecord() {
+	s.answer("example.com", dns.TypeA, aRR("example.com", "192.0.2.1", 300))
+	s.answer("example.com", dns.TypeAAAA)
+	s.client.On("ExchangeContext", mock.Anything, mock.MatchedBy(func(m *dns.Msg) bool {
+		return m.Question[0].Qtype == dns.TypeHTTPS
+	}), "8.8.8.8:53").Return(&dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeServerFailure}}, time.Duration(0), nil)
+
+	res, err := s.resolver.Resolve(context.Background(), "example.com")
+	s.Require().NoError(err)
+	s.Len(res.Addrs, 1)
+	s.Empty(res.Targets)
+	s.Equal(5*time.Minute, res.TTL)
+	s.Require().Len(s.resolver.Health(), 1)
+	s.Zero(s.resolver.Health()[0].Failures, "HTTPS failures are not held against the upstream")
+}
+
+func (s *ServiceHintsTestSuite) TestStalledHTTPSQuery() {
+	s.answer("example.com", dns.TypeA, aRR("example.com", "192.0.2.1", 300))
+	s.answer("example.com", dns.TypeAAAA)
+	s.client.On("ExchangeContext", mock.Anything, mock.MatchedBy(func(m *dns.Msg) bool {
+		return m.Question[0].Qtype == dns.TypeHTTPS
+	}), "8.8.8.8:53").Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	}).Return((*dns.Msg)(nil), time.Duration(0), context.Canceled)
+
+	start := time.Now()
+	res, err := s.resolver.Resolve(context.Background(), "example.com")
+	s.Require().NoError(err)
+	s.Less(time.Since(start), time.Second, "the lookup does not wait out the HTTPS query")
+	s.Len(res.Addrs, 1)
+	s.Empty(res.Targets)
+}
+
+func (s *ServiceHintsTestSuite) TestHintsAloneSuffice() {
+	s.answer("h3only.example", dns.TypeA)
+	s.answer("h3only.example", dns.TypeAAAA)
+	s.answer("h3only.example", dns.TypeHTTPS, httpsRR("h3only.example", ".", 60,
+		&dns.SVCBIPv6Hint{Hint: []net.IP{net.ParseIP("2001:db8::5")}},
+	))
+
+	addrs, err := s.resolver.LookupHost(context.Background(), "h3only.example")
+	s.Require().NoError(err)
+	s.Require().Len(addrs, 1)
+	s.Equal("2001:db8::5", addrs[0].IP.String())
+}
+
+func (s *ServiceHintsTestSuite) TestDisabledByDefault() {
+	s.resolver.ServiceHints = false
+	s.answer("example.com", dns.TypeA, aRR("example.com", "192.0.2.1", 300))
+	s.answer("example.com", dns.TypeAAAA)
+
+	_, err := s.resolver.Resolve(context.Background(), "example.com")
+	s.Require().NoError(err)
+	s.client.AssertNumberOfCalls(s.T(), "ExchangeContext", 2)
+}
+
+func TestServiceHintsTestSuite(t *testing.T) {
+	suite.Run(t, new(ServiceHintsTestSuite))
+}
@@ -0,0 +1,289 @@
+package dnsresolver
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// System is the resolver address that stands for the host's own
+	// resolver configuration, so void blocks the same addresses that
+	// applications on the machine connect to. See SystemSource.
+	System = "system"
+
+	// DefaultResolvConf is where the host's resolv.conf(5) lives.
+	DefaultResolvConf = "/etc/resolv.conf"
+	// DefaultResolverDir holds macOS's per-domain resolver(5) files.
+	DefaultResolverDir = "/etc/resolver"
+
+	// _systemCheckInterval is how often the files are checked for changes.
+	_systemCheckInterval = 5 * time.Second
+	// _defaultNdots is resolv.conf's default for the ndots option.
+	_defaultNdots = 1
+)
+
+// _fallbackServers are used when resolv.conf names no nameserver, as the
+// system resolver then asks the local host.
+var _fallbackServers = []string{"127.0.0.1:53", "[::1]:53"}
+
+// SystemConfig is the host's resolver configuration.
+type SystemConfig struct {
+	Servers []string // "host:port" addresses from resolv.conf
+	Search  []string // search domains, in order
+	Ndots   int      // dots a name needs to be tried as is before the search list
+	Rotate  bool     // spread queries over Servers instead of trying them in order
+	Scopes  []Scope  // per-domain servers, most specific first
+}
+
+// Scope sends queries for names under Domain to its own servers, as set
+// up by a macOS /etc/resolver/<domain> file; see resolver(5).
+type Scope struct {
+	Domain  string
+	Servers []string
+	Order   int // search_order; lower wins between scopes for the same domain
+}
+
+// ServersFor returns the servers to ask about name: those of the most
+// specific scope covering it, or Servers if none does.
+func (c SystemConfig) ServersFor(name string) []string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	for _, sc := range c.Scopes {
+		if name == sc.Domain || strings.HasSuffix(name, "."+sc.Domain) {
+			return sc.Servers
+		}
+	}
+	return c.Servers
+}
+
+// Names returns the names to query for host, in order, applying the search
+// list the way the system resolver does. A name ending in a dot is absolute
+// and never expanded.
+func (c SystemConfig) Names(host string) []string {
+	if strings.HasSuffix(host, ".") {
+		return []string{strings.TrimSuffix(host, ".")}
+	}
+	expanded := make([]string, 0, len(c.Search))
+	for _, d := range c.Search {
+		expanded = append(expanded, host+"."+d)
+	}
+	if strings.Count(host, ".") >= c.Ndots {
+		return append([]string{host}, expanded...)
+	}
+	return append(expanded, host)
+}
+
+// SystemSource reads the host's resolver configuration from resolv.conf and
+// the resolver(5) directory, and reads it again when either changes, so
+// that VPNs and network switches take effect without a restart. The files
+// are checked at most every few seconds.
+type SystemSource struct {
+	Path string // resolv.conf
+	Dir  string // per-domain files; a missing directory means none
+
+	mu      sync.Mutex
+	every   time.Duration
+	checked time.Time
+	stamp   string // sizes and modification times of the files last read
+	cfg     SystemConfig
+	err     error
+}
+
+// NewSystemSource returns a SystemSource that reads path and dir.
+func NewSystemSource(path, dir string) *SystemSource {
+	return &SystemSource{Path: path, Dir: dir, every: _systemCheckInterval}
+}
+
+// Config returns the current configuration, re-reading the files if they
+// changed since they were last read.
+func (s *SystemSource) Config() (SystemConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if !s.checked.IsZero() && now.Sub(s.checked) < s.every {
+		return s.cfg, s.err
+	}
+	first := s.checked.IsZero()
+	s.checked = now
+
+	stamp := s.fingerprint()
+	if !first && stamp == s.stamp {
+		return s.cfg, s.err
+	}
+	s.stamp = stamp
+	s.cfg, s.err = s.load()
+	return s.cfg, s.err
+}
+
+// load reads and parses every file.
+func (s *SystemSource) load() (SystemConfig, error) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return SystemConfig{}, fmt.Errorf("reading system resolver config: %w", err)
+	}
+	defer f.Close()
+	conf, err := parseResolvConf(f)
+	if err != nil {
+		return SystemConfig{}, fmt.Errorf("parsing %s: %w", s.Path, err)
+	}
+
+	cfg := SystemConfig{
+		Servers: conf.servers,
+		Search:  conf.search,
+		Ndots:   conf.ndots,
+		Rotate:  conf.rotate,
+	}
+	if len(cfg.Servers) == 0 {
+		cfg.Servers = slices.Clone(_fallbackServers)
+	}
+
+	for _, path := range s.scopeFiles() {
+		sc, ok := readScope(path)
+		if ok {
+			cfg.Scopes = append(cfg.Scopes, sc)
+		}
+	}
+	slices.SortStableFunc(cfg.Scopes, func(a, b Scope) int {
+		if d := strings.Count(b.Domain, ".") - strings.Count(a.Domain, "."); d != 0 {
+			return d
+		}
+		return a.Order - b.Order
+	})
+	return cfg, nil
+}
+
+// readScope parses one resolver(5) file. Files that cannot be read or name
+// no server are skipped, as the system resolver does.
+func readScope(path string) (Scope, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Scope{}, false
+	}
+	defer f.Close()
+	conf, err := parseResolvConf(f)
+	if err != nil || len(conf.servers) == 0 {
+		return Scope{}, false
+	}
+	domain := conf.domain
+	if domain == "" {
+		domain = filepath.Base(path)
+	}
+	return Scope{
+		Domain:  strings.ToLower(strings.TrimSuffix(domain, ".")),
+		Servers: conf.servers,
+		Order:   conf.order,
+	}, true
+}
+
+// scopeFiles lists the regular files in s.Dir.
+func (s *SystemSource) scopeFiles() []string {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		paths = append(paths, filepath.Join(s.Dir, e.Name()))
+	}
+	return paths
+}
+
+// fingerprint summarizes the size and modification time of every file, so
+// that a change to any of them is noticed without reading them.
+func (s *SystemSource) fingerprint() string {
+	var b strings.Builder
+	for _, path := range append([]string{s.Path}, s.scopeFiles()...) {
+		fi, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(&b, "%s -\n", path)
+			continue
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", path, fi.Size(), fi.ModTime().UnixNano())
+	}
+	return b.String()
+}
+
+// resolvConf is what one resolv.conf(5) or resolver(5) file says.
+type resolvConf struct {
+	servers []string
+	search  []string
+	domain  string
+	ndots   int
+	rotate  bool
+	order   int // resolver(5) search_order
+}
+
+// parseResolvConf parses the resolv.conf(5) keywords void cares about, plus
+// the port, domain and search_order keywords of macOS resolver(5) files.
+// Unknown keywords and options are ignored.
+func parseResolvConf(r io.Reader) (resolvConf, error) {
+	var (
+		conf  = resolvConf{ndots: _defaultNdots}
+		hosts []string
+		port  = "53"
+	)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+		f := strings.Fields(line)
+		if len(f) < 2 {
+			continue
+		}
+		switch f[0] {
+		case "nameserver":
+			// A link-local address may carry a zone, as in "fe80::1%en0".
+			if ip, _, _ := strings.Cut(f[1], "%"); net.ParseIP(ip) == nil {
+				continue
+			}
+			hosts = append(hosts, f[1])
+		case "port":
+			if _, err := strconv.ParseUint(f[1], 10, 16); err == nil {
+				port = f[1]
+			}
+		case "domain":
+			// In resolv.conf the last of domain and search wins.
+			conf.domain = f[1]
+			conf.search = []string{strings.TrimSuffix(f[1], ".")}
+		case "search":
+			conf.search = conf.search[:0:0]
+			for _, d := range f[1:] {
+				conf.search = append(conf.search, strings.TrimSuffix(d, "."))
+			}
+		case "search_order":
+			conf.order, _ = strconv.Atoi(f[1])
+		case "options":
+			for _, o := range f[1:] {
+				switch {
+				case o == "rotate":
+					conf.rotate = true
+				case strings.HasPrefix(o, "ndots:"):
+					if n, err := strconv.Atoi(o[len("ndots:"):]); err == nil && n >= 0 {
+						conf.ndots = min(n, 15) // the limit glibc applies
+					}
+				}
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return resolvConf{}, err
+	}
+	for _, h := range hosts {
+		conf.servers = append(conf.servers, net.JoinHostPort(h, port))
+	}
+	return conf, nil
+}
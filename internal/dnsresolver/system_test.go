@@ -0,0 +1,195 @@
+package dnsresolver
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type SystemTestSuite struct {
+	suite.Suite
+	dir    string
+	source *SystemSource
+}
+
+func (s *SystemTestSuite) SetupTest() {
+	s.dir = s.T().TempDir()
+	s.Require().NoError(os.Mkdir(filepath.Join(s.dir, "resolver"), 0o755))
+	s.source = NewSystemSource(filepath.Join(s.dir, "resolv.conf"), filepath.Join(s.dir, "resolver"))
+	s.source.every = 0
+}
+
+func (s *SystemTestSuite) write(name, content string) {
+	path := filepath.Join(s.dir, name)
+	s.Require().NoError(os.WriteFile(path, []byte(content), 0o644))
+	// Make sure the change is visible even on coarse file system clocks.
+	later := time.Now().Add(time.Duration(len(content)) * time.Second)
+	s.Require().NoError(os.Chtimes(path, later, later))
+}
+
+func (s *SystemTestSuite) TestParse() {
+	s.write("resolv.conf", strings.Join([]string{
+		"# generated by NetworkManager",
+		"domain old.example",
+		"search corp.example. lab.example ; trailing comment",
+		"nameserver 10.0.0.53",
+		"nameserver fe80::1%en0",
+		"nameserver not-an-ip",
+		"options ndots:2 rotate timeout:1",
+	}, "\n"))
+
+	cfg, err := s.source.Config()
+	s.Require().NoError(err)
+	s.Equal([]string{"10.0.0.53:53", "[fe80::1%en0]:53"}, cfg.Servers)
+	s.Equal([]string{"corp.example", "lab.example"}, cfg.Search)
+	s.Equal(2, cfg.Ndots)
+	s.True(cfg.Rotate)
+}
+
+func (s *SystemTestSuite) TestDefaults() {
+	s.write("resolv.conf", "search corp.example\n")
+
+	cfg, err := s.source.Config()
+	s.Require().NoError(err)
+	s.Equal(_fallbackServers, cfg.Servers, "no nameserver means the local host")
+	s.Equal(1, cfg.Ndots)
+	s.False(cfg.Rotate)
+}
+
+func (s *SystemTestSuite) TestMissingFile() {
+	_, err := s.source.Config()
+	s.ErrorIs(err, os.ErrNotExist)
+}
+
+func (s *SystemTestSuite) TestNames() {
+	cfg := SystemConfig{Search: []string{"corp.example"}, Ndots: 1}
+	s.Equal([]string{"intranet.corp.example", "intranet"}, cfg.Names("intranet"))
+	s.Equal([]string{"example.com", "example.com.corp.example"}, cfg.Names("example.com"))
+	s.Equal([]string{"example.com"}, cfg.Names("example.com."))
+
+	cfg.Ndots = 2
+	s.Equal([]string{"example.com.corp.example", "example.com"}, cfg.Names("example.com"))
+}
+
+func (s *SystemTestSuite) TestScopes() {
+	s.write("resolv.conf", "nameserver 10.0.0.53\n")
+	s.write("resolver/corp.example", "nameserver 10.1.0.53\nport 5353\n")
+	s.write("resolver/eng.corp.example", "nameserver 10.2.0.53\n")
+	s.write("resolver/vpn", "domain internal.example\nnameserver 10.3.0.53\n")
+	s.write("resolver/empty.example", "search_order 1\n")
+
+	cfg, err := s.source.Config()
+	s.Require().NoError(err)
+	s.Require().Len(cfg.Scopes, 3, "a file without a nameserver is skipped")
+	s.Equal([]string{"10.2.0.53:53"}, cfg.ServersFor("build.eng.corp.example"))
+	s.Equal([]string{"10.1.0.53:5353"}, cfg.ServersFor("wiki.corp.example."))
+	s.Equal([]string{"10.1.0.53:5353"}, cfg.ServersFor("CORP.example"))
+	s.Equal([]string{"10.3.0.53:53"}, cfg.ServersFor("git.internal.example"))
+	s.Equal([]string{"10.0.0.53:53"}, cfg.ServersFor("notcorp.example"))
+}
+
+func (s *SystemTestSuite) TestReloadsOnChange() {
+	s.write("resolv.conf", "nameserver 10.0.0.53\n")
+	cfg, err := s.source.Config()
+	s.Require().NoError(err)
+	s.Equal([]string{"10.0.0.53:53"}, cfg.Servers)
+
+	s.write("resolv.conf", "nameserver 192.168.1.1\n")
+	s.write("resolver/corp.example", "nameserver 10.1.0.53\n")
+	cfg, err = s.source.Config()
+	s.Require().NoError(err)
+	s.Equal([]string{"192.168.1.1:53"}, cfg.Servers)
+	s.Len(cfg.Scopes, 1)
+
+	s.Require().NoError(os.Remove(filepath.Join(s.dir, "resolver", "corp.example")))
+	cfg, err = s.source.Config()
+	s.Require().NoError(err)
+	s.Empty(cfg.Scopes)
+}
+
+func (s *SystemTestSuite) TestChecksAtMostEveryInterval() {
+	s.source.every = time.Hour
+	s.write("resolv.conf", "nameserver 10.0.0.53\n")
+	_, err := s.source.Config()
+	s.Require().NoError(err)
+
+	s.write("resolv.conf", "nameserver 192.168.1.1\n")
+	cfg, err := s.source.Config()
+	s.Require().NoError(err)
+	s.Equal([]string{"10.0.0.53:53"}, cfg.Servers, "files are not checked again so soon")
+}
+
+func (s *SystemTestSuite) TestClient() {
+	s.write("resolv.conf", "search corp.example\nnameserver 10.0.0.53\nnameserver 10.0.0.54\n")
+	s.write("resolver/lab.example", "nameserver 10.9.0.53\n")
+
+	client := new(mockClient)
+	r := New(5*time.Second, WithResolvers([]string{System}))
+	r.Client = client
+	r.System = s.source
+
+	a := func(name, ip string) dns.RR {
+		return &dns.A{Hdr: dns.RR_Header{Name: dns.Fqdn(name), Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.ParseIP(ip)}
+	}
+	query := func(name string, qtype uint16) any {
+		return mock.MatchedBy(func(m *dns.Msg) bool {
+			return m.Question[0].Name == name && m.Question[0].Qtype == qtype
+		})
+	}
+	// The first server fails and the second is asked next.
+	client.On("ExchangeContext", mock.Anything, query("intranet.corp.example.", dns.TypeA), "10.0.0.53:53").
+		Return(nil, time.Duration(0), context.DeadlineExceeded)
+	client.On("ExchangeContext", mock.Anything, query("intranet.corp.example.", dns.TypeA), "10.0.0.54:53").
+		Return(&dns.Msg{Answer: []dns.RR{a("intranet.corp.example", "10.4.0.1")}}, time.Duration(0), nil)
+	client.On("ExchangeContext", mock.Anything, query("intranet.corp.example.", dns.TypeAAAA), mock.Anything).
+		Return(&dns.Msg{}, time.Duration(0), nil)
+	r.Retries = 1
+
+	res, err := r.Resolve(context.Background(), "intranet")
+	s.Require().NoError(err)
+	s.Require().Len(res.Addrs, 1)
+	s.Equal("10.4.0.1", res.Addrs[0].IP.String())
+
+	client.ExpectedCalls = client.ExpectedCalls[:0]
+	client.On("ExchangeContext", mock.Anything, mock.Anything, "10.9.0.53:53").
+		Return(&dns.Msg{Answer: []dns.RR{a("ci.lab.example", "10.9.1.1")}}, time.Duration(0), nil)
+	res, err = r.Resolve(context.Background(), "ci.lab.example")
+	s.Require().NoError(err)
+	s.Equal("10.9.1.1", res.Addrs[0].IP.String(), "scoped names go to the scope's server")
+}
+
+func (s *SystemTestSuite) TestRouteToSystem() {
+	s.write("resolv.conf", "search corp.example\nnameserver 10.0.0.53\n")
+
+	client := new(mockClient)
+	r := New(5*time.Second, WithResolvers([]string{"8.8.8.8:53"}),
+		WithRoutes([]Route{{Suffix: "corp.example", Upstreams: []string{System}}}))
+	r.Client = client
+	r.System = s.source
+
+	client.On("ExchangeContext", mock.Anything, mock.MatchedBy(func(m *dns.Msg) bool {
+		return m.Question[0].Name == "intranet.corp.example." && m.Question[0].Qtype == dns.TypeA
+	}), "10.0.0.53:53").Return(&dns.Msg{Answer: []dns.RR{&dns.A{
+		Hdr: dns.RR_Header{Name: "intranet.corp.example.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+		A:   net.ParseIP("10.4.0.1"),
+	}}}, time.Duration(0), nil)
+	client.On("ExchangeContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeNameError}}, time.Duration(0), nil)
+
+	res, err := r.Resolve(context.Background(), "intranet")
+	s.Require().NoError(err, "the search list applies when only a route uses the system resolver")
+	s.Require().Len(res.Addrs, 1)
+	s.Equal("10.4.0.1", res.Addrs[0].IP.String())
+}
+
+func TestSystemTestSuite(t *testing.T) {
+	suite.Run(t, new(SystemTestSuite))
+}
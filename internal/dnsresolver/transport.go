@@ -0,0 +1,109 @@
+package dnsresolver
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// _dnsPort is the port plain DNS upstreams listen on unless told otherwise.
+const _dnsPort = "53"
+
+// ErrBadUpstream is returned by ValidateUpstream for an unusable address.
+var ErrBadUpstream = errors.New("invalid upstream address")
+
+var _ Exchanger = (*Transport)(nil)
+
+// Transport is an Exchanger that picks the protocol for each resolver
+// address: "https://…" URLs go over DNS-over-HTTPS, "tls://…" addresses
+// over DNS-over-TLS, "tcp://host[:port]" over DNS on TCP, and
+// "udp://host[:port]" or a bare "host:port", such as "1.1.1.1:53", over
+// plain DNS.
+type Transport struct {
+	Plain Exchanger
+	TCP   Exchanger
+	HTTPS Exchanger
+	TLS   Exchanger
+}
+
+// NewTransport returns a Transport whose exchanges time out after timeout.
+func NewTransport(timeout time.Duration) *Transport {
+	return &Transport{
+		Plain: &dns.Client{Timeout: timeout},
+		TCP:   &dns.Client{Net: "tcp", Timeout: timeout},
+		HTTPS: NewDoH(timeout),
+		TLS:   NewDoT(timeout),
+	}
+}
+
+// SetRootCAs makes the encrypted transports verify servers against roots
+// instead of the system roots.
+func (t *Transport) SetRootCAs(roots *x509.CertPool) {
+	if d, ok := t.HTTPS.(*DoH); ok {
+		d.SetRootCAs(roots)
+	}
+	if d, ok := t.TLS.(*DoT); ok {
+		d.RootCAs = roots
+	}
+}
+
+// ExchangeContext sends m to addr using the protocol addr calls for.
+func (t *Transport) ExchangeContext(ctx context.Context, m *dns.Msg, addr string) (*dns.Msg, time.Duration, error) {
+	switch {
+	case strings.HasPrefix(addr, "https://"):
+		return t.HTTPS.ExchangeContext(ctx, m, addr)
+	case strings.HasPrefix(addr, "tls://"):
+		return t.TLS.ExchangeContext(ctx, m, addr)
+	case strings.HasPrefix(addr, "tcp://"):
+		return t.TCP.ExchangeContext(ctx, m, withDNSPort(strings.TrimPrefix(addr, "tcp://")))
+	case strings.HasPrefix(addr, "udp://"):
+		return t.Plain.ExchangeContext(ctx, m, withDNSPort(strings.TrimPrefix(addr, "udp://")))
+	}
+	return t.Plain.ExchangeContext(ctx, m, addr)
+}
+
+// ValidateUpstream checks that addr is System or an address Transport can
+// query.
+func ValidateUpstream(addr string) error {
+	scheme, rest, found := strings.Cut(addr, "://")
+	switch {
+	case addr == System:
+		return nil
+	case !found:
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("%w %q: want host:port or a scheme such as udp://", ErrBadUpstream, addr)
+		}
+		return nil
+	case scheme == "udp" || scheme == "tcp":
+		if host, _, _ := net.SplitHostPort(withDNSPort(rest)); host == "" {
+			return fmt.Errorf("%w %q: no host", ErrBadUpstream, addr)
+		}
+		return nil
+	case scheme == "tls":
+		if _, _, err := parseDoTAddr(addr); err != nil {
+			return fmt.Errorf("%w: %w", ErrBadUpstream, err)
+		}
+		return nil
+	case scheme == "https":
+		if u, err := url.Parse(addr); err != nil || u.Host == "" {
+			return fmt.Errorf("%w %q: not a URL with a host", ErrBadUpstream, addr)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w %q: unknown scheme %q", ErrBadUpstream, addr, scheme)
+}
+
+// withDNSPort returns hostport with the DNS port added if it has none.
+func withDNSPort(hostport string) string {
+	if _, _, err := net.SplitHostPort(hostport); err == nil {
+		return hostport
+	}
+	return net.JoinHostPort(strings.Trim(hostport, "[]"), _dnsPort)
+}
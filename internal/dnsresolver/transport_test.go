@@ -0,0 +1,59 @@
+package dnsresolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type TransportTestSuite struct {
+	suite.Suite
+}
+
+func (s *TransportTestSuite) TestDispatch() {
+	plain, tcp := new(mockClient), new(mockClient)
+	t := &Transport{Plain: plain, TCP: tcp}
+	for _, c := range []*mockClient{plain, tcp} {
+		c.On("ExchangeContext", mock.Anything, mock.Anything, mock.Anything).
+			Return(new(dns.Msg), time.Duration(0), nil)
+	}
+
+	for _, addr := range []string{"udp://192.0.2.53", "tcp://[2001:db8::53]:5353", "192.0.2.54:53"} {
+		_, _, err := t.ExchangeContext(context.Background(), new(dns.Msg), addr)
+		s.Require().NoError(err)
+	}
+	plain.AssertCalled(s.T(), "ExchangeContext", mock.Anything, mock.Anything, "192.0.2.53:53")
+	plain.AssertCalled(s.T(), "ExchangeContext", mock.Anything, mock.Anything, "192.0.2.54:53")
+	tcp.AssertCalled(s.T(), "ExchangeContext", mock.Anything, mock.Anything, "[2001:db8::53]:5353")
+}
+
+func (s *TransportTestSuite) TestValidateUpstream() {
+	for _, addr := range []string{
+		System,
+		"1.1.1.1:53",
+		"udp://1.1.1.1",
+		"tcp://[2606:4700:4700::1111]:53",
+		"tls://1.1.1.1#cloudflare-dns.com",
+		"https://cloudflare-dns.com/dns-query",
+	} {
+		s.NoError(ValidateUpstream(addr), addr)
+	}
+	for _, addr := range []string{
+		"",
+		"1.1.1.1",
+		"udp://",
+		"tls://#cloudflare-dns.com",
+		"https:///dns-query",
+		"quic://dns.adguard.com",
+	} {
+		s.ErrorIs(ValidateUpstream(addr), ErrBadUpstream, addr)
+	}
+}
+
+func TestTransportTestSuite(t *testing.T) {
+	suite.Run(t, new(TransportTestSuite))
+}
@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"context"
+	"time"
+
+	"github.com/lc/void/internal/audit"
+	"github.com/lc/void/internal/events"
+)
+
+// AuditLog durably records audited engine events.
+type AuditLog interface {
+	Record(events.Event) error
+	Since(time.Time) ([]events.Event, error)
+}
+
+// WithAuditLog records every rule change and pf sync to l, along with the
+// actor and reason carried by the request context (see package audit).
+func WithAuditLog(l AuditLog) Opt {
+	return func(e *Engine) {
+		e.audit = l
+	}
+}
+
+// Audit returns the audit log entries recorded at or after since.
+func (e *Engine) Audit(since time.Time) ([]events.Event, error) {
+	if e.audit == nil {
+		return nil, ErrAuditDisabled
+	}
+	return e.audit.Since(since)
+}
+
+// origin is who asked for a command and why. Internal commands (expiry,
+// refresh, schedules) have none.
+type origin struct {
+	actor  *events.Actor
+	reason string
+}
+
+func originOf(ctx context.Context) origin {
+	var o origin
+	if a, ok := audit.ActorFrom(ctx); ok {
+		o.actor = &a
+	}
+	o.reason = audit.ReasonFrom(ctx)
+	return o
+}
+
+// originCmd carries the origin of a command queued by submit. runLoop
+// unwraps it and attributes the command's events to the origin.
+type originCmd struct {
+	command
+	origin origin
+}
@@ -11,22 +11,67 @@ import (
 	"fmt"
 	"io/fs"
 	"net"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"go.uber.org/multierr"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/lc/void/internal/dnsresolver"
+	"github.com/lc/void/internal/events"
+	"github.com/lc/void/internal/focus"
+	"github.com/lc/void/internal/group"
 	"github.com/lc/void/internal/log"
 	"github.com/lc/void/internal/pf"
 	"github.com/lc/void/internal/rules"
+	"github.com/lc/void/internal/schedule"
+)
+
+var (
+	// ErrRuleNotFound is returned when a command references an unknown rule.
+	ErrRuleNotFound = rules.ErrNotFound
+	// ErrAmbiguousRef is returned when a rule ID prefix matches several rules.
+	ErrAmbiguousRef = rules.ErrAmbiguous
+	// ErrLocked is returned when removing or shortening a rule before its lock deadline.
+	ErrLocked = rules.ErrLocked
+	// ErrWouldShorten is returned when an extend-only expiry change would end a rule sooner.
+	ErrWouldShorten = rules.ErrWouldShorten
+	// ErrInvalidExpiry is returned for an expiry change that makes no sense for the rule.
+	ErrInvalidExpiry = errors.New("invalid expiry change")
+	// ErrGroupNotFound is returned when a command references an unknown group.
+	ErrGroupNotFound = errors.New("group not found")
+	// ErrSessionActive is returned when starting a focus session while one runs.
+	ErrSessionActive = errors.New("a focus session is already running")
+	// ErrNoSession is returned when no focus session is running.
+	ErrNoSession = errors.New("no focus session running")
+	// ErrAuditDisabled is returned when reading the audit log of an engine without one.
+	ErrAuditDisabled = errors.New("audit log not enabled")
+	// ErrResolve is returned when a domain cannot be resolved to any IPs.
+	ErrResolve = errors.New("dns resolution failed")
+	// ErrNoSuchDomain is an ErrResolve for a domain whose every name is
+	// known not to exist.
+	ErrNoSuchDomain = fmt.Errorf("%w: no such domain", ErrResolve)
+	// ErrNoCache is returned when flushing the DNS cache of an engine without one.
+	ErrNoCache = errors.New("dns cache not enabled")
 )
 
 const (
-	// How often to check for expired rules and potentially refresh DNS.
-	// A shorter interval ensures timely expiry but increases load.
-	_tickerInterval = 30 * time.Second
+	// Longest the runLoop sleeps while any deadline is pending. Timers run
+	// on a monotonic clock that may pause while the machine sleeps, so
+	// wall-clock deadlines are re-checked at least this often.
+	_maxSleep = time.Minute
+	// Delay before retrying time-driven work (DNS refresh, schedule or
+	// focus activation) that failed.
+	_retryInterval = 30 * time.Second
+	// Bounds on the back-off between attempts to resolve a pending rule,
+	// which doubles with every failure.
+	_pendingMinBackoff = 10 * time.Second
+	_pendingMaxBackoff = 10 * time.Minute
+	// Shortest refresh interval derived from a DNS TTL, by default.
+	_defaultMinRefresh = 30 * time.Second
 	// Small buffer for commands to avoid blocking senders momentarily.
 	_commandBufferSize = 10
 )
@@ -37,26 +82,95 @@ type Engine struct {
 	pfMgr      pf.Manager
 	resolver   dnsresolver.Clienter
 	dnsRefresh time.Duration // How often rule's DNS should be refreshed/re-resolved.
+	minRefresh time.Duration // Bounds on a refresh interval derived from DNS TTLs.
+	maxRefresh time.Duration
+
+	wildcardPrefixes []string // Subdomain labels probed for new wildcard rules.
+
+	schedMu    sync.RWMutex                 // protects schedules; written only by runLoop
+	schedules  map[string]schedule.Schedule // id -> recurring block window
+	schedStore ScheduleStore                // optional persistence for schedules
+
+	groupMu    sync.RWMutex           // protects groups; written only by runLoop
+	groups     map[string]group.Group // name -> group definition
+	groupStore GroupStore             // optional persistence for groups
+
+	sessMu    sync.RWMutex   // protects session; written only by runLoop
+	session   *focus.Session // current focus session, if any
+	sessStore SessionStore   // optional persistence for the session
+
+	retryAt time.Time // when to retry failed time-driven work; written only by runLoop
+
+	bus    *events.Bus // Publishes what the engine does; see Subscribe
+	audit  AuditLog    // optional record of audited events; see WithAuditLog
+	origin origin      // who asked for the command runLoop is handling
 
 	cmdChan  chan command // Commands are processed serially by runLoop
 	wg       sync.WaitGroup
 	cancelFn context.CancelFunc // Cancels the context passed to Run
 }
 
+// Opt is a function option for configuring the Engine.
+type Opt func(e *Engine)
+
 // New creates a new Engine instance.
 // dnsRefreshInterval specifies how often DNS records for existing rules should be re-resolved.
-func New(pfMgr pf.Manager, resolver dnsresolver.Clienter, dnsRefreshInterval time.Duration) *Engine {
-	return &Engine{
-		store:      rules.NewStore(),
-		pfMgr:      pfMgr,
-		resolver:   resolver,
-		dnsRefresh: dnsRefreshInterval,
-		cmdChan:    make(chan command, _commandBufferSize),
+func New(pfMgr pf.Manager, resolver dnsresolver.Clienter, dnsRefreshInterval time.Duration, opts ...Opt) *Engine {
+	e := &Engine{
+		store:            rules.NewStore(),
+		pfMgr:            pfMgr,
+		resolver:         resolver,
+		dnsRefresh:       dnsRefreshInterval,
+		minRefresh:       _defaultMinRefresh,
+		maxRefresh:       dnsRefreshInterval,
+		wildcardPrefixes: _defaultWildcardPrefixes,
+		schedules:        make(map[string]schedule.Schedule),
+		groups:           make(map[string]group.Group),
+		bus:              events.NewBus(),
+		cmdChan:          make(chan command, _commandBufferSize),
+	}
+	for _, o := range opts {
+		o(e)
+	}
+	return e
+}
+
+// WithStore replaces the default in-memory rule store, e.g. with a
+// rules.FileStore that keeps state across restarts.
+func WithStore(s rules.Store) Opt {
+	return func(e *Engine) {
+		e.store = s
+	}
+}
+
+// WithRefreshBounds bounds how often a rule is re-resolved based on the
+// TTL of its DNS records. Zero keeps a bound's default: 30 seconds for
+// min and the DNS refresh interval given to New for max. Rules whose TTL
+// is unknown are refreshed at the DNS refresh interval.
+func WithRefreshBounds(minRefresh, maxRefresh time.Duration) Opt {
+	return func(e *Engine) {
+		if minRefresh > 0 {
+			e.minRefresh = minRefresh
+		}
+		if maxRefresh > 0 {
+			e.maxRefresh = maxRefresh
+		}
+	}
+}
+
+// WithWildcardPrefixes sets the subdomain labels (e.g. "www", "m") that are
+// resolved alongside the apex when a "*.zone" rule is created.
+// An empty list keeps the built-in defaults.
+func WithWildcardPrefixes(prefixes []string) Opt {
+	return func(e *Engine) {
+		if len(prefixes) > 0 {
+			e.wildcardPrefixes = prefixes
+		}
 	}
 }
 
 // Run starts the engine's background processing goroutines.
-// It loads initial rules from PF and starts the ticker and command loop.
+// It loads the initial rules and starts the command loop.
 // The provided context controls the lifetime of these goroutines.
 func (e *Engine) Run(ctx context.Context) {
 	// Create an internal context that we can cancel on Close()
@@ -67,10 +181,18 @@ func (e *Engine) Run(ctx context.Context) {
 	if err := e.loadInitialRules(runCtx); err != nil {
 		log.Warnf("engine: failed to load initial rules: %v", err)
 	}
+	if err := e.loadSchedules(); err != nil {
+		log.Warnf("engine: failed to load schedules: %v", err)
+	}
+	if err := e.loadGroups(); err != nil {
+		log.Warnf("engine: failed to load groups: %v", err)
+	}
+	if err := e.loadSession(); err != nil {
+		log.Warnf("engine: failed to load focus session: %v", err)
+	}
 
-	e.wg.Add(2)
+	e.wg.Add(1)
 	go e.runLoop(runCtx)
-	go e.runTicker(runCtx)
 
 	log.Info("engine: started")
 }
@@ -84,38 +206,68 @@ func (e *Engine) Close() {
 	log.Info("engine: stopped")
 }
 
-// BlockDomain initiates the process of blocking a domain.
-// It resolves the domain to IPs and adds a rule to the store.
-// The actual PF update happens asynchronously within the engine's runLoop.
-func (e *Engine) BlockDomain(ctx context.Context, domain string, ttl time.Duration) error {
+// BlockDomain resolves domain and adds (or merges) a rule for it.
+// It waits for the engine to process the command, including the PF sync,
+// and returns the ID of the rule that now covers the domain. When the
+// store merges the request into an existing rule, that rule's ID is returned.
+// A non-zero lock prevents the rule from being removed or shortened for
+// that long. A non-empty strategy overrides how the rule's names are
+// resolved, now and on every refresh.
+func (e *Engine) BlockDomain(ctx context.Context, domain string, ttl, lock time.Duration, strategy dnsresolver.Strategy) (string, error) {
+	reply := make(chan result, 1)
 	cmd := blockCmd{
-		domain: domain,
-		ttl:    ttl,
+		domain:   domain,
+		ttl:      ttl,
+		lock:     lock,
+		strategy: strategy,
+		reply:    reply,
 	}
-
-	select {
-	case e.cmdChan <- cmd:
-		return nil // Command successfully queued
-	case <-ctx.Done():
-		return ctx.Err() // Request context cancelled
+	res, err := e.submit(ctx, cmd, reply)
+	if err != nil {
+		return "", err
 	}
+	return res.id, res.err
 }
 
-// UnblockDomain initiates the removal of a rule by its ID.
-// The actual PF update happens asynchronously within the engine's runLoop.
-func (e *Engine) UnblockDomain(id string) {
-	// Use a background context for sending the command, as the API handler
-	// might return before the command is processed. The engine's lifecycle
-	// context (runCtx) ensures processing stops eventually on shutdown.
-	ctx := context.Background()
+// UnblockResult reports the outcome of unblocking a single target.
+type UnblockResult struct {
+	Target string     // domain, rule ID or ID prefix as given by the caller
+	Rule   rules.Rule // the removed rule; zero if Err is set
+	Err    error      // ErrRuleNotFound, ErrAmbiguousRef, …
+}
 
-	cmd := unblockCmd{id: id}
+// Unblock removes the rules referenced by targets, each of which may be a
+// domain, a rule ID or a unique rule ID prefix. All removals are applied
+// with a single PF sync. Per-target failures are reported in the results;
+// the returned error is reserved for failures affecting the whole batch.
+func (e *Engine) Unblock(ctx context.Context, targets []string) ([]UnblockResult, error) {
+	reply := make(chan result, 1)
+	res, err := e.submit(ctx, unblockCmd{targets: targets, reply: reply}, reply)
+	if err != nil {
+		return nil, err
+	}
+	return res.unblocked, res.err
+}
 
+// submit queues cmd for the runLoop and waits for its reply.
+// The reply channel must be buffered so the runLoop never blocks on
+// a caller that has already gone away.
+func (e *Engine) submit(ctx context.Context, cmd command, reply <-chan result) (result, error) {
+	if o := originOf(ctx); o != (origin{}) {
+		cmd = originCmd{command: cmd, origin: o}
+	}
 	select {
 	case e.cmdChan <- cmd:
-		// Command queued
-	case <-ctx.Done(): // unlikely, but check
-		log.Warnf("engine: failed to queue unblock command for %s: %v", id, ctx.Err())
+	case <-ctx.Done():
+		return result{}, ctx.Err() // Request context cancelled
+	}
+
+	select {
+	case res := <-reply:
+		return res, nil
+	case <-ctx.Done():
+		// The command may still be applied; the caller just stops waiting.
+		return result{}, ctx.Err()
 	}
 }
 
@@ -124,138 +276,383 @@ func (e *Engine) Snapshot() []rules.Rule {
 	return e.store.Snapshot()
 }
 
-// runLoop is the central processing loop. It serializes all state changes.
-func (e *Engine) runLoop(ctx context.Context) {
-	defer e.wg.Done()
-	defer func() {
-		// Drain command channel on exit? Maybe not necessary if Close waits.
-		log.Warnf("engine: runLoop stopping")
-	}()
+// Counters returns pf's packet, byte and state counters per rule ID. It
+// reads pf directly and does not go through the runLoop.
+func (e *Engine) Counters(ctx context.Context) (map[string]pf.Counters, error) {
+	return e.pfMgr.Counters(ctx)
+}
 
-	log.Info("engine: runLoop starting")
+// ResolverHealth returns the health of the upstream DNS resolvers, or nil
+// if the engine's resolver does not track it.
+func (e *Engine) ResolverHealth() []dnsresolver.UpstreamHealth {
+	if h, ok := e.resolver.(interface {
+		Health() []dnsresolver.UpstreamHealth
+	}); ok {
+		return h.Health()
+	}
+	return nil
+}
 
-	for {
-		select {
-		case cmd := <-e.cmdChan:
-			// Process commands serially
-			needsSync := false
-			var err error
-			switch c := cmd.(type) {
-			case blockCmd:
-				needsSync, err = e.handleBlock(ctx, c)
-				if err != nil {
-					log.Warnf("engine: error handling block command for %q: %v", c.domain, err)
-					// TODO: Propagate error back via c.errChan if added
-				}
-			case unblockCmd:
-				needsSync, err = e.handleUnblock(ctx, c)
-				if err != nil {
-					log.Warnf("engine: error handling unblock command for %q: %v", c.id, err)
-					// TODO: Propagate error back via c.errChan if added
-				}
-			case refreshExpireCmd:
-				needsSync, err = e.handleRefreshExpire(ctx)
-				if err != nil {
-					log.Warnf("engine: error handling refresh/expire: %v", err)
-				}
-			default:
-				log.Warnf("engine: received unknown command type: %T", cmd)
-			}
+// FlushDNSCache drops every DNS answer the resolver has cached, so that
+// the next lookups and refreshes query the upstreams, and returns how many
+// there were.
+func (e *Engine) FlushDNSCache() (int, error) {
+	c, ok := e.resolver.(interface{ Flush() int })
+	if !ok {
+		return 0, ErrNoCache
+	}
+	n := c.Flush()
+	log.Infof("engine: flushed %d cached DNS answers", n)
+	return n, nil
+}
 
-			// Sync PF if any command resulted in a state change
-			if needsSync {
-				if syncErr := e.syncPF(ctx); syncErr != nil {
-					log.Infof("engine: failed to sync pf: %v", syncErr)
-				}
-			}
+// Rule returns the rule ref names: a rule ID, a domain or a unique rule ID
+// prefix. It reads the store directly and does not go through the runLoop.
+func (e *Engine) Rule(ref string) (rules.Rule, error) {
+	return e.store.Lookup(ref)
+}
 
-		case <-ctx.Done():
-			return
-		}
+// Route returns the routing suffix that r's names match and the upstreams
+// they are resolved with. suffix is empty when no route applies, and both
+// are empty if the engine's resolver does not route.
+func (e *Engine) Route(r rules.Rule) (suffix string, upstreams []string) {
+	rt, ok := e.resolver.(interface {
+		UpstreamsFor(host string) (string, []string)
+	})
+	if !ok {
+		return "", nil
+	}
+	host := r.Domain
+	if r.Wildcard() {
+		host = r.Zone()
 	}
+	return rt.UpstreamsFor(host)
 }
 
-// runTicker periodically sends refresh/expire commands to the runLoop.
-func (e *Engine) runTicker(ctx context.Context) {
+// runLoop is the central processing loop. It serializes all state changes
+// and, between commands, sleeps until time-driven work is next due (see
+// nextWake), so rules expire on time and an idle daemon stays asleep.
+func (e *Engine) runLoop(ctx context.Context) {
 	defer e.wg.Done()
-	defer log.Info("engine: runTicker stopping")
+	defer log.Warnf("engine: runLoop stopping")
 
-	log.Info("engine: runTicker starting")
-	ticker := time.NewTicker(_tickerInterval)
-	defer ticker.Stop()
+	log.Info("engine: runLoop starting")
 
+	wake := time.NewTimer(0) // catch up on anything that fell due while stopped
+	defer wake.Stop()
 	for {
+		var cmd command
 		select {
-		case <-ticker.C:
-			select {
-			case e.cmdChan <- refreshExpireCmd{}:
-			case <-ctx.Done():
-				return
-			default:
-				// Should not happen with a buffered channel unless severely backed up
-				log.Info("engine: warning: command channel full, skipping ticker cycle")
-			}
+		case cmd = <-e.cmdChan:
+		case <-wake.C:
+			cmd = refreshExpireCmd{}
 		case <-ctx.Done():
 			return
 		}
+		e.handle(ctx, cmd)
+		e.rearm(wake, time.Now())
+	}
+}
+
+// handle processes one command, persists the result, syncs PF if needed
+// and responds. Runs only within runLoop.
+func (e *Engine) handle(ctx context.Context, cmd command) {
+	var (
+		res       result
+		needsSync bool
+	)
+	inner := cmd
+	if oc, ok := cmd.(originCmd); ok {
+		inner, e.origin = oc.command, oc.origin
+	}
+	switch c := inner.(type) {
+	case blockCmd:
+		res.id, needsSync, res.err = e.handleBlock(ctx, c)
+		if res.err != nil {
+			log.Warnf("engine: error handling block command for %q: %v", c.domain, res.err)
+		}
+	case unblockCmd:
+		res.unblocked, needsSync, res.err = e.handleUnblock(ctx, c)
+	case updateExpiryCmd:
+		res.rule, needsSync, res.err = e.handleUpdateExpiry(ctx, c)
+	case blockGroupCmd:
+		res.ids, needsSync, res.err = e.handleBlockGroup(ctx, c)
+		if res.err != nil {
+			log.Warnf("engine: error handling block command for group %q: %v", c.name, res.err)
+		}
+	case defineGroupCmd:
+		res.err = e.handleDefineGroup(c)
+	case deleteGroupCmd:
+		res.err = e.handleDeleteGroup(c)
+	case startSessionCmd:
+		res.sess, needsSync, res.err = e.handleStartSession(ctx, c)
+	case stopSessionCmd:
+		res.sess, needsSync, res.err = e.handleStopSession(ctx)
+	case addScheduleCmd:
+		needsSync, res.err = e.handleAddSchedule(ctx, c)
+		if res.err != nil {
+			log.Warnf("engine: error adding schedule for %q: %v", c.sched.Domain, res.err)
+		}
+	case removeScheduleCmd:
+		res.sched, needsSync, res.err = e.handleRemoveSchedule(ctx, c)
+	case refreshExpireCmd:
+		var err error
+		needsSync, err = e.handleRefreshExpire(ctx)
+		if err != nil {
+			log.Warnf("engine: error handling refresh/expire: %v", err)
+		}
+		failed := err != nil
+		activated, err := e.activateSchedules(ctx, time.Now())
+		if err != nil {
+			log.Warnf("engine: error activating schedules: %v", err)
+		}
+		failed = failed || err != nil
+		needsSync = needsSync || activated
+		advanced, err := e.advanceSession(ctx, time.Now())
+		if err != nil {
+			log.Warnf("engine: error advancing focus session: %v", err)
+		}
+		failed = failed || err != nil
+		needsSync = needsSync || advanced
+		e.retryAt = time.Time{}
+		if failed {
+			e.retryLater()
+		}
+	default:
+		log.Warnf("engine: received unknown command type: %T", inner)
+	}
+
+	// Persist state before rendering it, so the state file never
+	// lags behind the anchor.
+	if err := e.store.Flush(); err != nil {
+		log.Warnf("engine: failed to persist rule state: %v", err)
+		res.err = multierr.Append(res.err, err)
+	}
+	// Sync PF if any command resulted in a state change
+	if needsSync {
+		if syncErr := e.syncPF(ctx); syncErr != nil {
+			log.Infof("engine: failed to sync pf: %v", syncErr)
+			res.err = multierr.Append(res.err, syncErr)
+		}
 	}
+	e.origin = origin{}
+	cmd.respond(res)
 }
 
 // --- Command Handlers (run only within runLoop) ---
-func (e *Engine) handleBlock(ctx context.Context, cmd blockCmd) (needsSync bool, err error) {
-	log.Infof("engine: handling block request for %q (ttl: %v)", cmd.domain, cmd.ttl)
+func (e *Engine) handleBlock(ctx context.Context, cmd blockCmd) (id string, needsSync bool, err error) {
+	log.Infof("engine: handling block request for %q (ttl: %v, lock: %v)", cmd.domain, cmd.ttl, cmd.lock)
+	return e.block(ctx, cmd.domain, blockOpts{ttl: cmd.ttl, lock: cmd.lock, now: time.Now(), strategy: cmd.strategy})
+}
+
+// blockOpts describes the rule block creates.
+type blockOpts struct {
+	ttl   time.Duration // 0 = permanent
+	lock  time.Duration // 0 = not locked
+	now   time.Time     // shared by rules created together so they expire together
+	group string        // group the rule is blocked through, if any
+
+	strategy dnsresolver.Strategy // how to resolve the rule; empty means the resolver's default
+}
 
-	ips, err := e.resolver.LookupHost(ctx, cmd.domain)
+// block creates or merges the rule for a single domain and returns the ID
+// of the rule covering it.
+func (e *Engine) block(ctx context.Context, domain string, o blockOpts) (id string, needsSync bool, err error) {
+	p, err := e.planBlock(ctx, domain, o)
 	if err != nil {
-		// Don't block if DNS fails initially, maybe log? Or should we error?
-		// For now, let's log and not proceed with adding the rule.
-		return false, fmt.Errorf("dns lookup failed for %q: %w", cmd.domain, err)
-		// Alternatively, create a rule with no IPs and let refresh handle it?
+		return "", false, err
 	}
-	if len(ips) == 0 {
-		// Should be covered by dnsresolver error, but check defensively.
-		return false, fmt.Errorf("no IPs resolved for %q", cmd.domain)
+	return e.applyBlock(p, o)
+}
+
+// blockPlan is a domain resolved for block but not yet stored.
+type blockPlan struct {
+	domain   string
+	rule     *rules.Rule            // the new rule, unless a wildcard rule covers domain
+	wildcard string                 // the covering wildcard rule's domain, if any
+	res      dnsresolver.Resolution // domain's addresses, for the wildcard rule
+}
+
+// planBlock does the DNS work of blocking domain under o. It only reads
+// the store, so several may run at once; applyBlock stores the result.
+func (e *Engine) planBlock(ctx context.Context, domain string, o blockOpts) (blockPlan, error) {
+	// A host under an existing wildcard rule is tracked by that rule, unless
+	// the host is blocked through a different group: it then gets a rule of
+	// its own, so that the group covers and unblocks it.
+	if !rules.IsWildcard(domain) {
+		if w, ok := e.coveringWildcard(domain, o); ok && w.Group != o.group {
+			log.Infof("engine: %q is covered by wildcard rule ID %s of another group; blocking it separately", domain, w.ID)
+		} else if ok {
+			log.Infof("engine: %q is covered by wildcard rule ID %s", domain, w.ID)
+			host := strings.ToLower(strings.TrimSuffix(domain, "."))
+			p := blockPlan{domain: host, wildcard: w.Domain}
+			if slices.Contains(w.Hosts, host) {
+				return p, nil
+			}
+			var err error
+			p.res, err = e.resolver.Resolve(dnsresolver.ContextWithStrategy(ctx, dnsresolver.Strategy(w.Strategy)), host)
+			if err != nil {
+				return blockPlan{}, fmt.Errorf("%w for %q: %w", ErrResolve, host, err)
+			}
+			return p, nil
+		}
 	}
 
-	now := time.Now()
-	rule := &rules.Rule{
-		ID:         uuid.NewString(), // Generate a new unique ID
-		Domain:     cmd.domain,
-		IPs:        ips,
-		ResolvedAt: now,
+	rule, err := e.newRule(ctx, domain, o.strategy)
+	if err != nil {
+		// A domain that does not exist is most likely a typo, which the
+		// caller should hear about now. Any other failure may pass, so the
+		// rule is kept and enforced once it resolves.
+		if !errors.Is(err, ErrResolve) || errors.Is(err, ErrNoSuchDomain) {
+			return blockPlan{}, err
+		}
+		log.Warnf("engine: %v; keeping %q pending", err, domain)
+		rule = pendingRule(domain, o.strategy, o.now, err)
 	}
+	return blockPlan{domain: domain, rule: rule}, nil
+}
 
-	if cmd.ttl > 0 {
-		rule.Expires = now.Add(cmd.ttl)
+// applyBlock stores the rule p planned, or adds p's host to the wildcard
+// rule covering it, and returns the ID of the rule covering the domain.
+func (e *Engine) applyBlock(p blockPlan, o blockOpts) (id string, needsSync bool, err error) {
+	if p.wildcard != "" {
+		return e.learnHost(p.wildcard, p.domain, p.res)
+	}
+	domain, rule := p.domain, p.rule
+	rule.Group = o.group
+	if o.ttl > 0 {
+		rule.Expires = o.now.Add(o.ttl)
 		rule.Permanent = false
 	} else {
 		rule.Permanent = true
 	}
+	if o.lock > 0 {
+		rule.LockedUntil = o.now.Add(o.lock)
+	}
 
-	changed := e.store.Upsert(rule)
+	changed := e.upsert(rule)
+	// Upsert merges into an existing rule for the same domain, in which
+	// case the caller needs that rule's ID rather than the one we minted.
+	id = rule.ID
+	if cur, ok := e.store.ByDomain(domain); ok {
+		id = cur.ID
+	}
 	if changed {
-		log.Infof("engine: added/updated rule ID %s for domain %s", rule.ID, rule.Domain)
+		log.Infof("engine: added/updated rule ID %s for domain %s", id, domain)
 	} else {
-		log.Infof("engine: block request for existing permanent domain %s ignored", rule.Domain)
+		log.Infof("engine: block request for existing permanent domain %s ignored", domain)
 	}
 
-	return changed, nil
+	return id, changed, nil
 }
 
-func (e *Engine) handleUnblock(_ context.Context, cmd unblockCmd) (needsSync bool, err error) {
-	log.Infof("engine: handling unblock request for ID %q", cmd.id)
-	removedRule, found := e.store.Remove(cmd.id)
-	if found {
-		log.Infof("engine: removed rule ID %s for domain %s", removedRule.ID, removedRule.Domain)
-		return true, nil // Need to sync PF
+// newRule builds a rule for domain with addresses freshly resolved using
+// strategy. Expiry is left for the caller to fill in.
+func (e *Engine) newRule(ctx context.Context, domain string, strategy dnsresolver.Strategy) (*rules.Rule, error) {
+	now := time.Now()
+	rule := &rules.Rule{
+		ID:         uuid.NewString(), // Generate a new unique ID
+		Domain:     domain,
+		ResolvedAt: now,
+		Created:    now,
+		Strategy:   string(strategy),
+	}
+	if rule.Wildcard() {
+		rule.Hosts = e.wildcardHosts(domain)
+	}
+
+	res, err := e.resolveRule(ctx, *rule)
+	if err != nil {
+		cause := ErrResolve
+		if dnsresolver.NotFound(err) {
+			cause = ErrNoSuchDomain
+		}
+		return nil, fmt.Errorf("%w for %q: %w", cause, domain, err)
+	}
+	if len(res.ips) == 0 {
+		// Should be covered by dnsresolver error, but check defensively.
+		return nil, fmt.Errorf("%w: no IPs for %q", ErrResolve, domain)
 	}
-	log.Infof("engine: unblock request for non-existent ID %q ignored", cmd.id)
-	return false, nil
+	rule.IPs = res.ips
+	rule.Sources = res.sources
+	rule.CNAMEs = res.cnames
+	rule.Targets = res.targets
+	rule.TTL = res.ttl
+	if rule.Wildcard() {
+		// Only keep the candidate names that actually exist.
+		rule.Hosts = res.hosts
+	}
+	return rule, nil
+}
+
+// newRules is newRule for each of domains with the resolver's default
+// strategy. The domains are resolved concurrently, so that it takes about
+// as long as the slowest; out[i] is nil where errs[i] is set.
+func (e *Engine) newRules(ctx context.Context, domains []string) (out []*rules.Rule, errs []error) {
+	out = make([]*rules.Rule, len(domains))
+	errs = make([]error, len(domains))
+	grp, gctx := errgroup.WithContext(ctx)
+	grp.SetLimit(_maxParallelLookups)
+	for i, domain := range domains {
+		grp.Go(func() error {
+			out[i], errs[i] = e.newRule(gctx, domain, "")
+			return nil // one failed domain must not cancel the others
+		})
+	}
+	_ = grp.Wait() // goroutines never return errors
+	return out, errs
+}
+
+func (e *Engine) handleUnblock(_ context.Context, cmd unblockCmd) (results []UnblockResult, needsSync bool, err error) {
+	if cmd.group != "" {
+		log.Infof("engine: handling unblock request for group %q", cmd.group)
+		cmd.targets = append(cmd.targets, e.groupTargets(cmd.group)...)
+		if len(cmd.targets) == 0 {
+			e.groupMu.RLock()
+			_, ok := e.groups[cmd.group]
+			e.groupMu.RUnlock()
+			if !ok {
+				return nil, false, fmt.Errorf("%w: %q", ErrGroupNotFound, cmd.group)
+			}
+			return nil, false, nil
+		}
+	}
+	log.Infof("engine: handling unblock request for %q", cmd.targets)
+	now := time.Now()
+	results = make([]UnblockResult, 0, len(cmd.targets))
+	for _, target := range cmd.targets {
+		res := UnblockResult{Target: target}
+		rule, err := e.store.Lookup(target)
+		if err != nil {
+			res.Err = fmt.Errorf("%w: %q", err, target)
+			log.Infof("engine: unblock target %q not removed: %v", target, err)
+			results = append(results, res)
+			continue
+		}
+		if rule.Locked(now) {
+			res.Err = fmt.Errorf("%w until %s: %q", ErrLocked, rule.LockedUntil.Format(time.RFC3339), target)
+			log.Infof("engine: unblock target %q refused: rule %s is locked", target, rule.ID)
+			results = append(results, res)
+			continue
+		}
+		if removed, found := e.remove(rule.ID, "unblocked"); found {
+			log.Infof("engine: removed rule ID %s for domain %s", removed.ID, removed.Domain)
+			res.Rule = *removed
+			needsSync = true
+		} else {
+			res.Err = fmt.Errorf("%w: %q", ErrRuleNotFound, target)
+		}
+		results = append(results, res)
+	}
+	return results, needsSync, nil
 }
 
 func (e *Engine) handleRefreshExpire(ctx context.Context) (needsSync bool, err error) {
 	log.Debug("engine: handling refresh/expire cycle")
 	now := time.Now()
+	// A refresh is due before the records' TTL runs out, so a cached
+	// answer would only be the one the rule already has.
+	ctx = dnsresolver.ContextWithRefresh(ctx)
 	var changed bool
 
 	// 1. Expire rules
@@ -264,42 +661,65 @@ func (e *Engine) handleRefreshExpire(ctx context.Context) (needsSync bool, err e
 		changed = true
 		for _, r := range expired {
 			log.Infof("engine: expired rule ID %s for domain %s", r.ID, r.Domain)
+			e.publish(events.RuleExpired, *r, "", nil)
 		}
 	}
 
 	// 2. Refresh DNS for existing rules nearing refresh time
 	var refreshErrors error
 	for _, rule := range e.store.Snapshot() { // Get a copy to iterate over
+		// Pending rules back off on their own schedule rather than
+		// making the whole cycle retry.
+		if rule.Pending {
+			if !now.Before(rule.NextAttempt) && e.retryPending(ctx, rule, now) {
+				changed = true
+			}
+			continue
+		}
 		// Check if rule needs refresh (e.g., older than 90% of refresh interval)
-		if rule.ResolvedAt.IsZero() || time.Since(rule.ResolvedAt) > (e.dnsRefresh*9/10) {
+		if rule.ResolvedAt.IsZero() || !now.Before(rule.ResolvedAt.Add(e.refreshAfter(rule))) {
 			log.Infof("engine: refreshing DNS for rule ID %s (%s)", rule.ID, rule.Domain)
-			newIPs, err := e.resolver.LookupHost(ctx, rule.Domain)
+			res, err := e.resolveRule(ctx, rule)
 			if err != nil {
 				refreshErrors = multierr.Append(refreshErrors, fmt.Errorf("refresh failed for %s (%s): %w", rule.ID, rule.Domain, err))
+				e.publish(events.DNSRefreshFailed, rule, "", err)
 				continue
 			}
 
-			// Check if IPs actually changed
-			if !ipsEqual(rule.IPs, newIPs) {
+			// Only addresses and service targets reach pf; a new alias
+			// chain is recorded but needs no reload.
+			addrsChanged := !ipsEqual(rule.IPs, res.ips) || !slices.Equal(rule.Targets, res.targets)
+			if addrsChanged || !slices.Equal(rule.CNAMEs, res.cnames) {
 				log.Infof("engine: IPs changed for rule ID %s (%s)", rule.ID, rule.Domain)
+				e.publish(events.DNSRefreshed, rule, fmt.Sprintf("%d -> %d addresses", len(rule.IPs), len(res.ips)), nil)
 				// Create updated rule object (keep ID, Expires, Permanent)
 				updatedRule := &rules.Rule{
 					ID:         rule.ID,
 					Domain:     rule.Domain,
-					IPs:        newIPs,
+					Hosts:      rule.Hosts, // Keep learned names through transient failures
+					CNAMEs:     res.cnames,
+					Targets:    res.targets,
+					IPs:        res.ips,
+					Sources:    res.sources,
 					Expires:    rule.Expires, // Keep original expiry
 					Permanent:  rule.Permanent,
 					ResolvedAt: now, // Update resolution time
+					TTL:        res.ttl,
+					Strategy:   rule.Strategy,
+					// Keep what blocked it, or the store takes it for the user's own block
+					ScheduleID: rule.ScheduleID,
+					Group:      rule.Group,
+					SessionID:  rule.SessionID,
 				}
 				// Upsert should handle replacing the existing entry by ID
-				if e.store.Upsert(updatedRule) {
+				if e.upsert(updatedRule) && addrsChanged {
 					changed = true // IPs changed, need sync
 				}
 			} else {
 				log.Debugf("engine: IPs unchanged for rule ID %s (%s), updating resolution time", rule.ID, rule.Domain)
-				if e.store.UpdateResolvedAt(rule.ID, now) {
-					changed = true // ResolvedAt changed, need sync
-				}
+				e.publish(events.DNSRefreshed, rule, "unchanged", nil)
+				// handle flushes the store; pf has nothing new to load.
+				e.store.UpdateResolvedAt(rule.ID, now, res.ttl)
 			}
 		}
 	}
@@ -310,19 +730,46 @@ func (e *Engine) handleRefreshExpire(ctx context.Context) (needsSync bool, err e
 // syncPF pushes the current ruleset state to the firewall manager.
 func (e *Engine) syncPF(ctx context.Context) error {
 	log.Info("engine: synchronizing rules with PF")
-	currentRules := e.store.Snapshot()
+	// Pending rules have no addresses to block yet.
+	currentRules := slices.DeleteFunc(e.store.Snapshot(), func(r rules.Rule) bool { return r.Pending })
 	// Pass the engine's run context, not the original request context
-	err := e.pfMgr.Sync(ctx, currentRules)
+	reloaded, err := e.pfMgr.Sync(ctx, currentRules)
+	detail := fmt.Sprintf("%d rules", len(currentRules))
 	if err != nil {
+		e.emit(events.Event{Type: events.PFSyncFailed, Detail: detail, Error: err.Error()})
 		return fmt.Errorf("pfMgr.Sync failed: %w", err)
 	}
+	if reloaded {
+		e.emit(events.Event{Type: events.PFSynced, Detail: detail})
+	}
 	log.Info("engine: PF synchronization successful")
 	return nil
 }
 
-// loadInitialRules reads the current rules from PF on startup.
-// This prevents overwriting existing rules managed by a previous daemon instance.
-func (e *Engine) loadInitialRules(_ context.Context) error {
+// freshStore is implemented by durable stores that can tell whether they
+// started without saved state.
+type freshStore interface {
+	Fresh() bool
+}
+
+// loadInitialRules prepares the store on startup. A durable store that
+// already holds saved state is authoritative and the anchor is simply
+// re-rendered from it. Otherwise the rules are read back from the pf anchor
+// so a previous daemon instance's rules are not overwritten; for a durable
+// store this is a one-time migration.
+func (e *Engine) loadInitialRules(ctx context.Context) error {
+	if ds, ok := e.store.(freshStore); ok && !ds.Fresh() {
+		log.Infof("engine: loaded %d rules from state file", len(e.store.Snapshot()))
+		return e.syncPF(ctx)
+	}
+	if err := e.importAnchor(); err != nil {
+		return err
+	}
+	return e.store.Flush()
+}
+
+// importAnchor reads the current rules back from the pf anchor.
+func (e *Engine) importAnchor() error {
 	log.Info("engine: loading initial rules from PF")
 	initialRules, err := e.pfMgr.CurrentRules()
 	if err != nil {
@@ -360,25 +807,45 @@ func (e *Engine) loadInitialRules(_ context.Context) error {
 
 // command interface defines the structure of commands sent to the engine.
 type command interface {
-	isCommand()
+	// respond hands the outcome back to whoever queued the command.
+	// Internal commands without a waiting caller ignore it.
+	respond(result)
+}
+
+// result is the outcome of a processed command.
+type result struct {
+	id        string            // rule ID affected by the command, if any
+	ids       []string          // rule IDs affected by a multi-rule command
+	rule      rules.Rule        // rule changed by the command, if any
+	unblocked []UnblockResult   // per-target outcome of an unblockCmd
+	sched     schedule.Schedule // schedule affected by a schedule command
+	sess      focus.Session     // focus session affected by a session command
+	err       error
 }
 
 type blockCmd struct {
-	domain string
-	ttl    time.Duration
+	domain   string
+	ttl      time.Duration
+	lock     time.Duration
+	strategy dnsresolver.Strategy
+	reply    chan<- result
 }
 
-func (blockCmd) isCommand() {}
+func (c blockCmd) respond(r result) { c.reply <- r }
 
 type unblockCmd struct {
-	id string
+	targets []string
+	group   string // if set, every rule blocked through this group is a target
+	reply   chan<- result
 }
 
-func (unblockCmd) isCommand() {}
+func (c unblockCmd) respond(r result) { c.reply <- r }
 
+// refreshExpireCmd runs the time-driven work; the runLoop issues it to
+// itself when its wake timer fires.
 type refreshExpireCmd struct{}
 
-func (refreshExpireCmd) isCommand() {}
+func (refreshExpireCmd) respond(result) {}
 
 // ipsEqual checks if two slices of net.IPAddr contain the same IPs, ignoring order.
 func ipsEqual(a, b []net.IPAddr) bool {
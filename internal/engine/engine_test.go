@@ -0,0 +1,389 @@
+package engine
+
+import (
+	"context"
+	"io/fs"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/lc/void/internal/dnsresolver"
+	"github.com/lc/void/internal/events"
+	"github.com/lc/void/internal/group"
+	"github.com/lc/void/internal/pf"
+	"github.com/lc/void/internal/rules"
+)
+
+// fakePF records every ruleset the engine syncs. It reports a reload
+// unless the ruleset matches the last one synced.
+type fakePF struct {
+	mu    sync.Mutex
+	syncs [][]rules.Rule
+}
+
+func (f *fakePF) CurrentRules() ([]rules.Rule, error) { return nil, fs.ErrNotExist }
+
+func (f *fakePF) Sync(_ context.Context, want []rules.Rule) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	reloaded := len(f.syncs) == 0 || !reflect.DeepEqual(f.syncs[len(f.syncs)-1], want)
+	f.syncs = append(f.syncs, want)
+	return reloaded, nil
+}
+
+func (f *fakePF) Counters(context.Context) (map[string]pf.Counters, error) { return nil, nil }
+
+// synced returns the domains of the last ruleset synced, if any.
+func (f *fakePF) synced() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.syncs) == 0 {
+		return nil
+	}
+	var out []string
+	for _, r := range f.syncs[len(f.syncs)-1] {
+		out = append(out, r.Domain)
+	}
+	return out
+}
+
+// fakeResolver answers the names it was given an address or an error for;
+// any other name does not exist. Each lookup takes hold, and peak is the
+// most lookups that were in flight at once.
+type fakeResolver struct {
+	mu       sync.Mutex
+	addrs    map[string]string
+	errs     map[string]error
+	calls    map[string]int
+	hold     time.Duration
+	inflight int
+	peak     int
+}
+
+func newFakeResolver() *fakeResolver {
+	return &fakeResolver{addrs: map[string]string{}, errs: map[string]error{}, calls: map[string]int{}}
+}
+
+// answer makes host resolve to ip.
+func (f *fakeResolver) answer(host, ip string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.errs, host)
+	f.addrs[host] = ip
+}
+
+// fail makes lookups of host fail with err.
+func (f *fakeResolver) fail(host string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.addrs, host)
+	f.errs[host] = err
+}
+
+func (f *fakeResolver) lookups(host string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[host]
+}
+
+// concurrency returns the most lookups that were in flight at once.
+func (f *fakeResolver) concurrency() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.peak
+}
+
+func (f *fakeResolver) LookupHost(ctx context.Context, host string) ([]net.IPAddr, error) {
+	res, err := f.Resolve(ctx, host)
+	return res.Addrs, err
+}
+
+func (f *fakeResolver) Resolve(_ context.Context, host string) (dnsresolver.Resolution, error) {
+	f.mu.Lock()
+	f.calls[host]++
+	f.inflight++
+	f.peak = max(f.peak, f.inflight)
+	hold := f.hold
+	f.mu.Unlock()
+	time.Sleep(hold)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.inflight--
+	if err, ok := f.errs[host]; ok {
+		return dnsresolver.Resolution{}, err
+	}
+	ip, ok := f.addrs[host]
+	if !ok {
+		return dnsresolver.Resolution{}, &dnsresolver.NegativeError{Name: host, Err: dnsresolver.ErrNXDomain}
+	}
+	return dnsresolver.Resolution{Addrs: []net.IPAddr{{IP: net.ParseIP(ip)}}, TTL: 5 * time.Minute}, nil
+}
+
+type EngineTestSuite struct {
+	suite.Suite
+	ctx     context.Context
+	pf      *fakePF
+	dns     *fakeResolver
+	engine  *Engine
+	running bool
+}
+
+func (s *EngineTestSuite) SetupTest() {
+	s.ctx = context.Background()
+	s.pf = &fakePF{}
+	s.dns = newFakeResolver()
+	s.engine = New(s.pf, s.dns, time.Hour, WithWildcardPrefixes([]string{"www", "api"}))
+	s.running = false
+}
+
+func (s *EngineTestSuite) TearDownTest() {
+	if s.running {
+		s.engine.Close()
+	}
+}
+
+// start runs the engine's loop. Tests that call handlers directly must
+// not, since the loop would run alongside them.
+func (s *EngineTestSuite) start() {
+	s.engine.Run(s.ctx)
+	s.running = true
+}
+
+func (s *EngineTestSuite) rule(domain string) rules.Rule {
+	r, ok := s.engine.store.ByDomain(domain)
+	s.Require().True(ok, "no rule for %s", domain)
+	return r
+}
+
+func (s *EngineTestSuite) TestBlockDomain() {
+	s.start()
+	s.dns.answer("example.com", "192.0.2.1")
+
+	// The reply comes once the rule is in PF.
+	id, err := s.engine.BlockDomain(s.ctx, "example.com", time.Hour, 0, "")
+	s.Require().NoError(err)
+	r := s.rule("example.com")
+	s.Equal(id, r.ID)
+	s.Equal("192.0.2.1", r.IPs[0].IP.String())
+	s.Contains(s.pf.synced(), "example.com")
+
+	// Blocking again merges into the same rule, whose ID is returned.
+	again, err := s.engine.BlockDomain(s.ctx, "example.com", 2*time.Hour, 0, "")
+	s.Require().NoError(err)
+	s.Equal(id, again)
+	s.Len(s.engine.Snapshot(), 1)
+	s.WithinDuration(time.Now().Add(2*time.Hour), s.rule("example.com").Expires, time.Minute)
+}
+
+func (s *EngineTestSuite) TestBlockDomainUnresolved() {
+	s.start()
+
+	// A name that does not exist is refused outright.
+	_, err := s.engine.BlockDomain(s.ctx, "typo.example", time.Hour, 0, "")
+	s.ErrorIs(err, ErrNoSuchDomain)
+	s.ErrorIs(err, ErrResolve)
+	s.Empty(s.engine.Snapshot())
+
+	// Any other failure leaves the rule pending, out of PF.
+	s.dns.fail("down.example", _errTimeout)
+	id, err := s.engine.BlockDomain(s.ctx, "down.example", time.Hour, 0, "")
+	s.Require().NoError(err)
+	r := s.rule("down.example")
+	s.Equal(id, r.ID)
+	s.True(r.Pending)
+	s.Contains(r.LastError, "i/o timeout")
+	s.NotContains(s.pf.synced(), "down.example")
+}
+
+func (s *EngineTestSuite) TestUnblock() {
+	s.start()
+	s.dns.answer("example.com", "192.0.2.1")
+	id, err := s.engine.BlockDomain(s.ctx, "example.com", time.Hour, 0, "")
+	s.Require().NoError(err)
+
+	// Each target gets its own result, and the reply comes once PF has
+	// dropped the rule.
+	res, err := s.engine.Unblock(s.ctx, []string{id[:8], "nope.com"})
+	s.Require().NoError(err)
+	s.Require().Len(res, 2)
+	s.NoError(res[0].Err)
+	s.Equal(id, res[0].Rule.ID)
+	s.ErrorIs(res[1].Err, ErrRuleNotFound)
+	s.Empty(s.engine.Snapshot())
+	s.Empty(s.pf.synced())
+}
+
+func (s *EngineTestSuite) TestUnblockLocked() {
+	s.start()
+	s.dns.answer("locked.com", "192.0.2.1")
+	s.dns.answer("free.com", "192.0.2.2")
+	_, err := s.engine.BlockDomain(s.ctx, "locked.com", time.Hour, 30*time.Minute, "")
+	s.Require().NoError(err)
+	_, err = s.engine.BlockDomain(s.ctx, "free.com", time.Hour, 0, "")
+	s.Require().NoError(err)
+
+	res, err := s.engine.Unblock(s.ctx, []string{"locked.com", "free.com"})
+	s.Require().NoError(err)
+	s.Require().Len(res, 2)
+	s.ErrorIs(res[0].Err, ErrLocked)
+	s.NoError(res[1].Err)
+	s.Equal("free.com", res[1].Rule.Domain)
+	s.Equal([]string{"locked.com"}, s.pf.synced())
+
+	// A lock also refuses shortening, but not extending.
+	_, err = s.engine.UpdateExpiry(s.ctx, "locked.com", ExpiryUpdate{Extend: -10 * time.Minute})
+	s.ErrorIs(err, ErrLocked)
+	r, err := s.engine.UpdateExpiry(s.ctx, "locked.com", ExpiryUpdate{Extend: time.Hour})
+	s.Require().NoError(err)
+	s.WithinDuration(time.Now().Add(2*time.Hour), r.Expires, time.Minute)
+}
+
+func (s *EngineTestSuite) TestGroup() {
+	s.start()
+	s.dns.answer("a.com", "192.0.2.1")
+	s.dns.answer("b.com", "192.0.2.2")
+	s.dns.answer("mine.com", "192.0.2.3")
+	s.Require().NoError(s.engine.DefineGroup(s.ctx, group.New("social", []string{"a.com", "b.com", "gone.com"})))
+	_, err := s.engine.BlockDomain(s.ctx, "mine.com", time.Hour, 0, "")
+	s.Require().NoError(err)
+
+	// Members that fail are reported without failing the others.
+	res, err := s.engine.BlockGroup(s.ctx, "social", time.Hour, 0, "")
+	s.Require().NoError(err)
+	s.Len(res.IDs, 2)
+	s.ErrorIs(res.Failed, ErrResolve)
+	s.Equal("social", s.rule("a.com").Group)
+	s.Equal(s.rule("a.com").Expires, s.rule("b.com").Expires, "members expire together")
+
+	// The group's expiry can be changed per member like any rule's.
+	r, err := s.engine.UpdateExpiry(s.ctx, "a.com", ExpiryUpdate{Permanent: true})
+	s.Require().NoError(err)
+	s.True(r.Permanent)
+
+	// Unblocking the group leaves rules blocked on their own.
+	unblocked, err := s.engine.UnblockGroup(s.ctx, "Social")
+	s.Require().NoError(err)
+	s.Len(unblocked, 2)
+	s.Equal([]string{"mine.com"}, s.pf.synced())
+
+	_, err = s.engine.BlockGroup(s.ctx, "nope", time.Hour, 0, "")
+	s.ErrorIs(err, ErrGroupNotFound)
+	_, err = s.engine.UnblockGroup(s.ctx, "nope")
+	s.ErrorIs(err, ErrGroupNotFound)
+}
+
+func (s *EngineTestSuite) TestGroupWildcardMember() {
+	s.start()
+	s.dns.answer("news.com", "192.0.2.1")
+	s.dns.answer("live.news.com", "192.0.2.2")
+	s.Require().NoError(s.engine.DefineGroup(s.ctx, group.New("news", []string{"live.news.com", "*.news.com"})))
+
+	// The member under the wildcard joins its rule, as it would if blocked
+	// on its own afterwards.
+	res, err := s.engine.BlockGroup(s.ctx, "news", time.Hour, 0, "")
+	s.Require().NoError(err)
+	s.Require().Len(res.IDs, 2)
+	s.Equal(res.IDs[0], res.IDs[1])
+	s.Len(s.engine.Snapshot(), 1)
+	s.Contains(s.rule("*.news.com").Hosts, "live.news.com")
+
+	// Its rules can still be unblocked after the group is deleted.
+	s.Require().NoError(s.engine.DeleteGroup(s.ctx, "news"))
+	unblocked, err := s.engine.UnblockGroup(s.ctx, "news")
+	s.Require().NoError(err)
+	s.Len(unblocked, 1)
+	s.Empty(s.engine.Snapshot())
+}
+
+func (s *EngineTestSuite) TestGroupLeavesOwnBlocks() {
+	s.start()
+	for _, d := range []string{"before.com", "after.com", "only.com"} {
+		s.dns.answer(d, "192.0.2.1")
+	}
+	s.Require().NoError(s.engine.DefineGroup(s.ctx, group.New("social", []string{"before.com", "after.com", "only.com"})))
+
+	// The user blocks one member before the group and one after.
+	_, err := s.engine.BlockDomain(s.ctx, "before.com", time.Hour, 0, "")
+	s.Require().NoError(err)
+	_, err = s.engine.BlockGroup(s.ctx, "social", 2*time.Hour, 0, "")
+	s.Require().NoError(err)
+	_, err = s.engine.BlockDomain(s.ctx, "after.com", time.Hour, 0, "")
+	s.Require().NoError(err)
+	s.WithinDuration(time.Now().Add(2*time.Hour), s.rule("before.com").Expires, time.Minute, "the group still extends it")
+
+	unblocked, err := s.engine.UnblockGroup(s.ctx, "social")
+	s.Require().NoError(err)
+	s.Require().Len(unblocked, 1)
+	s.Equal("only.com", unblocked[0].Rule.Domain)
+	s.ElementsMatch([]string{"before.com", "after.com"}, s.pf.synced())
+}
+
+func (s *EngineTestSuite) TestRefreshKeepsGroup() {
+	s.dns.answer("a.com", "192.0.2.1")
+	_, _, err := s.engine.block(s.ctx, "a.com", blockOpts{now: time.Now(), group: "social"})
+	s.Require().NoError(err)
+
+	s.dns.answer("a.com", "192.0.2.2")
+	r := s.rule("a.com")
+	s.engine.store.UpdateResolvedAt(r.ID, time.Now().Add(-r.TTL), r.TTL)
+	_, err = s.engine.handleRefreshExpire(s.ctx)
+	s.Require().NoError(err)
+	r = s.rule("a.com")
+	s.Equal("192.0.2.2", r.IPs[0].IP.String())
+	s.Equal("social", r.Group)
+}
+
+func (s *EngineTestSuite) TestRefreshSkipsCache() {
+	s.engine = New(s.pf, dnsresolver.NewCache(s.dns), time.Hour)
+	s.dns.answer("cdn.example", "192.0.2.1")
+	_, _, err := s.engine.block(s.ctx, "cdn.example", blockOpts{now: time.Now()})
+	s.Require().NoError(err)
+
+	// The CDN rotates its addresses while the cached answer is still
+	// valid, and the rule falls due for a refresh.
+	s.dns.answer("cdn.example", "192.0.2.2")
+	r := s.rule("cdn.example")
+	s.engine.store.UpdateResolvedAt(r.ID, time.Now().Add(-r.TTL), r.TTL)
+
+	needsSync, err := s.engine.handleRefreshExpire(s.ctx)
+	s.Require().NoError(err)
+	s.True(needsSync)
+	s.Equal("192.0.2.2", s.rule("cdn.example").IPs[0].IP.String())
+}
+
+func (s *EngineTestSuite) TestRefreshUnchanged() {
+	s.dns.answer("a.com", "192.0.2.1")
+	_, _, err := s.engine.block(s.ctx, "a.com", blockOpts{now: time.Now()})
+	s.Require().NoError(err)
+
+	r := s.rule("a.com")
+	stale := time.Now().Add(-r.TTL)
+	s.engine.store.UpdateResolvedAt(r.ID, stale, r.TTL)
+	needsSync, err := s.engine.handleRefreshExpire(s.ctx)
+	s.Require().NoError(err)
+	s.False(needsSync, "same addresses need no pf reload")
+	s.True(s.rule("a.com").ResolvedAt.After(stale))
+}
+
+func (s *EngineTestSuite) TestSyncPFUnchanged() {
+	sub := s.engine.Subscribe(4)
+	defer sub.Close()
+
+	s.Require().NoError(s.engine.syncPF(s.ctx))
+	s.Require().NoError(s.engine.syncPF(s.ctx))
+	s.Equal(events.PFSynced, (<-sub.C).Type)
+	select {
+	case ev := <-sub.C:
+		s.Failf("unexpected event", "%s after a sync that reloaded nothing", ev.Type)
+	default:
+	}
+}
+
+func TestEngineSuite(t *testing.T) {
+	suite.Run(t, new(EngineTestSuite))
+}
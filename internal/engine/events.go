@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lc/void/internal/audit"
+	"github.com/lc/void/internal/events"
+	"github.com/lc/void/internal/log"
+	"github.com/lc/void/internal/rules"
+)
+
+// Subscribe returns a subscription to the engine's event stream with room
+// for buf queued events (events.DefaultBuffer if buf <= 0). The caller
+// must Close it.
+func (e *Engine) Subscribe(buf int) *events.Subscription {
+	return e.bus.Subscribe(buf)
+}
+
+// publish reports an event about a single rule.
+func (e *Engine) publish(typ events.Type, r rules.Rule, detail string, err error) {
+	ev := events.Event{Type: typ, RuleID: r.ID, Domain: r.Domain, Detail: detail}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	e.emit(ev)
+}
+
+// emit attaches the origin of the command being handled, if any, to ev,
+// publishes it and records it in the audit log. Runs only within runLoop.
+func (e *Engine) emit(ev events.Event) {
+	ev.Actor, ev.Reason = e.origin.actor, e.origin.reason
+	ev = e.bus.Publish(ev)
+	if e.audit == nil || !audit.Audited(ev.Type) {
+		return
+	}
+	if err := e.audit.Record(ev); err != nil {
+		log.Errorf("engine: failed to write audit log: %v", err)
+	}
+}
+
+// upsert stores r like Store.Upsert and reports the rule as added or
+// updated. Runs only within runLoop.
+func (e *Engine) upsert(r *rules.Rule) (changed bool) {
+	old, existed := e.store.ByDomain(r.Domain)
+	if !e.store.Upsert(r) {
+		return false
+	}
+	cur, ok := e.store.ByDomain(r.Domain)
+	if !ok {
+		return true
+	}
+	typ, detail := events.RuleAdded, expiryDetail(cur)
+	if cur.Pending && !existed {
+		typ, detail = events.RulePending, "waiting for DNS: "+cur.LastError
+	}
+	if existed {
+		typ = events.RuleUpdated
+		if !ipsEqual(old.IPs, cur.IPs) {
+			detail = fmt.Sprintf("%d -> %d addresses, %s", len(old.IPs), len(cur.IPs), detail)
+		}
+	}
+	e.publish(typ, cur, detail, nil)
+	return true
+}
+
+// remove deletes rule id like Store.Remove and reports why it went.
+// Runs only within runLoop.
+func (e *Engine) remove(id, why string) (*rules.Rule, bool) {
+	r, ok := e.store.Remove(id)
+	if ok {
+		e.publish(events.RuleRemoved, *r, why, nil)
+	}
+	return r, ok
+}
+
+// expiryDetail describes when a rule ends.
+func expiryDetail(r rules.Rule) string {
+	if r.Permanent {
+		return "permanent"
+	}
+	return "expires " + r.Expires.Format(time.RFC3339)
+}
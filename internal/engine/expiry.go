@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lc/void/internal/events"
+	"github.com/lc/void/internal/log"
+	"github.com/lc/void/internal/rules"
+)
+
+// ExpiryUpdate describes a change to a rule's expiry. Exactly one of
+// Extend, TTL or Permanent should be set.
+type ExpiryUpdate struct {
+	Extend    time.Duration      // move the current expiry by this much; negative shortens
+	TTL       time.Duration      // expire this long from now
+	Permanent bool               // make the rule permanent
+	Policy    rules.ExpiryPolicy // whether the change may end the rule sooner
+}
+
+// UpdateExpiry changes the expiry of the rule referenced by target (a
+// domain, rule ID or unique ID prefix) and returns the updated rule.
+// A locked rule is always treated as rules.ExtendOnly.
+func (e *Engine) UpdateExpiry(ctx context.Context, target string, u ExpiryUpdate) (rules.Rule, error) {
+	reply := make(chan result, 1)
+	res, err := e.submit(ctx, updateExpiryCmd{target: target, update: u, reply: reply}, reply)
+	if err != nil {
+		return rules.Rule{}, err
+	}
+	return res.rule, res.err
+}
+
+// --- Command Handlers (run only within runLoop) ---
+
+func (e *Engine) handleUpdateExpiry(_ context.Context, cmd updateExpiryCmd) (updated rules.Rule, needsSync bool, err error) {
+	rule, err := e.store.Lookup(cmd.target)
+	if err != nil {
+		return rules.Rule{}, false, fmt.Errorf("%w: %q", err, cmd.target)
+	}
+
+	u, now := cmd.update, time.Now()
+	var expires time.Time // zero = permanent
+	switch {
+	case u.Permanent:
+	case u.TTL > 0:
+		expires = now.Add(u.TTL)
+	case rule.Permanent:
+		return rules.Rule{}, false, fmt.Errorf("%w: %q is permanent; give a TTL instead", ErrInvalidExpiry, cmd.target)
+	default:
+		expires = rule.Expires.Add(u.Extend)
+	}
+	if !u.Permanent && !expires.After(now) {
+		return rules.Rule{}, false, fmt.Errorf("%w: %q would already have expired", ErrInvalidExpiry, cmd.target)
+	}
+
+	policy, locked := u.Policy, rule.Locked(now)
+	if locked {
+		policy = rules.ExtendOnly
+	}
+	updated, err = e.store.SetExpiry(rule.ID, expires, policy)
+	switch {
+	case errors.Is(err, rules.ErrWouldShorten) && locked:
+		return rules.Rule{}, false, fmt.Errorf("%w until %s: cannot shorten %q",
+			ErrLocked, rule.LockedUntil.Format(time.RFC3339), cmd.target)
+	case err != nil:
+		return rules.Rule{}, false, fmt.Errorf("%w: %q", err, cmd.target)
+	}
+
+	e.publish(events.RuleUpdated, updated, expiryDetail(updated), nil)
+	if updated.Permanent {
+		log.Infof("engine: rule ID %s (%s) is now permanent", updated.ID, updated.Domain)
+	} else {
+		log.Infof("engine: rule ID %s (%s) now expires at %s (policy: %s)",
+			updated.ID, updated.Domain, updated.Expires.Format(time.RFC3339), policy)
+	}
+	return updated, true, nil
+}
+
+type updateExpiryCmd struct {
+	target string
+	update ExpiryUpdate
+	reply  chan<- result
+}
+
+func (c updateExpiryCmd) respond(r result) { c.reply <- r }
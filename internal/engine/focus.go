@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/multierr"
+
+	"github.com/lc/void/internal/focus"
+	"github.com/lc/void/internal/log"
+)
+
+// SessionStore persists the running focus session across daemon restarts.
+type SessionStore interface {
+	Load() (*focus.Session, error)
+	Save(*focus.Session) error
+}
+
+// WithSessionStore persists the running focus session to s. Without it, a
+// session ends with the daemon process, leaving its blocks to expire at
+// the end of the work phase they were made for.
+func WithSessionStore(s SessionStore) Opt {
+	return func(e *Engine) {
+		e.sessStore = s
+	}
+}
+
+// StartSession starts a focus session. If s.Group is set, the group's
+// domains are added to s.Domains. Only one session runs at a time; the
+// returned session has its ID and start time filled in.
+func (e *Engine) StartSession(ctx context.Context, s focus.Session) (focus.Session, error) {
+	s.ID = uuid.NewString()
+	reply := make(chan result, 1)
+	res, err := e.submit(ctx, startSessionCmd{sess: s, reply: reply}, reply)
+	if err != nil {
+		return focus.Session{}, err
+	}
+	return res.sess, res.err
+}
+
+// StopSession ends the current focus session and lifts the blocks it
+// created, except locked ones. It returns the stopped session.
+func (e *Engine) StopSession(ctx context.Context) (focus.Session, error) {
+	reply := make(chan result, 1)
+	res, err := e.submit(ctx, stopSessionCmd{reply: reply}, reply)
+	if err != nil {
+		return focus.Session{}, err
+	}
+	return res.sess, res.err
+}
+
+// Session returns the current focus session, if any.
+func (e *Engine) Session() (focus.Session, bool) {
+	e.sessMu.RLock()
+	defer e.sessMu.RUnlock()
+	if e.session == nil {
+		return focus.Session{}, false
+	}
+	return *e.session, true
+}
+
+// --- Command Handlers (run only within runLoop) ---
+
+func (e *Engine) handleStartSession(ctx context.Context, cmd startSessionCmd) (sess focus.Session, needsSync bool, err error) {
+	now := time.Now()
+	if cur, ok := e.Session(); ok && cur.At(now).Phase != focus.PhaseDone {
+		return focus.Session{}, false, fmt.Errorf("%w: %s", ErrSessionActive, cur.ID)
+	}
+
+	sess = cmd.sess
+	if sess.Group != "" {
+		e.groupMu.RLock()
+		g, ok := e.groups[strings.ToLower(sess.Group)]
+		e.groupMu.RUnlock()
+		if !ok {
+			return focus.Session{}, false, fmt.Errorf("%w: %q", ErrGroupNotFound, sess.Group)
+		}
+		sess.Group = g.Name
+		sess.Domains = append(sess.Domains, g.Domains...)
+	}
+	for i, d := range sess.Domains {
+		sess.Domains[i] = strings.ToLower(d)
+	}
+	slices.Sort(sess.Domains)
+	sess.Domains = slices.Compact(sess.Domains)
+	sess.Started = now
+	if err := sess.Validate(); err != nil {
+		return focus.Session{}, false, err
+	}
+
+	log.Infof("engine: starting focus session %s: %d x %v work / %v break for %q",
+		sess.ID, sess.Cycles, sess.Work, sess.Break, sess.Domains)
+	if err := e.setSession(&sess); err != nil {
+		return sess, false, err
+	}
+
+	// The session runs either way; a failed block is retried shortly.
+	needsSync, err = e.advanceSession(ctx, now)
+	if err != nil {
+		log.Warnf("engine: focus session %s not fully blocked: %v", sess.ID, err)
+		e.retryLater()
+	}
+	return sess, needsSync, nil
+}
+
+func (e *Engine) handleStopSession(_ context.Context) (sess focus.Session, needsSync bool, err error) {
+	sess, ok := e.Session()
+	if !ok {
+		return focus.Session{}, false, ErrNoSession
+	}
+	log.Infof("engine: stopping focus session %s", sess.ID)
+	err = e.setSession(nil)
+
+	now := time.Now()
+	for _, r := range e.store.Snapshot() {
+		if r.SessionID != sess.ID {
+			continue
+		}
+		if r.Locked(now) {
+			log.Infof("engine: keeping locked rule ID %s of stopped focus session %s", r.ID, sess.ID)
+			continue
+		}
+		if _, ok := e.remove(r.ID, "focus session stopped"); ok {
+			log.Infof("engine: removed rule ID %s blocked by focus session %s", r.ID, sess.ID)
+			needsSync = true
+		}
+	}
+	return sess, needsSync, err
+}
+
+// advanceSession moves the current focus session to the phase containing
+// now. During a work phase every domain is blocked until the phase ends,
+// so breaks begin through the ordinary expiry path; a rule that already
+// outlives the phase (or is permanent) is left alone. Only the rules the
+// session creates are its own: a rule the user already had is extended to
+// the end of the phase, but stopping the session leaves it in place. The
+// domains are resolved concurrently, as the run loop waits for them.
+func (e *Engine) advanceSession(ctx context.Context, now time.Time) (needsSync bool, err error) {
+	sess, ok := e.Session()
+	if !ok {
+		return false, nil
+	}
+	st := sess.At(now)
+	switch st.Phase {
+	case focus.PhaseDone:
+		log.Infof("engine: focus session %s finished", sess.ID)
+		return false, e.setSession(nil)
+	case focus.PhaseBreak:
+		return false, nil
+	}
+
+	var (
+		domains []string
+		existed []bool
+	)
+	for _, domain := range sess.Domains {
+		cur, ok := e.store.ByDomain(domain)
+		if ok && (cur.Permanent || !cur.Expires.Before(st.Ends)) {
+			continue
+		}
+		domains, existed = append(domains, domain), append(existed, ok)
+	}
+
+	resolved, errs := e.newRules(ctx, domains)
+	for i, domain := range domains {
+		if errs[i] != nil {
+			err = multierr.Append(err, fmt.Errorf("focus session %s: %w", sess.ID, errs[i]))
+			continue
+		}
+		rule := resolved[i]
+		rule.Expires = st.Ends
+		if !existed[i] {
+			rule.Group = sess.Group
+			rule.SessionID = sess.ID
+		}
+		if e.upsert(rule) {
+			log.Infof("engine: focus session %s blocked %s until %s (cycle %d/%d)",
+				sess.ID, domain, st.Ends.Format(time.RFC3339), st.Cycle, sess.Cycles)
+			needsSync = true
+		}
+	}
+	return needsSync, err
+}
+
+// setSession makes s the current focus session (nil for none) and saves
+// it, if a store is configured.
+func (e *Engine) setSession(s *focus.Session) error {
+	e.sessMu.Lock()
+	e.session = s
+	e.sessMu.Unlock()
+	if e.sessStore == nil {
+		return nil
+	}
+	if err := e.sessStore.Save(s); err != nil {
+		return fmt.Errorf("saving focus session: %w", err)
+	}
+	return nil
+}
+
+// loadSession restores the focus session saved before a restart, which
+// resumes in whatever phase it has reached, unless it has finished since.
+func (e *Engine) loadSession() error {
+	if e.sessStore == nil {
+		return nil
+	}
+	s, err := e.sessStore.Load()
+	if err != nil {
+		return fmt.Errorf("loading focus session: %w", err)
+	}
+	if s == nil {
+		return nil
+	}
+	if err := s.Validate(); err != nil || s.At(time.Now()).Phase == focus.PhaseDone {
+		log.Infof("engine: discarding saved focus session %s", s.ID)
+		return e.setSession(nil)
+	}
+	e.sessMu.Lock()
+	e.session = s
+	e.sessMu.Unlock()
+	log.Infof("engine: resumed focus session %s", s.ID)
+	return nil
+}
+
+type startSessionCmd struct {
+	sess  focus.Session
+	reply chan<- result
+}
+
+func (c startSessionCmd) respond(r result) { c.reply <- r }
+
+type stopSessionCmd struct {
+	reply chan<- result
+}
+
+func (c stopSessionCmd) respond(r result) { c.reply <- r }
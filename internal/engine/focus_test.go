@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/lc/void/internal/focus"
+)
+
+func (s *EngineTestSuite) TestStopSessionKeepsOwnRules() {
+	s.start()
+	s.dns.answer("mine.com", "192.0.2.1")
+	s.dns.answer("focus.com", "192.0.2.2")
+	_, err := s.engine.BlockDomain(s.ctx, "mine.com", time.Minute, 0, "")
+	s.Require().NoError(err)
+
+	sess, err := s.engine.StartSession(s.ctx, focus.Session{
+		Domains: []string{"mine.com", "focus.com"},
+		Work:    25 * time.Minute,
+		Break:   5 * time.Minute,
+		Cycles:  2,
+	})
+	s.Require().NoError(err)
+
+	// The session extends a rule the user had, but does not take it over.
+	mine := s.rule("mine.com")
+	s.Empty(mine.SessionID)
+	s.WithinDuration(sess.Started.Add(25*time.Minute), mine.Expires, time.Second)
+	s.Equal(sess.ID, s.rule("focus.com").SessionID)
+
+	_, err = s.engine.StopSession(s.ctx)
+	s.Require().NoError(err)
+	s.Equal([]string{"mine.com"}, s.pf.synced())
+	_, running := s.engine.Session()
+	s.False(running)
+}
+
+func (s *EngineTestSuite) TestStopSessionKeepsLaterBlocks() {
+	s.start()
+	s.dns.answer("focus.com", "192.0.2.1")
+	s.dns.answer("later.com", "192.0.2.2")
+	_, err := s.engine.StartSession(s.ctx, focus.Session{
+		Domains: []string{"focus.com", "later.com"},
+		Work:    25 * time.Minute,
+		Break:   5 * time.Minute,
+		Cycles:  2,
+	})
+	s.Require().NoError(err)
+
+	// Blocking a domain the session already blocks makes it the user's.
+	_, err = s.engine.BlockDomain(s.ctx, "later.com", 2*time.Hour, 0, "")
+	s.Require().NoError(err)
+	s.Empty(s.rule("later.com").SessionID)
+
+	_, err = s.engine.StopSession(s.ctx)
+	s.Require().NoError(err)
+	s.Equal([]string{"later.com"}, s.pf.synced())
+}
+
+func (s *EngineTestSuite) TestAdvanceSessionConcurrently() {
+	s.dns.hold = 20 * time.Millisecond
+	sess := focus.Session{ID: "sess", Work: 25 * time.Minute, Break: 5 * time.Minute, Cycles: 1, Started: time.Now()}
+	for _, d := range []string{"a.com", "b.com", "c.com"} {
+		s.dns.answer(d, "192.0.2.1")
+		sess.Domains = append(sess.Domains, d)
+	}
+	s.engine.session = &sess
+
+	needsSync, err := s.engine.advanceSession(s.ctx, time.Now())
+	s.Require().NoError(err)
+	s.True(needsSync)
+	s.Len(s.engine.Snapshot(), 3)
+	s.Greater(s.dns.concurrency(), 1, "resolved one after another")
+}
@@ -0,0 +1,260 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/multierr"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/lc/void/internal/dnsresolver"
+	"github.com/lc/void/internal/group"
+	"github.com/lc/void/internal/log"
+	"github.com/lc/void/internal/rules"
+)
+
+// GroupStore persists group definitions across daemon restarts.
+type GroupStore interface {
+	Load() ([]group.Group, error)
+	Save([]group.Group) error
+}
+
+// WithGroupStore persists group definitions to s. Without it, groups only
+// live as long as the daemon process.
+func WithGroupStore(s GroupStore) Opt {
+	return func(e *Engine) {
+		e.groupStore = s
+	}
+}
+
+// GroupBlockResult reports the outcome of blocking a group.
+type GroupBlockResult struct {
+	IDs    []string // rules now covering the group's domains
+	Failed error    // domains that could not be resolved, if any
+}
+
+// DefineGroup creates or replaces a named group of domains.
+func (e *Engine) DefineGroup(ctx context.Context, g group.Group) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	reply := make(chan result, 1)
+	res, err := e.submit(ctx, defineGroupCmd{group: g, reply: reply}, reply)
+	if err != nil {
+		return err
+	}
+	return res.err
+}
+
+// DeleteGroup removes a group definition. Rules already blocked through
+// the group stay in place until they expire or are unblocked.
+func (e *Engine) DeleteGroup(ctx context.Context, name string) error {
+	reply := make(chan result, 1)
+	res, err := e.submit(ctx, deleteGroupCmd{name: name, reply: reply}, reply)
+	if err != nil {
+		return err
+	}
+	return res.err
+}
+
+// Groups returns all group definitions ordered by name.
+func (e *Engine) Groups() []group.Group {
+	e.groupMu.RLock()
+	defer e.groupMu.RUnlock()
+
+	out := make([]group.Group, 0, len(e.groups))
+	for _, g := range e.groups {
+		out = append(out, g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// BlockGroup blocks every domain of the named group with one shared expiry
+// (ttl of 0 means permanent), an optional shared lock, and a single PF sync.
+// Domains that fail to resolve are reported in Failed; an error is returned
+// only if none could be blocked. A non-empty strategy applies to every
+// member as in BlockDomain.
+func (e *Engine) BlockGroup(ctx context.Context, name string, ttl, lock time.Duration, strategy dnsresolver.Strategy) (GroupBlockResult, error) {
+	reply := make(chan result, 1)
+	cmd := blockGroupCmd{name: name, ttl: ttl, lock: lock, strategy: strategy, reply: reply}
+	res, err := e.submit(ctx, cmd, reply)
+	if err != nil {
+		return GroupBlockResult{}, err
+	}
+	if len(res.ids) == 0 {
+		return GroupBlockResult{}, res.err
+	}
+	return GroupBlockResult{IDs: res.ids, Failed: res.err}, nil
+}
+
+// UnblockGroup removes every rule blocked through the named group with a
+// single PF sync. Locked members are kept and reported with ErrLocked.
+// ErrGroupNotFound is returned if no such group is defined and no rule
+// was blocked through one by that name before it was deleted.
+func (e *Engine) UnblockGroup(ctx context.Context, name string) ([]UnblockResult, error) {
+	reply := make(chan result, 1)
+	cmd := unblockCmd{group: strings.ToLower(name), reply: reply}
+	res, err := e.submit(ctx, cmd, reply)
+	if err != nil {
+		return nil, err
+	}
+	return res.unblocked, res.err
+}
+
+// --- Command Handlers (run only within runLoop) ---
+
+func (e *Engine) handleDefineGroup(cmd defineGroupCmd) error {
+	log.Infof("engine: defining group %q with %d domains", cmd.group.Name, len(cmd.group.Domains))
+	e.groupMu.Lock()
+	e.groups[cmd.group.Name] = cmd.group
+	e.groupMu.Unlock()
+	return e.saveGroups()
+}
+
+func (e *Engine) handleDeleteGroup(cmd deleteGroupCmd) error {
+	name := strings.ToLower(cmd.name)
+	e.groupMu.Lock()
+	_, ok := e.groups[name]
+	delete(e.groups, name)
+	e.groupMu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrGroupNotFound, cmd.name)
+	}
+	log.Infof("engine: deleted group %q", name)
+	return e.saveGroups()
+}
+
+func (e *Engine) handleBlockGroup(ctx context.Context, cmd blockGroupCmd) (ids []string, needsSync bool, err error) {
+	e.groupMu.RLock()
+	g, ok := e.groups[strings.ToLower(cmd.name)]
+	e.groupMu.RUnlock()
+	if !ok {
+		return nil, false, fmt.Errorf("%w: %q", ErrGroupNotFound, cmd.name)
+	}
+	log.Infof("engine: handling block request for group %q (ttl: %v)", g.Name, cmd.ttl)
+
+	// One now for every member so they expire (and unlock) together.
+	o := blockOpts{ttl: cmd.ttl, lock: cmd.lock, now: time.Now(), group: g.Name, strategy: cmd.strategy}
+
+	// Wildcard members go first, so that the other members see the rules
+	// they fall under, just as when blocked one at a time.
+	var wild, plain []string
+	for _, domain := range g.Domains {
+		if rules.IsWildcard(domain) {
+			wild = append(wild, domain)
+		} else {
+			plain = append(plain, domain)
+		}
+	}
+	for _, domains := range [...][]string{wild, plain} {
+		bids, changed, berr := e.blockAll(ctx, domains, o)
+		ids = append(ids, bids...)
+		needsSync = needsSync || changed
+		err = multierr.Append(err, berr)
+	}
+	return ids, needsSync, err
+}
+
+// blockAll blocks each of domains under o. They are resolved concurrently,
+// so that it takes about as long as the slowest, then stored one by one.
+func (e *Engine) blockAll(ctx context.Context, domains []string, o blockOpts) (ids []string, needsSync bool, err error) {
+	plans := make([]blockPlan, len(domains))
+	errs := make([]error, len(domains))
+	grp, gctx := errgroup.WithContext(ctx)
+	grp.SetLimit(_maxParallelLookups)
+	for i, domain := range domains {
+		grp.Go(func() error {
+			plans[i], errs[i] = e.planBlock(gctx, domain, o)
+			return nil // one failed domain must not cancel the others
+		})
+	}
+	_ = grp.Wait() // goroutines never return errors
+
+	for i := range domains {
+		if errs[i] != nil {
+			err = multierr.Append(err, errs[i])
+			continue
+		}
+		id, changed, berr := e.applyBlock(plans[i], o)
+		if berr != nil {
+			err = multierr.Append(err, berr)
+			continue
+		}
+		ids = append(ids, id)
+		needsSync = needsSync || changed
+	}
+	return ids, needsSync, err
+}
+
+// groupTargets returns the IDs of all rules blocked through the group.
+func (e *Engine) groupTargets(name string) []string {
+	var ids []string
+	for _, r := range e.store.Snapshot() {
+		if r.Group == name {
+			ids = append(ids, r.ID)
+		}
+	}
+	return ids
+}
+
+// loadGroups restores persisted group definitions on startup.
+func (e *Engine) loadGroups() error {
+	if e.groupStore == nil {
+		return nil
+	}
+	list, err := e.groupStore.Load()
+	if err != nil {
+		return fmt.Errorf("loading groups: %w", err)
+	}
+
+	e.groupMu.Lock()
+	defer e.groupMu.Unlock()
+	for _, g := range list {
+		if err := g.Validate(); err != nil {
+			log.Warnf("engine: skipping invalid group %q: %v", g.Name, err)
+			continue
+		}
+		e.groups[g.Name] = g
+	}
+	log.Infof("engine: loaded %d groups", len(e.groups))
+	return nil
+}
+
+// saveGroups persists the current group definitions, if a store is configured.
+func (e *Engine) saveGroups() error {
+	if e.groupStore == nil {
+		return nil
+	}
+	if err := e.groupStore.Save(e.Groups()); err != nil {
+		return fmt.Errorf("saving groups: %w", err)
+	}
+	return nil
+}
+
+type defineGroupCmd struct {
+	group group.Group
+	reply chan<- result
+}
+
+func (c defineGroupCmd) respond(r result) { c.reply <- r }
+
+type deleteGroupCmd struct {
+	name  string
+	reply chan<- result
+}
+
+func (c deleteGroupCmd) respond(r result) { c.reply <- r }
+
+type blockGroupCmd struct {
+	name     string
+	ttl      time.Duration
+	lock     time.Duration
+	strategy dnsresolver.Strategy
+	reply    chan<- result
+}
+
+func (c blockGroupCmd) respond(r result) { c.reply <- r }
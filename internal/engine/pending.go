@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lc/void/internal/dnsresolver"
+	"github.com/lc/void/internal/events"
+	"github.com/lc/void/internal/log"
+	"github.com/lc/void/internal/rules"
+)
+
+// pendingBackoff returns how long to wait before the next attempt to
+// resolve a pending rule that has failed attempts times.
+func pendingBackoff(attempts int) time.Duration {
+	d := _pendingMinBackoff
+	for i := 1; i < attempts && d < _pendingMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, _pendingMaxBackoff)
+}
+
+// pendingRule builds a rule for domain that could not be resolved at now
+// because of err. Expiry is left for the caller to fill in, and counts
+// from now like that of any other rule.
+func pendingRule(domain string, strategy dnsresolver.Strategy, now time.Time, err error) *rules.Rule {
+	return &rules.Rule{
+		ID:          uuid.NewString(),
+		Domain:      domain,
+		Created:     now,
+		Strategy:    string(strategy),
+		Pending:     true,
+		Attempts:    1,
+		NextAttempt: now.Add(pendingBackoff(1)),
+		LastError:   err.Error(),
+	}
+}
+
+// retryPending tries to resolve the pending rule again. On success the
+// rule is enforced like any other; otherwise its next attempt is put off.
+// That includes a domain that does not exist (yet): it may be registered
+// later, and the back-off keeps the retries rare. Runs only within runLoop.
+func (e *Engine) retryPending(ctx context.Context, rule rules.Rule, now time.Time) (changed bool) {
+	log.Infof("engine: resolving pending rule ID %s (%s), attempt %d", rule.ID, rule.Domain, rule.Attempts+1)
+	res, err := e.newRule(ctx, rule.Domain, dnsresolver.Strategy(rule.Strategy))
+	if err != nil {
+		rule.Attempts++
+		rule.NextAttempt = now.Add(pendingBackoff(rule.Attempts))
+		rule.LastError = err.Error()
+		e.store.Upsert(&rule)
+		log.Warnf("engine: pending rule ID %s (%s) still unresolved, next attempt at %s: %v",
+			rule.ID, rule.Domain, rule.NextAttempt.Format(time.RFC3339), err)
+		e.publish(events.DNSRefreshFailed, rule, fmt.Sprintf("attempt %d, next at %s",
+			rule.Attempts, rule.NextAttempt.Format(time.RFC3339)), err)
+		// Bookkeeping only: PF has nothing to enforce yet.
+		return false
+	}
+
+	rule.Pending = false
+	rule.IPs, rule.Sources = res.IPs, res.Sources
+	rule.CNAMEs, rule.Targets = res.CNAMEs, res.Targets
+	rule.Hosts, rule.TTL, rule.ResolvedAt = res.Hosts, res.TTL, now
+	log.Infof("engine: pending rule ID %s (%s) resolved after %d attempts", rule.ID, rule.Domain, rule.Attempts+1)
+	return e.upsert(&rule)
+}
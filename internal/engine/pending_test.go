@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lc/void/internal/dnsresolver"
+)
+
+var _errTimeout = errors.New("i/o timeout")
+
+func (s *EngineTestSuite) TestPendingBackoff() {
+	testCases := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 10 * time.Second},
+		{attempts: 2, want: 20 * time.Second},
+		{attempts: 3, want: 40 * time.Second},
+		{attempts: 7, want: 10 * time.Minute},
+		{attempts: 100, want: 10 * time.Minute},
+	}
+	for _, tc := range testCases {
+		s.Equal(tc.want, pendingBackoff(tc.attempts), "after %d attempts", tc.attempts)
+	}
+}
+
+func (s *EngineTestSuite) TestRetryPending() {
+	now := time.Now()
+	s.dns.fail("down.example", _errTimeout)
+	_, changed, err := s.engine.block(s.ctx, "down.example", blockOpts{ttl: time.Hour, now: now})
+	s.Require().NoError(err)
+	s.True(changed)
+	r := s.rule("down.example")
+	s.Equal(now.Add(10*time.Second), r.NextAttempt)
+
+	// Still failing backs off further.
+	s.False(s.engine.retryPending(s.ctx, r, r.NextAttempt))
+	r = s.rule("down.example")
+	s.True(r.Pending)
+	s.Equal(2, r.Attempts)
+	s.Equal(now.Add(30*time.Second), r.NextAttempt)
+
+	// Resolving enforces it, keeping its expiry.
+	s.dns.answer("down.example", "192.0.2.1")
+	s.True(s.engine.retryPending(s.ctx, r, r.NextAttempt))
+	r = s.rule("down.example")
+	s.False(r.Pending)
+	s.Len(r.IPs, 1)
+	s.Equal(now.Add(time.Hour), r.Expires)
+}
+
+func (s *EngineTestSuite) TestRetryPendingNotFound() {
+	now := time.Now()
+	s.dns.fail("later.example", _errTimeout)
+	_, _, err := s.engine.block(s.ctx, "later.example", blockOpts{ttl: time.Hour, now: now})
+	s.Require().NoError(err)
+
+	// A name that does not exist is retried like any other, ever more rarely.
+	s.dns.fail("later.example", &dnsresolver.NegativeError{Name: "later.example", Err: dnsresolver.ErrNXDomain})
+	r := s.rule("later.example")
+	for range 10 {
+		at := r.NextAttempt
+		s.False(s.engine.retryPending(s.ctx, r, at))
+		r = s.rule("later.example")
+		s.True(r.Pending)
+		s.Contains(r.LastError, "no such domain")
+		s.Equal(at.Add(pendingBackoff(r.Attempts)), r.NextAttempt)
+	}
+	s.Equal(11, r.Attempts)
+	s.Equal(_pendingMaxBackoff, pendingBackoff(r.Attempts))
+
+	// Once it is registered, it is blocked.
+	s.dns.answer("later.example", "192.0.2.1")
+	s.True(s.engine.retryPending(s.ctx, r, r.NextAttempt))
+	s.False(s.rule("later.example").Pending)
+}
@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/multierr"
+
+	"github.com/lc/void/internal/log"
+	"github.com/lc/void/internal/schedule"
+)
+
+// ScheduleStore persists schedules across daemon restarts.
+type ScheduleStore interface {
+	Load() ([]schedule.Schedule, error)
+	Save([]schedule.Schedule) error
+}
+
+// WithScheduleStore persists schedules to s. Without it, schedules only
+// live as long as the daemon process.
+func WithScheduleStore(s ScheduleStore) Opt {
+	return func(e *Engine) {
+		e.schedStore = s
+	}
+}
+
+// AddSchedule registers a recurring block window and returns it with its
+// ID filled in. If the window is already open the domain is blocked at once.
+func (e *Engine) AddSchedule(ctx context.Context, s schedule.Schedule) (schedule.Schedule, error) {
+	if err := s.Validate(); err != nil {
+		return schedule.Schedule{}, err
+	}
+	s.ID = uuid.NewString()
+
+	reply := make(chan result, 1)
+	res, err := e.submit(ctx, addScheduleCmd{sched: s, reply: reply}, reply)
+	if err != nil {
+		return schedule.Schedule{}, err
+	}
+	return s, res.err
+}
+
+// RemoveSchedule deletes the schedule whose ID is or starts with ref, along
+// with any rule it currently has active. A locked rule is left to expire.
+func (e *Engine) RemoveSchedule(ctx context.Context, ref string) (schedule.Schedule, error) {
+	reply := make(chan result, 1)
+	res, err := e.submit(ctx, removeScheduleCmd{ref: ref, reply: reply}, reply)
+	if err != nil {
+		return schedule.Schedule{}, err
+	}
+	return res.sched, res.err
+}
+
+// Schedules returns all registered schedules ordered by domain.
+func (e *Engine) Schedules() []schedule.Schedule {
+	e.schedMu.RLock()
+	defer e.schedMu.RUnlock()
+
+	out := make([]schedule.Schedule, 0, len(e.schedules))
+	for _, s := range e.schedules {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Domain != out[j].Domain {
+			return out[i].Domain < out[j].Domain
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// --- Command Handlers (run only within runLoop) ---
+
+func (e *Engine) handleAddSchedule(ctx context.Context, cmd addScheduleCmd) (needsSync bool, err error) {
+	log.Infof("engine: adding schedule %s for %q (%s %s-%s)",
+		cmd.sched.ID, cmd.sched.Domain, cmd.sched.Days, cmd.sched.From, cmd.sched.To)
+
+	e.schedMu.Lock()
+	e.schedules[cmd.sched.ID] = cmd.sched
+	e.schedMu.Unlock()
+
+	if err := e.saveSchedules(); err != nil {
+		return false, err
+	}
+	// The schedule exists either way; a failed activation is retried shortly.
+	needsSync, err = e.activateSchedules(ctx, time.Now())
+	if err != nil {
+		log.Warnf("engine: schedule %s not yet active: %v", cmd.sched.ID, err)
+		e.retryLater()
+	}
+	return needsSync, nil
+}
+
+func (e *Engine) handleRemoveSchedule(_ context.Context, cmd removeScheduleCmd) (sched schedule.Schedule, needsSync bool, err error) {
+	sched, err = e.findSchedule(cmd.ref)
+	if err != nil {
+		return sched, false, err
+	}
+	log.Infof("engine: removing schedule %s for %q", sched.ID, sched.Domain)
+
+	e.schedMu.Lock()
+	delete(e.schedules, sched.ID)
+	e.schedMu.Unlock()
+
+	now := time.Now()
+	for _, r := range e.store.Snapshot() {
+		if r.ScheduleID != sched.ID {
+			continue
+		}
+		if r.Locked(now) {
+			log.Infof("engine: keeping locked rule ID %s of removed schedule %s", r.ID, sched.ID)
+			continue
+		}
+		if _, ok := e.remove(r.ID, "schedule removed"); ok {
+			log.Infof("engine: removed rule ID %s activated by schedule %s", r.ID, sched.ID)
+			needsSync = true
+		}
+	}
+	return sched, needsSync, e.saveSchedules()
+}
+
+// activateSchedules blocks the domain of every schedule whose window
+// contains now. The rule expires when the window closes, so deactivation
+// is handled by the ordinary expiry path. A rule that already outlives
+// the window (or is permanent) is left alone. The domains are resolved
+// concurrently, as the run loop waits for them.
+func (e *Engine) activateSchedules(ctx context.Context, now time.Time) (needsSync bool, err error) {
+	var (
+		due     []schedule.Schedule
+		ends    []time.Time
+		domains []string
+	)
+	for _, s := range e.Schedules() {
+		_, end, open := s.Window(now)
+		if !open {
+			continue
+		}
+		if cur, ok := e.store.ByDomain(s.Domain); ok && (cur.Permanent || !cur.Expires.Before(end)) {
+			continue
+		}
+		due, ends, domains = append(due, s), append(ends, end), append(domains, s.Domain)
+	}
+
+	resolved, errs := e.newRules(ctx, domains)
+	for i, s := range due {
+		if errs[i] != nil {
+			// Retried while the window is still open; see retryLater.
+			err = multierr.Append(err, fmt.Errorf("schedule %s: %w", s.ID, errs[i]))
+			continue
+		}
+		rule, end := resolved[i], ends[i]
+		rule.Expires = end
+		rule.ScheduleID = s.ID
+		if e.upsert(rule) {
+			log.Infof("engine: schedule %s activated for %s until %s", s.ID, s.Domain, end.Format(time.RFC3339))
+			needsSync = true
+		}
+	}
+	return needsSync, err
+}
+
+// findSchedule resolves an exact schedule ID or a unique ID prefix.
+func (e *Engine) findSchedule(ref string) (schedule.Schedule, error) {
+	e.schedMu.RLock()
+	defer e.schedMu.RUnlock()
+
+	if s, ok := e.schedules[ref]; ok {
+		return s, nil
+	}
+	var (
+		match schedule.Schedule
+		found bool
+	)
+	for id, s := range e.schedules {
+		if ref == "" || !strings.HasPrefix(id, ref) {
+			continue
+		}
+		if found {
+			return schedule.Schedule{}, fmt.Errorf("%w: %q", ErrAmbiguousRef, ref)
+		}
+		match, found = s, true
+	}
+	if !found {
+		return schedule.Schedule{}, fmt.Errorf("%w: schedule %q", ErrRuleNotFound, ref)
+	}
+	return match, nil
+}
+
+// loadSchedules restores persisted schedules on startup.
+func (e *Engine) loadSchedules() error {
+	if e.schedStore == nil {
+		return nil
+	}
+	list, err := e.schedStore.Load()
+	if err != nil {
+		return fmt.Errorf("loading schedules: %w", err)
+	}
+
+	e.schedMu.Lock()
+	defer e.schedMu.Unlock()
+	for _, s := range list {
+		if err := s.Validate(); err != nil {
+			log.Warnf("engine: skipping invalid schedule %s: %v", s.ID, err)
+			continue
+		}
+		e.schedules[s.ID] = s
+	}
+	log.Infof("engine: loaded %d schedules", len(e.schedules))
+	return nil
+}
+
+// saveSchedules persists the current schedules, if a store is configured.
+func (e *Engine) saveSchedules() error {
+	if e.schedStore == nil {
+		return nil
+	}
+	if err := e.schedStore.Save(e.Schedules()); err != nil {
+		return fmt.Errorf("saving schedules: %w", err)
+	}
+	return nil
+}
+
+type addScheduleCmd struct {
+	sched schedule.Schedule
+	reply chan<- result
+}
+
+func (c addScheduleCmd) respond(r result) { c.reply <- r }
+
+type removeScheduleCmd struct {
+	ref   string
+	reply chan<- result
+}
+
+func (c removeScheduleCmd) respond(r result) { c.reply <- r }
@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/lc/void/internal/schedule"
+)
+
+// openSchedule returns a daily schedule for domain whose window opened an
+// hour ago and closes in two.
+func openSchedule(domain string) schedule.Schedule {
+	now := time.Now()
+	c := schedule.Clock(now.Hour()*60 + now.Minute())
+	return schedule.Schedule{Domain: domain, Days: 0x7f, From: (c + 23*60) % (24 * 60), To: (c + 2*60) % (24 * 60)}
+}
+
+func (s *EngineTestSuite) TestRemoveScheduleKeepsOwnBlocks() {
+	s.start()
+	for _, d := range []string{"before.com", "after.com", "only.com"} {
+		s.dns.answer(d, "192.0.2.1")
+	}
+
+	// The user blocks one domain before its schedule opens and one after.
+	_, err := s.engine.BlockDomain(s.ctx, "before.com", time.Minute, 0, "")
+	s.Require().NoError(err)
+	var ids []string
+	for _, d := range []string{"before.com", "after.com", "only.com"} {
+		sched, err := s.engine.AddSchedule(s.ctx, openSchedule(d))
+		s.Require().NoError(err)
+		ids = append(ids, sched.ID)
+	}
+	_, err = s.engine.BlockDomain(s.ctx, "after.com", time.Minute, 0, "")
+	s.Require().NoError(err)
+	s.Empty(s.rule("before.com").ScheduleID)
+	s.Empty(s.rule("after.com").ScheduleID)
+	s.Equal(ids[2], s.rule("only.com").ScheduleID)
+	s.WithinDuration(time.Now().Add(2*time.Hour), s.rule("before.com").Expires, 2*time.Minute, "the schedule still extends it")
+
+	for _, id := range ids {
+		_, err := s.engine.RemoveSchedule(s.ctx, id)
+		s.Require().NoError(err)
+	}
+	s.ElementsMatch([]string{"before.com", "after.com"}, s.pf.synced())
+}
+
+func (s *EngineTestSuite) TestActivateSchedulesConcurrently() {
+	s.dns.hold = 20 * time.Millisecond
+	for _, d := range []string{"a.com", "b.com", "c.com"} {
+		s.dns.answer(d, "192.0.2.1")
+		sched := openSchedule(d)
+		sched.ID = d
+		s.engine.schedules[sched.ID] = sched
+	}
+	needsSync, err := s.engine.activateSchedules(s.ctx, time.Now())
+	s.Require().NoError(err)
+	s.True(needsSync)
+	s.Len(s.engine.Snapshot(), 3)
+	s.Greater(s.dns.concurrency(), 1, "resolved one after another")
+}
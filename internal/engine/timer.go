@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/lc/void/internal/focus"
+	"github.com/lc/void/internal/rules"
+)
+
+// refreshAfter is how long after resolution a rule's DNS is refreshed:
+// slightly before its records' TTL runs out, kept within the configured
+// bounds, or before the refresh interval runs out if the TTL is unknown.
+func (e *Engine) refreshAfter(r rules.Rule) time.Duration {
+	interval := e.dnsRefresh
+	if r.TTL > 0 {
+		interval = min(max(r.TTL, e.minRefresh), max(e.maxRefresh, e.minRefresh))
+	}
+	return interval * 9 / 10
+}
+
+// nextWake returns when time-driven work is next due: a rule expiring or
+// needing a DNS refresh, a schedule window opening, a focus phase ending,
+// or a retry of work that failed. ok is false if nothing is pending.
+func (e *Engine) nextWake(now time.Time) (next time.Time, ok bool) {
+	consider := func(t time.Time) {
+		if !t.IsZero() && (!ok || t.Before(next)) {
+			next, ok = t, true
+		}
+	}
+
+	if t, found := e.store.NextExpiry(); found {
+		consider(t)
+	}
+	if t, found := e.store.NextRefresh(e.refreshAfter); found {
+		// A refresh that just failed is still overdue; wait for the retry.
+		if t.Before(e.retryAt) {
+			t = e.retryAt
+		}
+		consider(t)
+	}
+	for _, s := range e.Schedules() {
+		consider(s.Next(now))
+	}
+	if sess, running := e.Session(); running {
+		if st := sess.At(now); st.Phase == focus.PhaseDone {
+			consider(now) // clear it out
+		} else {
+			consider(st.Ends)
+		}
+	}
+	consider(e.retryAt)
+	return next, ok
+}
+
+// retryLater makes the runLoop retry its time-driven work (DNS refresh,
+// schedule and focus activation) after _retryInterval.
+func (e *Engine) retryLater() {
+	e.retryAt = time.Now().Add(_retryInterval)
+}
+
+// rearm resets the runLoop's wake timer to the next deadline, capped at
+// _maxSleep, or stops it when nothing is pending.
+func (e *Engine) rearm(t *time.Timer, now time.Time) {
+	next, ok := e.nextWake(now)
+	if !ok {
+		t.Stop()
+		return
+	}
+	t.Reset(min(max(next.Sub(now), 0), _maxSleep))
+}
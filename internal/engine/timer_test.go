@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/lc/void/internal/rules"
+)
+
+func (s *EngineTestSuite) TestRefreshAfter() {
+	WithRefreshBounds(time.Minute, 30*time.Minute)(s.engine)
+
+	testCases := []struct {
+		name string
+		ttl  time.Duration
+		want time.Duration
+	}{
+		{name: "unknown TTL uses the refresh interval", ttl: 0, want: 54 * time.Minute},
+		{name: "short TTL is raised to the minimum", ttl: time.Second, want: 54 * time.Second},
+		{name: "TTL within bounds", ttl: 10 * time.Minute, want: 9 * time.Minute},
+		{name: "long TTL is lowered to the maximum", ttl: 2 * time.Hour, want: 27 * time.Minute},
+	}
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			s.Equal(tc.want, s.engine.refreshAfter(rules.Rule{TTL: tc.ttl}))
+		})
+	}
+
+	// A maximum below the minimum gives way to it.
+	WithRefreshBounds(10*time.Minute, 5*time.Minute)(s.engine)
+	s.Equal(9*time.Minute, s.engine.refreshAfter(rules.Rule{TTL: time.Hour}))
+}
+
+func (s *EngineTestSuite) TestNextWake() {
+	now := time.Now()
+	_, ok := s.engine.nextWake(now)
+	s.False(ok, "nothing to do")
+
+	// A permanent rule is due for a DNS refresh.
+	s.engine.store.Upsert(&rules.Rule{ID: "p", Domain: "p.com", Permanent: true, ResolvedAt: now, TTL: 10 * time.Minute})
+	next, ok := s.engine.nextWake(now)
+	s.True(ok)
+	s.Equal(now.Add(9*time.Minute), next)
+
+	// An expiry before that comes first.
+	s.engine.store.Upsert(&rules.Rule{ID: "t", Domain: "t.com", Expires: now.Add(time.Minute), ResolvedAt: now})
+	next, _ = s.engine.nextWake(now)
+	s.Equal(now.Add(time.Minute), next)
+
+	// A pending rule wakes the loop for its next attempt.
+	s.engine.store.Upsert(&rules.Rule{ID: "r", Domain: "r.com", Permanent: true, Pending: true, NextAttempt: now.Add(10 * time.Second)})
+	next, _ = s.engine.nextWake(now)
+	s.Equal(now.Add(10*time.Second), next)
+
+	// An overdue refresh that failed waits for the retry.
+	s.engine.store.Remove("r")
+	s.engine.store.Remove("t")
+	s.engine.retryAt = now.Add(20 * time.Minute)
+	next, _ = s.engine.nextWake(now.Add(15 * time.Minute))
+	s.Equal(now.Add(20*time.Minute), next)
+}
+
+func (s *EngineTestSuite) TestRearm() {
+	now := time.Now()
+	t := time.NewTimer(time.Hour)
+	defer t.Stop()
+
+	// Nothing pending stops the timer.
+	s.engine.rearm(t, now)
+	s.False(t.Stop(), "timer should already be stopped")
+
+	// Something overdue fires it at once.
+	s.engine.store.Upsert(&rules.Rule{ID: "t", Domain: "t.com", Expires: now.Add(-time.Second), ResolvedAt: now})
+	s.engine.rearm(t, now)
+	select {
+	case <-t.C:
+	case <-time.After(time.Second):
+		s.Fail("timer did not fire for an overdue expiry")
+	}
+}
@@ -0,0 +1,223 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/lc/void/internal/dnsresolver"
+	"github.com/lc/void/internal/rules"
+)
+
+// _defaultWildcardPrefixes are the subdomain labels probed when a wildcard
+// rule is created and no prefixes were configured.
+var _defaultWildcardPrefixes = []string{
+	"www", "m", "mobile", "api", "app", "cdn", "static",
+	"img", "images", "media", "video", "old", "new", "login",
+}
+
+// _maxParallelLookups bounds the concurrent lookups made for one rule.
+const _maxParallelLookups = 8
+
+// LookupRounds returns how many lookups in a row blocking domains together,
+// as a group is blocked, takes at most. Up to _maxParallelLookups domains,
+// and as many names of each wildcard domain, are resolved at once, and
+// wildcard domains go first. A wildcard domain covers its apex and one name
+// per prefix, as given to WithWildcardPrefixes; names its rule has learned
+// since are not counted.
+func LookupRounds(domains, prefixes []string) int {
+	if len(prefixes) == 0 {
+		prefixes = _defaultWildcardPrefixes
+	}
+	var wild, plain int
+	for _, domain := range domains {
+		if rules.IsWildcard(domain) {
+			wild++
+		} else {
+			plain++
+		}
+	}
+	batches := func(n int) int { return (n + _maxParallelLookups - 1) / _maxParallelLookups }
+	return batches(wild)*batches(1+len(prefixes)) + batches(plain)
+}
+
+// wildcardHosts returns the candidate hostnames for a wildcard rule: the
+// zone apex, every configured prefix under it, and any names an existing
+// rule for the same zone has already learned.
+func (e *Engine) wildcardHosts(domain string) []string {
+	zone := strings.TrimPrefix(domain, rules.WildcardPrefix)
+	hosts := []string{zone}
+	for _, p := range e.wildcardPrefixes {
+		hosts = append(hosts, p+"."+zone)
+	}
+	if cur, ok := e.store.ByDomain(domain); ok {
+		hosts = append(hosts, cur.Hosts...)
+	}
+	slices.Sort(hosts)
+	return slices.Compact(hosts)
+}
+
+// coveringWildcard returns the wildcard rule that covers host and lives at
+// least as long as a new rule blocked under o would, so that folding the
+// host into it never shortens the block the caller asked for.
+func (e *Engine) coveringWildcard(host string, o blockOpts) (rules.Rule, bool) {
+	name := strings.ToLower(strings.TrimSuffix(host, "."))
+	for {
+		if w, ok := e.store.ByDomain(rules.WildcardPrefix + name); ok {
+			if w.Permanent || (o.ttl > 0 && !w.Expires.Before(o.now.Add(o.ttl))) {
+				return w, true
+			}
+			return rules.Rule{}, false
+		}
+		i := strings.IndexByte(name, '.')
+		if i < 0 {
+			return rules.Rule{}, false
+		}
+		name = name[i+1:]
+	}
+}
+
+// learnHost adds host, which resolved to res, to the wildcard rule for
+// wildcard and blocks its addresses under that rule, so the zone stays a
+// single rule in PF and in listings. The rule is read afresh, so hosts
+// learned by other members of the same command are kept.
+func (e *Engine) learnHost(wildcard, host string, res dnsresolver.Resolution) (id string, needsSync bool, err error) {
+	w, ok := e.store.ByDomain(wildcard)
+	if !ok {
+		return "", false, fmt.Errorf("%w: %q", ErrRuleNotFound, wildcard)
+	}
+	if slices.Contains(w.Hosts, host) {
+		return w.ID, false, nil
+	}
+
+	w.Hosts = append(slices.Clone(w.Hosts), host)
+	w.Pending = false // a pending wildcard is enforced from its first host on
+	w.CNAMEs = unionNames(w.CNAMEs, res.CNAMEs)
+	w.Targets = unionNames(w.Targets, res.Targets)
+	w.IPs = unionIPs(w.IPs, res.Addrs)
+	w.Sources = unionSources(w.Sources, res.Sources)
+	if res.TTL > 0 && (w.TTL == 0 || res.TTL < w.TTL) {
+		w.TTL = res.TTL
+	}
+	changed := e.upsert(&w)
+	return w.ID, changed, nil
+}
+
+// ruleResolution is what resolving the names covered by a rule yields.
+type ruleResolution struct {
+	ips     []net.IPAddr
+	hosts   []string            // wildcard hosts that resolved, in rule order
+	cnames  []string            // alias targets seen across all names
+	targets []string            // HTTPS service targets seen across all names
+	ttl     time.Duration       // lowest DNS TTL across all names; 0 if unknown
+	sources map[string][]string // upstreams behind each IP, for union resolution
+}
+
+// resolveRule resolves every name a rule covers with the rule's strategy.
+// For a plain rule that is just its domain; for a wildcard rule it is each
+// tracked host, looked up concurrently. An error is returned only if
+// nothing resolved.
+func (e *Engine) resolveRule(ctx context.Context, r rules.Rule) (ruleResolution, error) {
+	ctx = dnsresolver.ContextWithStrategy(ctx, dnsresolver.Strategy(r.Strategy))
+	if !r.Wildcard() {
+		res, err := e.resolver.Resolve(ctx, r.Domain)
+		if err != nil {
+			return ruleResolution{}, err
+		}
+		return ruleResolution{ips: res.Addrs, cnames: res.CNAMEs, targets: res.Targets, ttl: res.TTL, sources: res.Sources}, nil
+	}
+	if len(r.Hosts) == 0 {
+		return ruleResolution{}, fmt.Errorf("wildcard rule %q tracks no hosts", r.Domain)
+	}
+
+	var (
+		mu      sync.Mutex
+		errs    error
+		results = make([]dnsresolver.Resolution, len(r.Hosts))
+	)
+	grp, gctx := errgroup.WithContext(ctx)
+	grp.SetLimit(_maxParallelLookups)
+	for i, host := range r.Hosts {
+		grp.Go(func() error {
+			res, err := e.resolver.Resolve(gctx, host)
+			if err != nil {
+				mu.Lock()
+				errs = multierr.Append(errs, err)
+				mu.Unlock()
+				return nil // one dead name must not cancel the others
+			}
+			results[i] = res
+			return nil
+		})
+	}
+	_ = grp.Wait() // goroutines never return errors
+
+	var out ruleResolution
+	for i, res := range results {
+		if len(res.Addrs) == 0 {
+			continue
+		}
+		out.hosts = append(out.hosts, r.Hosts[i])
+		out.ips = unionIPs(out.ips, res.Addrs)
+		out.cnames = unionNames(out.cnames, res.CNAMEs)
+		out.targets = unionNames(out.targets, res.Targets)
+		out.sources = unionSources(out.sources, res.Sources)
+		if res.TTL > 0 && (out.ttl == 0 || res.TTL < out.ttl) {
+			out.ttl = res.TTL
+		}
+	}
+	if len(out.ips) == 0 {
+		return ruleResolution{}, errs
+	}
+	return out, nil
+}
+
+// unionNames returns a followed by every name in b not already present.
+func unionNames(a, b []string) []string {
+	out := slices.Clone(a)
+	for _, n := range b {
+		if !slices.Contains(out, n) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// unionSources returns the upstreams behind each IP in either a or b. It
+// returns nil if both are empty.
+func unionSources(a, b map[string][]string) map[string][]string {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+	out := make(map[string][]string, len(a)+len(b))
+	for _, m := range [...]map[string][]string{a, b} {
+		for ip, upstreams := range m {
+			out[ip] = unionNames(out[ip], upstreams)
+		}
+	}
+	return out
+}
+
+// unionIPs returns a followed by every address in b not already present.
+func unionIPs(a, b []net.IPAddr) []net.IPAddr {
+	seen := make(map[string]struct{}, len(a)+len(b))
+	out := make([]net.IPAddr, 0, len(a)+len(b))
+	for _, list := range [...][]net.IPAddr{a, b} {
+		for _, ip := range list {
+			k := ipKey(ip)
+			if _, dup := seen[k]; dup {
+				continue
+			}
+			seen[k] = struct{}{}
+			out = append(out, ip)
+		}
+	}
+	return out
+}
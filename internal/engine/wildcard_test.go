@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lc/void/internal/rules"
+)
+
+func (s *EngineTestSuite) TestCoveringWildcard() {
+	now := time.Now()
+	s.engine.store.Upsert(&rules.Rule{ID: "w", Domain: "*.example.com", Expires: now.Add(time.Hour)})
+	for30m := blockOpts{ttl: 30 * time.Minute, now: now}
+
+	w, ok := s.engine.coveringWildcard("www.Example.com.", for30m)
+	s.True(ok)
+	s.Equal("w", w.ID)
+	_, ok = s.engine.coveringWildcard("a.b.example.com", for30m)
+	s.True(ok, "any depth is covered")
+
+	// Folding a host in must not end its block sooner than asked.
+	_, ok = s.engine.coveringWildcard("www.example.com", blockOpts{ttl: 2 * time.Hour, now: now})
+	s.False(ok)
+	_, ok = s.engine.coveringWildcard("www.example.com", blockOpts{ttl: time.Hour, now: now})
+	s.True(ok, "a rule blocked at the same instant for as long")
+	_, ok = s.engine.coveringWildcard("www.example.com", blockOpts{now: now})
+	s.False(ok, "a permanent block outlives any expiring rule")
+	_, ok = s.engine.coveringWildcard("example.org", for30m)
+	s.False(ok)
+}
+
+func (s *EngineTestSuite) TestLearnHost() {
+	s.dns.answer("example.com", "192.0.2.1")
+	s.dns.answer("www.example.com", "192.0.2.2")
+	s.dns.answer("mail.example.com", "192.0.2.3")
+	s.dns.answer("chat.example.com", "192.0.2.4")
+	o := blockOpts{now: time.Now()}
+
+	id, changed, err := s.engine.block(s.ctx, "*.example.com", o)
+	s.Require().NoError(err)
+	s.True(changed)
+	w := s.rule("*.example.com")
+	s.Equal([]string{"example.com", "www.example.com"}, w.Hosts, "only names that exist are kept")
+
+	// A host under the wildcard joins it rather than getting a rule.
+	got, changed, err := s.engine.block(s.ctx, "mail.example.com", o)
+	s.Require().NoError(err)
+	s.True(changed)
+	s.Equal(id, got)
+	w = s.rule("*.example.com")
+	s.Contains(w.Hosts, "mail.example.com")
+	s.Len(w.IPs, 3)
+	_, ok := s.engine.store.ByDomain("mail.example.com")
+	s.False(ok)
+
+	// Blocking it again changes nothing and resolves nothing.
+	calls := s.dns.lookups("mail.example.com")
+	_, changed, err = s.engine.block(s.ctx, "mail.example.com", o)
+	s.Require().NoError(err)
+	s.False(changed)
+	s.Equal(calls, s.dns.lookups("mail.example.com"))
+
+	// A host blocked through another group keeps a rule of its own.
+	o.group = "work"
+	got, _, err = s.engine.block(s.ctx, "chat.example.com", o)
+	s.Require().NoError(err)
+	s.NotEqual(id, got)
+	s.Equal("work", s.rule("chat.example.com").Group)
+	s.NotContains(s.rule("*.example.com").Hosts, "chat.example.com")
+}
+
+func (s *EngineTestSuite) TestWildcardNotFound() {
+	o := blockOpts{now: time.Now()}
+
+	// Every name missing is a typo.
+	_, _, err := s.engine.block(s.ctx, "*.typo.example", o)
+	s.ErrorIs(err, ErrNoSuchDomain)
+
+	// Some names missing and the others failing may pass.
+	s.dns.fail("down.example", _errTimeout)
+	_, _, err = s.engine.block(s.ctx, "*.down.example", o)
+	s.Require().NoError(err)
+	s.True(s.rule("*.down.example").Pending)
+}
+
+func (s *EngineTestSuite) TestLookupRounds() {
+	s.Equal(0, LookupRounds(nil, nil))
+	s.Equal(1, LookupRounds([]string{"a.com"}, nil))
+	s.Equal(2, LookupRounds([]string{"*.a.com"}, nil), "apex and 14 default prefixes")
+	s.Equal(1, LookupRounds([]string{"*.a.com"}, []string{"www", "api"}))
+
+	members := []string{"*.a.com", "*.b.com"}
+	for i := range 9 {
+		members = append(members, fmt.Sprintf("d%d.com", i))
+	}
+	s.Equal(1+2, LookupRounds(members, []string{"www"}), "wildcards resolve side by side, then nine domains in two batches")
+}
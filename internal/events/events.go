@@ -0,0 +1,141 @@
+// Package events is a small in-process publish/subscribe bus the Void
+// engine uses to report what it does: rules coming and going, DNS refresh
+// results and pf sync outcomes. Publishing never blocks; a subscriber that
+// falls behind loses events and can tell how many from Dropped.
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// Type identifies what happened.
+type Type string
+
+// Event types published by the engine.
+const (
+	RuleAdded        Type = "rule.added"
+	RulePending      Type = "rule.pending" // added, but waiting for DNS to resolve
+	RuleUpdated      Type = "rule.updated"
+	RuleExpired      Type = "rule.expired"
+	RuleRemoved      Type = "rule.removed"
+	DNSRefreshed     Type = "dns.refreshed"
+	DNSRefreshFailed Type = "dns.refresh_failed"
+	PFSynced         Type = "pf.synced" // pf reloaded the anchor
+	PFSyncFailed     Type = "pf.sync_failed"
+)
+
+// Event is a single notification. Rule fields are empty for events that
+// don't concern one rule, such as pf syncs.
+type Event struct {
+	Seq    uint64    `json:"seq"` // assigned by the bus, increasing
+	Time   time.Time `json:"time"`
+	Type   Type      `json:"type"`
+	RuleID string    `json:"rule_id,omitempty"`
+	Domain string    `json:"domain,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+	Error  string    `json:"error,omitempty"`
+	Actor  *Actor    `json:"actor,omitempty"`  // who caused it; nil for the daemon's own work
+	Reason string    `json:"reason,omitempty"` // why, as given by the caller
+}
+
+// Actor identifies the local user and process behind a change.
+type Actor struct {
+	UID  uint32 `json:"uid"`
+	GID  uint32 `json:"gid"`
+	PID  int32  `json:"pid,omitempty"`
+	User string `json:"user,omitempty"`
+}
+
+// String returns e.g. "alice (uid 501, pid 4242)".
+func (a Actor) String() string {
+	id := fmt.Sprintf("uid %d", a.UID)
+	if a.PID != 0 {
+		id += fmt.Sprintf(", pid %d", a.PID)
+	}
+	if a.User == "" {
+		return id
+	}
+	return fmt.Sprintf("%s (%s)", a.User, id)
+}
+
+// DefaultBuffer is the per-subscriber queue length used by Subscribe
+// when given a non-positive size.
+const DefaultBuffer = 64
+
+// Bus fans events out to subscribers. The zero value is not usable; use NewBus.
+type Bus struct {
+	mu   sync.Mutex
+	seq  uint64
+	subs map[*Subscription]struct{}
+}
+
+// NewBus returns an empty bus.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Publish stamps e with the next sequence number (and the current time if
+// unset) and delivers it to every subscriber that has room for it. It
+// returns the stamped event.
+func (b *Bus) Publish(e Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	e.Seq = b.seq
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	for s := range b.subs {
+		select {
+		case s.c <- e:
+		default:
+			s.dropped.Inc()
+		}
+	}
+	return e
+}
+
+// Subscribe registers a subscriber with a queue of buf events. The caller
+// must Close the subscription when done.
+func (b *Bus) Subscribe(buf int) *Subscription {
+	if buf <= 0 {
+		buf = DefaultBuffer
+	}
+	c := make(chan Event, buf)
+	s := &Subscription{C: c, c: c, bus: b}
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+	return s
+}
+
+// Subscription receives events published after it was created.
+type Subscription struct {
+	// C delivers events in publish order. It is closed by Close.
+	C <-chan Event
+
+	c       chan Event
+	bus     *Bus
+	dropped atomic.Uint64
+	once    sync.Once
+}
+
+// Dropped returns how many events were discarded because C was full.
+func (s *Subscription) Dropped() uint64 { return s.dropped.Load() }
+
+// Close unregisters the subscription and closes C. It is safe to call
+// more than once.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subs, s)
+		s.bus.mu.Unlock()
+		close(s.c)
+	})
+}
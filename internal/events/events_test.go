@@ -0,0 +1,62 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type BusTestSuite struct {
+	suite.Suite
+	bus *Bus
+}
+
+func (s *BusTestSuite) SetupTest() {
+	s.bus = NewBus()
+}
+
+func (s *BusTestSuite) TestFanOut() {
+	a := s.bus.Subscribe(4)
+	b := s.bus.Subscribe(4)
+	defer a.Close()
+	defer b.Close()
+
+	s.bus.Publish(Event{Type: RuleAdded, Domain: "x.com"})
+	s.bus.Publish(Event{Type: PFSynced})
+
+	for _, sub := range []*Subscription{a, b} {
+		first, second := <-sub.C, <-sub.C
+		s.Equal(RuleAdded, first.Type)
+		s.Equal("x.com", first.Domain)
+		s.False(first.Time.IsZero())
+		s.Equal(PFSynced, second.Type)
+		s.Less(first.Seq, second.Seq)
+	}
+}
+
+func (s *BusTestSuite) TestSlowSubscriberDrops() {
+	sub := s.bus.Subscribe(1)
+	defer sub.Close()
+
+	// Publishing must not block even though nobody reads.
+	for range 3 {
+		s.bus.Publish(Event{Type: RuleUpdated})
+	}
+	s.Equal(uint64(2), sub.Dropped())
+	e := <-sub.C
+	s.Equal(uint64(1), e.Seq)
+}
+
+func (s *BusTestSuite) TestClose() {
+	sub := s.bus.Subscribe(1)
+	sub.Close()
+	sub.Close() // idempotent
+
+	_, ok := <-sub.C
+	s.False(ok)
+	s.bus.Publish(Event{Type: RuleRemoved}) // no panic on closed subscriber
+}
+
+func TestBusSuite(t *testing.T) {
+	suite.Run(t, new(BusTestSuite))
+}
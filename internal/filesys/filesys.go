@@ -5,6 +5,8 @@
 package filesys
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
@@ -116,3 +118,32 @@ func AtomicWrite(fs FileOps, dst string, data []byte, perm fs.FileMode) error {
 	}
 	return nil
 }
+
+// ReadJSON decodes the JSON file at path into v. A missing file is not an
+// error and leaves v untouched, so callers can treat it as "no data yet".
+func ReadJSON(fs FileOps, path string, v any) error {
+	data, err := fs.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteJSON atomically replaces the file at path with v encoded as
+// indented JSON, creating the parent directory if needed.
+func WriteJSON(fs FileOps, path string, v any, perm os.FileMode) error {
+	if err := fs.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return AtomicWrite(fs, path, append(data, '\n'), perm)
+}
@@ -0,0 +1,120 @@
+// Package focus models pomodoro-style focus sessions: a fixed number of
+// cycles, each a work phase during which domains are blocked followed by a
+// break during which they are not. Like package schedule it only answers
+// questions about time; blocking is the engine's job.
+//
+// The phase at any instant is derived from the session's start time, so a
+// session needs no timer state of its own and a late tick simply observes
+// the phase it lands in, and a session saved before a restart resumes
+// where the clock says it should be.
+package focus
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lc/void/internal/filesys"
+)
+
+// DefaultPath is where the daemon keeps the running session, next to the pf anchor.
+const DefaultPath = "/etc/pf.anchors/void.focus"
+
+// MinPhase is the shortest allowed work or break phase.
+const MinPhase = time.Minute
+
+// ErrInvalidSession is returned for a session that cannot run.
+var ErrInvalidSession = errors.New("invalid focus session")
+
+// Phase is the part of a cycle a session is in.
+type Phase string
+
+const (
+	// PhaseWork means the session's domains are blocked.
+	PhaseWork Phase = "work"
+	// PhaseBreak means the session's domains are reachable.
+	PhaseBreak Phase = "break"
+	// PhaseDone means every cycle has finished.
+	PhaseDone Phase = "done"
+)
+
+// Session alternates Cycles work phases with breaks between them. The last
+// work phase is not followed by a break.
+type Session struct {
+	ID      string        `json:"id"`
+	Domains []string      `json:"domains"`
+	Group   string        `json:"group,omitempty"` // group the domains came from, if any
+	Work    time.Duration `json:"work"`
+	Break   time.Duration `json:"break"`
+	Cycles  int           `json:"cycles"`
+	Started time.Time     `json:"started"`
+}
+
+// State describes a session at a given instant.
+type State struct {
+	Phase Phase     `json:"phase"`
+	Cycle int       `json:"cycle"` // 1-based; equals Cycles once done
+	Ends  time.Time `json:"ends"`  // end of the current phase
+}
+
+// Validate reports whether the session can run.
+func (s Session) Validate() error {
+	switch {
+	case len(s.Domains) == 0:
+		return fmt.Errorf("%w: no domains", ErrInvalidSession)
+	case s.Work < MinPhase:
+		return fmt.Errorf("%w: work phase must be at least %v", ErrInvalidSession, MinPhase)
+	case s.Break < MinPhase:
+		return fmt.Errorf("%w: break phase must be at least %v", ErrInvalidSession, MinPhase)
+	case s.Cycles < 1:
+		return fmt.Errorf("%w: at least one cycle required", ErrInvalidSession)
+	}
+	return nil
+}
+
+// End returns when the last work phase finishes.
+func (s Session) End() time.Time {
+	n := time.Duration(s.Cycles)
+	return s.Started.Add(n*s.Work + (n-1)*s.Break)
+}
+
+// At returns the session's state at t. Instants before Started count as
+// the first work phase.
+func (s Session) At(t time.Time) State {
+	if end := s.End(); !t.Before(end) {
+		return State{Phase: PhaseDone, Cycle: s.Cycles, Ends: end}
+	}
+	elapsed := max(t.Sub(s.Started), 0)
+	period := s.Work + s.Break
+	n := elapsed / period
+	start := s.Started.Add(n * period)
+
+	if elapsed-n*period < s.Work {
+		return State{Phase: PhaseWork, Cycle: int(n) + 1, Ends: start.Add(s.Work)}
+	}
+	return State{Phase: PhaseBreak, Cycle: int(n) + 1, Ends: start.Add(period)}
+}
+
+// FileStore persists the running session as JSON using crash-safe writes.
+type FileStore struct {
+	fs   filesys.FileOps
+	path string
+}
+
+// NewFileStore returns a FileStore backed by the local file at path.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{fs: filesys.OS(), path: path}
+}
+
+// Load reads the saved session. A missing file, or one saved without a
+// session, yields nil.
+func (f *FileStore) Load() (*Session, error) {
+	var out *Session
+	err := filesys.ReadJSON(f.fs, f.path, &out)
+	return out, err
+}
+
+// Save atomically replaces the saved session; nil records that none runs.
+func (f *FileStore) Save(s *Session) error {
+	return filesys.WriteJSON(f.fs, f.path, s, 0o644)
+}
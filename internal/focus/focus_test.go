@@ -0,0 +1,86 @@
+package focus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FocusTestSuite struct {
+	suite.Suite
+	start time.Time
+}
+
+func (s *FocusTestSuite) SetupTest() {
+	s.start = time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+}
+
+func (s *FocusTestSuite) session() Session {
+	return Session{
+		Domains: []string{"x.com"},
+		Work:    50 * time.Minute,
+		Break:   10 * time.Minute,
+		Cycles:  2,
+		Started: s.start,
+	}
+}
+
+func (s *FocusTestSuite) TestValidate() {
+	testCases := []struct {
+		name   string
+		modify func(*Session)
+		err    bool
+	}{
+		{name: "valid", modify: func(*Session) {}},
+		{name: "no domains", modify: func(ss *Session) { ss.Domains = nil }, err: true},
+		{name: "short work", modify: func(ss *Session) { ss.Work = time.Second }, err: true},
+		{name: "short break", modify: func(ss *Session) { ss.Break = 0 }, err: true},
+		{name: "no cycles", modify: func(ss *Session) { ss.Cycles = 0 }, err: true},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			ss := s.session()
+			tc.modify(&ss)
+			err := ss.Validate()
+			s.Equal(tc.err, err != nil, "err: %v", err)
+			if tc.err {
+				s.ErrorIs(err, ErrInvalidSession)
+			}
+		})
+	}
+}
+
+func (s *FocusTestSuite) TestEnd() {
+	// Two 50m work phases with one 10m break in between.
+	s.Equal(s.start.Add(110*time.Minute), s.session().End())
+}
+
+func (s *FocusTestSuite) TestAt() {
+	at := func(m time.Duration) time.Time { return s.start.Add(m * time.Minute) }
+
+	testCases := []struct {
+		name     string
+		t        time.Time
+		expected State
+	}{
+		{name: "before start", t: at(-5), expected: State{Phase: PhaseWork, Cycle: 1, Ends: at(50)}},
+		{name: "start", t: at(0), expected: State{Phase: PhaseWork, Cycle: 1, Ends: at(50)}},
+		{name: "first break", t: at(50), expected: State{Phase: PhaseBreak, Cycle: 1, Ends: at(60)}},
+		{name: "second work", t: at(60), expected: State{Phase: PhaseWork, Cycle: 2, Ends: at(110)}},
+		{name: "last minute", t: at(109), expected: State{Phase: PhaseWork, Cycle: 2, Ends: at(110)}},
+		{name: "done", t: at(110), expected: State{Phase: PhaseDone, Cycle: 2, Ends: at(110)}},
+		{name: "long after", t: at(500), expected: State{Phase: PhaseDone, Cycle: 2, Ends: at(110)}},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			s.Equal(tc.expected, s.session().At(tc.t))
+		})
+	}
+}
+
+func TestFocusSuite(t *testing.T) {
+	suite.Run(t, new(FocusTestSuite))
+}
@@ -0,0 +1,77 @@
+// Package group defines named sets of domains ("profiles") that the Void
+// daemon blocks and unblocks as a unit, and persists them across restarts.
+package group
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/lc/void/internal/filesys"
+)
+
+// DefaultPath is where the daemon keeps group definitions, next to the pf anchor.
+const DefaultPath = "/etc/pf.anchors/void.groups"
+
+// ErrInvalidName is returned for a group name that is empty or malformed.
+var ErrInvalidName = errors.New("invalid group name")
+
+// _nameRE restricts names to something safe to show in a pf anchor comment
+// and easy to type on a command line.
+var _nameRE = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Group is a named set of domains.
+type Group struct {
+	Name    string   `json:"name"`
+	Domains []string `json:"domains"`
+}
+
+// New returns a group with a normalized name and a sorted, de-duplicated,
+// lower-cased domain list.
+func New(name string, domains []string) Group {
+	g := Group{Name: strings.ToLower(strings.TrimSpace(name))}
+	for _, d := range domains {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			g.Domains = append(g.Domains, d)
+		}
+	}
+	slices.Sort(g.Domains)
+	g.Domains = slices.Compact(g.Domains)
+	return g
+}
+
+// Validate reports whether the group is usable.
+func (g Group) Validate() error {
+	if !_nameRE.MatchString(g.Name) {
+		return fmt.Errorf("%w: %q", ErrInvalidName, g.Name)
+	}
+	if len(g.Domains) == 0 {
+		return fmt.Errorf("group %q has no domains", g.Name)
+	}
+	return nil
+}
+
+// FileStore persists groups as JSON using crash-safe writes.
+type FileStore struct {
+	fs   filesys.FileOps
+	path string
+}
+
+// NewFileStore returns a FileStore backed by the local file at path.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{fs: filesys.OS(), path: path}
+}
+
+// Load reads all groups. A missing file yields no groups.
+func (f *FileStore) Load() ([]Group, error) {
+	var out []Group
+	err := filesys.ReadJSON(f.fs, f.path, &out)
+	return out, err
+}
+
+// Save atomically replaces the stored groups.
+func (f *FileStore) Save(list []Group) error {
+	return filesys.WriteJSON(f.fs, f.path, list, 0o644)
+}
@@ -0,0 +1,43 @@
+package group
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type GroupTestSuite struct {
+	suite.Suite
+}
+
+func (s *GroupTestSuite) TestNew() {
+	g := New(" Social ", []string{"x.com", "Twitter.com", "", "x.com", " t.co"})
+	s.Equal("social", g.Name)
+	s.Equal([]string{"t.co", "twitter.com", "x.com"}, g.Domains)
+	s.NoError(g.Validate())
+}
+
+func (s *GroupTestSuite) TestValidate() {
+	testCases := []struct {
+		name  string
+		group Group
+		err   bool
+	}{
+		{name: "valid", group: Group{Name: "news-sites_2", Domains: []string{"a.com"}}},
+		{name: "empty name", group: Group{Domains: []string{"a.com"}}, err: true},
+		{name: "name with space", group: Group{Name: "my group", Domains: []string{"a.com"}}, err: true},
+		{name: "leading dash", group: Group{Name: "-x", Domains: []string{"a.com"}}, err: true},
+		{name: "no domains", group: Group{Name: "empty"}, err: true},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			err := tc.group.Validate()
+			s.Equal(tc.err, err != nil, "err: %v", err)
+		})
+	}
+}
+
+func TestGroupSuite(t *testing.T) {
+	suite.Run(t, new(GroupTestSuite))
+}
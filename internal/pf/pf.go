@@ -23,6 +23,8 @@ import (
 	"net"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -41,7 +43,16 @@ const (
 // Callers *never* modify files or invoke pfctl directly.
 type Manager interface {
 	CurrentRules() ([]rules.Rule, error)
-	Sync(ctx context.Context, want []rules.Rule) error
+	Sync(ctx context.Context, want []rules.Rule) (reloaded bool, err error)
+	Counters(ctx context.Context) (map[string]Counters, error)
+}
+
+// Counters is the traffic pf has matched against one Void rule since the
+// rule was last loaded.
+type Counters struct {
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+	States  uint64 `json:"states"`
 }
 
 // manager is the concrete implementation of the Manager interface.
@@ -60,6 +71,8 @@ func New() Manager {
 }
 
 // CurrentRules parses /etc/pf.anchors/void and returns the rules it finds.
+// The daemon only uses it to migrate anchors written before rule state was
+// kept in its own file; the anchor is otherwise output only.
 func (m *ManagerImpl) CurrentRules() ([]rules.Rule, error) {
 	data, err := m.fs.ReadFile(_pfAnchorPath)
 	if err != nil {
@@ -82,28 +95,39 @@ func (m *ManagerImpl) CurrentRules() ([]rules.Rule, error) {
 	return out, nil
 }
 
-// Sync synchronizes the desired rules with the pf configuration.
-func (m *ManagerImpl) Sync(ctx context.Context, want []rules.Rule) error {
+// Sync synchronizes the desired rules with the pf configuration. It reports
+// whether pf was reloaded; nothing is reloaded when the files already match.
+func (m *ManagerImpl) Sync(ctx context.Context, want []rules.Rule) (bool, error) {
 	hdrChanged, err := m.ensureAnchor()
 	if err != nil {
-		return err
+		return false, err
 	}
 	confChanged, err := m.ensurePfConf()
 	if err != nil {
-		return err
+		return false, err
 	}
 	rulesChanged, err := m.reconcileRules(want)
 	if err != nil {
-		return err
+		return false, err
 	}
 	if !hdrChanged && !confChanged && !rulesChanged {
-		return nil // nothing to do
+		return false, nil // nothing to do
 	}
 
 	if err := m.reload(ctx); err != nil {
-		return fmt.Errorf("failed to reload pf: %w", err)
+		return false, fmt.Errorf("failed to reload pf: %w", err)
+	}
+	return true, nil
+}
+
+// Counters returns the pf counters of every labelled Void rule, keyed by
+// rule ID. Rules pf has not loaded (yet) are absent.
+func (m *ManagerImpl) Counters(ctx context.Context) (map[string]Counters, error) {
+	out, err := m.cmd.Output(ctx, _pfCtlPath, "-a", "void", "-s", "labels")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pf labels: %w", err)
 	}
-	return nil
+	return parseLabels(bytes.NewReader(out))
 }
 
 // reload reloads the pf configuration.
@@ -269,12 +293,33 @@ func (m *ManagerImpl) walk(r io.Reader) ([]blockMeta, error) {
 func renderBlock(w io.Writer, r rules.Rule) {
 	_, _ = fmt.Fprintf(w, "# === VOID-RULE %s BEGIN ===\n", r.ID)
 	_, _ = fmt.Fprintf(w, "# Domain: %s\n", r.Domain)
+	if len(r.Hosts) > 0 {
+		_, _ = fmt.Fprintf(w, "# Hosts: %s\n", strings.Join(r.Hosts, " "))
+	}
+	if len(r.CNAMEs) > 0 {
+		_, _ = fmt.Fprintf(w, "# CNAMEs: %s\n", strings.Join(r.CNAMEs, " "))
+	}
+	if len(r.Targets) > 0 {
+		_, _ = fmt.Fprintf(w, "# Targets: %s\n", strings.Join(r.Targets, " "))
+	}
 	if !r.Permanent {
 		_, _ = fmt.Fprintf(w, "# Expires: %s\n", r.Expires.Format(time.RFC3339))
 	}
+	if r.ScheduleID != "" {
+		_, _ = fmt.Fprintf(w, "# Schedule: %s\n", r.ScheduleID)
+	}
+	if r.Group != "" {
+		_, _ = fmt.Fprintf(w, "# Group: %s\n", r.Group)
+	}
+	if r.SessionID != "" {
+		_, _ = fmt.Fprintf(w, "# Session: %s\n", r.SessionID)
+	}
+	if !r.LockedUntil.IsZero() {
+		_, _ = fmt.Fprintf(w, "# Locked: %s\n", r.LockedUntil.Format(time.RFC3339))
+	}
 	for _, ip := range r.IPs {
-		_, _ = fmt.Fprintf(w, "block return out proto tcp from any to %s\n", ip.String())
-		_, _ = fmt.Fprintf(w, "block return out proto udp from any to %s\n", ip.String())
+		_, _ = fmt.Fprintf(w, "block return out proto tcp from any to %s label %q\n", ip.String(), ruleLabel(r.ID))
+		_, _ = fmt.Fprintf(w, "block return out proto udp from any to %s label %q\n", ip.String(), ruleLabel(r.ID))
 	}
 	_, _ = fmt.Fprintf(w, "# === VOID-RULE %s END ===\n", r.ID)
 }
@@ -308,6 +353,39 @@ func parseBlock(b []byte) (rules.Rule, error) {
 		case stage == 0 && strings.HasPrefix(line, "# Domain:"):
 			r.Domain = strings.TrimSpace(strings.TrimPrefix(line, "# Domain:"))
 
+		// Hosts header (wildcard rules only)
+		case stage == 0 && strings.HasPrefix(line, "# Hosts:"):
+			r.Hosts = strings.Fields(strings.TrimPrefix(line, "# Hosts:"))
+
+		// CNAMEs header (optional)
+		case stage == 0 && strings.HasPrefix(line, "# CNAMEs:"):
+			r.CNAMEs = strings.Fields(strings.TrimPrefix(line, "# CNAMEs:"))
+
+		// Targets header (optional)
+		case stage == 0 && strings.HasPrefix(line, "# Targets:"):
+			r.Targets = strings.Fields(strings.TrimPrefix(line, "# Targets:"))
+
+		// Schedule header (optional)
+		case stage == 0 && strings.HasPrefix(line, "# Schedule:"):
+			r.ScheduleID = strings.TrimSpace(strings.TrimPrefix(line, "# Schedule:"))
+
+		// Group header (optional)
+		case stage == 0 && strings.HasPrefix(line, "# Group:"):
+			r.Group = strings.TrimSpace(strings.TrimPrefix(line, "# Group:"))
+
+		// Session header (optional)
+		case stage == 0 && strings.HasPrefix(line, "# Session:"):
+			r.SessionID = strings.TrimSpace(strings.TrimPrefix(line, "# Session:"))
+
+		// Locked header (optional)
+		case stage == 0 && strings.HasPrefix(line, "# Locked:"):
+			ts := strings.TrimSpace(strings.TrimPrefix(line, "# Locked:"))
+			until, err := time.Parse(time.RFC3339, ts)
+			if err != nil {
+				return r, fmt.Errorf("bad lock timestamp: %v", err)
+			}
+			r.LockedUntil = until
+
 		// Expires header (optional)
 		case stage == 0 && strings.HasPrefix(line, "# Expires:"):
 			ts := strings.TrimSpace(strings.TrimPrefix(line, "# Expires:"))
@@ -322,10 +400,13 @@ func parseBlock(b []byte) (rules.Rule, error) {
 			stage = 1
 			fallthrough
 
-		// IP rule lines
+		// IP rule lines: "block … to <ip> [label "…"]"
 		case stage == 1 && strings.HasPrefix(line, "block"):
 			parts := strings.Fields(line)
 			ipStr := parts[len(parts)-1]
+			if i := slices.Index(parts, "to"); i >= 0 && i+1 < len(parts) {
+				ipStr = parts[i+1]
+			}
 			m.Lock()
 			if _, dup := seen[ipStr]; !dup {
 				seen[ipStr] = struct{}{}
@@ -355,6 +436,39 @@ func parseBlock(b []byte) (rules.Rule, error) {
 	return r, nil
 }
 
+// ruleLabel is the pf label attached to every line of a rule's block.
+func ruleLabel(id string) string { return _labelPrefix + id }
+
+// parseLabels sums the output of "pfctl -s labels" per Void rule. Each line
+// reads "<label> <evaluations> <packets> <bytes> <in packets> <in bytes>
+// <out packets> <out bytes> [<states>]"; a rule contributes one line per
+// pf rule (two per address). Lines with other labels are ignored.
+func parseLabels(r io.Reader) (map[string]Counters, error) {
+	out := make(map[string]Counters)
+	scan := bufio.NewScanner(r)
+	for scan.Scan() {
+		fields := strings.Fields(scan.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], _labelPrefix) {
+			continue
+		}
+		var nums [8]uint64
+		for i, f := range fields[1:min(len(fields), 9)] {
+			n, err := strconv.ParseUint(f, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("malformed label line %q: %w", scan.Text(), err)
+			}
+			nums[i] = n
+		}
+		id := strings.TrimPrefix(fields[0], _labelPrefix)
+		c := out[id]
+		c.Packets += nums[1]
+		c.Bytes += nums[2]
+		c.States += nums[7]
+		out[id] = c
+	}
+	return out, scan.Err()
+}
+
 // hasLoader checks if pf.conf contains the anchor loading stanza.
 func hasLoader(b []byte) bool {
 	const (
@@ -387,6 +501,8 @@ const (
 	_anchorStanza = `anchor "void"
 load anchor "void" from "/etc/pf.anchors/void"`
 	_sentinelLine = "# void-anchor"
+	// Prefix of the pf label carrying a rule's ID.
+	_labelPrefix = "void-"
 	// Header written at the very top of /etc/pf.anchors/void.
 	// Header written at the very top of /etc/pf.anchors/void.
 	_anchorHeader = _sentinelLine + `
@@ -402,6 +518,7 @@ set skip on lo0
 
 type runner interface {
 	Run(ctx context.Context, name string, arg ...string) error
+	Output(ctx context.Context, name string, arg ...string) ([]byte, error)
 }
 type execRunner struct{}
 
@@ -409,3 +526,7 @@ func (execRunner) Run(ctx context.Context, name string, arg ...string) error {
 	cmd := exec.CommandContext(ctx, name, arg...)
 	return cmd.Run()
 }
+
+func (execRunner) Output(ctx context.Context, name string, arg ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, arg...).Output()
+}
@@ -1,6 +1,7 @@
 package pf
 
 import (
+	"bytes"
 	"context"
 	"net"
 	"strings"
@@ -185,6 +186,64 @@ block return out proto udp from any to 23.192.228.84
 				},
 			},
 		},
+		{
+			name: "locked temporary rule",
+			in: `# void-anchor
+# === VOID-RULE 0xlock BEGIN ===
+# Domain: youtube.com
+# Expires: 2025-03-03T13:00:00Z
+# Locked: 2025-03-03T12:00:00Z
+block return out proto tcp from any to 1.2.3.4
+block return out proto udp from any to 1.2.3.4
+# === VOID-RULE 0xlock END ===
+`,
+			expectedRules: 1,
+			expected: []rules.Rule{
+				{
+					ID:          "0xlock",
+					Domain:      "youtube.com",
+					IPs:         []net.IPAddr{{IP: net.ParseIP("1.2.3.4")}},
+					Expires:     time.Date(2025, 3, 3, 13, 0, 0, 0, time.UTC),
+					LockedUntil: time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		{
+			name: "wildcard rule with hosts",
+			in: `# void-anchor
+# === VOID-RULE 0xwild BEGIN ===
+# Domain: *.example.com
+# Hosts: example.com www.example.com old.example.com
+# CNAMEs: example.edgekey.net e1.akamaiedge.net
+# Targets: svc.example.net
+# Schedule: sched-1
+# Group: social
+# Session: focus-1
+block return out proto tcp from any to 1.2.3.4
+block return out proto udp from any to 1.2.3.4
+block return out proto tcp from any to 5.6.7.8
+block return out proto udp from any to 5.6.7.8
+# === VOID-RULE 0xwild END ===
+`,
+			expectedRules: 1,
+			expected: []rules.Rule{
+				{
+					ID:         "0xwild",
+					Domain:     "*.example.com",
+					Hosts:      []string{"example.com", "www.example.com", "old.example.com"},
+					CNAMEs:     []string{"example.edgekey.net", "e1.akamaiedge.net"},
+					Targets:    []string{"svc.example.net"},
+					ScheduleID: "sched-1",
+					Group:      "social",
+					SessionID:  "focus-1",
+					IPs: []net.IPAddr{
+						{IP: net.ParseIP("1.2.3.4")},
+						{IP: net.ParseIP("5.6.7.8")},
+					},
+					Permanent: true,
+				},
+			},
+		},
 		{
 			name: "two rules parsed",
 			in: `# void-anchor
@@ -250,6 +309,13 @@ block return out proto udp from any to 1.2.3.4
 				for i, rule := range out {
 					s.Equal(tt.expected[i].ID, rule.ID)
 					s.Equal(tt.expected[i].Domain, rule.Domain)
+					s.Equal(tt.expected[i].Hosts, rule.Hosts)
+					s.Equal(tt.expected[i].CNAMEs, rule.CNAMEs)
+					s.Equal(tt.expected[i].Targets, rule.Targets)
+					s.Equal(tt.expected[i].ScheduleID, rule.ScheduleID)
+					s.Equal(tt.expected[i].Group, rule.Group)
+					s.Equal(tt.expected[i].SessionID, rule.SessionID)
+					s.Equal(tt.expected[i].LockedUntil, rule.LockedUntil)
 					assert.ElementsMatch(s.T(), tt.expected[i].IPs, rule.IPs)
 					s.Equal(tt.expected[i].Expires, rule.Expires)
 					s.Equal(tt.expected[i].Permanent, rule.Permanent)
@@ -372,6 +438,45 @@ set skip on lo0
 	})
 }
 
+func (s *PFTestSuite) TestRenderBlockLabels() {
+	r := rules.Rule{
+		ID:        "ecceadd1-d9ca-4ec9-a906-0e3e4736a45e",
+		Domain:    "example.com",
+		IPs:       []net.IPAddr{{IP: net.ParseIP("1.2.3.4")}, {IP: net.ParseIP("2001:db8::1")}},
+		Permanent: true,
+	}
+	var buf bytes.Buffer
+	renderBlock(&buf, r)
+	s.Contains(buf.String(), `block return out proto tcp from any to 1.2.3.4 label "void-ecceadd1-d9ca-4ec9-a906-0e3e4736a45e"`)
+	s.Contains(buf.String(), `block return out proto udp from any to 2001:db8::1 label "void-ecceadd1-d9ca-4ec9-a906-0e3e4736a45e"`)
+
+	got, err := parseBlock(buf.Bytes())
+	s.Require().NoError(err)
+	s.Equal(r.ID, got.ID)
+	s.ElementsMatch(r.IPs, got.IPs)
+}
+
+func (s *PFTestSuite) TestCounters() {
+	const out = `void-aaaa 120 7 420 0 0 7 420 1
+void-aaaa 120 3 180 0 0 3 180 0
+void-bbbb 5 0 0 0 0 0 0
+other 9 9 9 9 9 9 9 9
+`
+	runner := &cannedOutput{out: out}
+	m := ManagerImpl{fs: &mocks.MockOsFS{}, cmd: runner}
+
+	got, err := m.Counters(context.Background())
+	s.Require().NoError(err)
+	s.Equal([]string{_pfCtlPath, "-a", "void", "-s", "labels"}, runner.args)
+	s.Equal(map[string]Counters{
+		"aaaa": {Packets: 10, Bytes: 600, States: 1},
+		"bbbb": {},
+	}, got)
+
+	_, err = parseLabels(strings.NewReader("void-cccc 1 x 3\n"))
+	s.Error(err)
+}
+
 func TestRunPFTestSuite(t *testing.T) {
 	suite.Run(t, new(PFTestSuite))
 }
@@ -393,3 +498,19 @@ type noexec struct{}
 func (noexec) Run(_ context.Context, _ string, _ ...string) error {
 	return nil
 }
+
+func (noexec) Output(_ context.Context, _ string, _ ...string) ([]byte, error) {
+	return nil, nil
+}
+
+// cannedOutput returns out for every command and records the last one run.
+type cannedOutput struct {
+	noexec
+	out  string
+	args []string
+}
+
+func (c *cannedOutput) Output(_ context.Context, name string, arg ...string) ([]byte, error) {
+	c.args = append([]string{name}, arg...)
+	return []byte(c.out), nil
+}
@@ -0,0 +1,133 @@
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"github.com/lc/void/internal/filesys"
+)
+
+// DefaultStatePath is where the daemon keeps its rule state, next to the pf anchor.
+const DefaultStatePath = "/etc/pf.anchors/void.state"
+
+// _stateVersion is bumped whenever the state file layout changes incompatibly.
+const _stateVersion = 1
+
+var _ Store = (*FileStore)(nil)
+
+// stateFile is the on-disk layout of a FileStore.
+type stateFile struct {
+	Version int    `json:"version"`
+	Rules   []Rule `json:"rules"`
+}
+
+// FileStore is a Store whose rules are the daemon's source of truth,
+// persisted to a JSON state file. Changes are kept in memory until Flush,
+// which rewrites the file with filesys.AtomicWrite so a crash leaves
+// either the old or the new state, never a torn one.
+type FileStore struct {
+	*MemoryStore
+	fs    filesys.FileOps
+	path  string
+	fresh bool        // no state file existed when the store was opened
+	dirty atomic.Bool // changes not yet written to disk
+}
+
+// NewFileStore opens the state file at path on the local disk.
+func NewFileStore(path string) (*FileStore, error) {
+	return OpenFileStore(filesys.OS(), path)
+}
+
+// OpenFileStore opens the state file at path through fs, loading any rules
+// already saved there. A missing file yields an empty, fresh store.
+func OpenFileStore(fs filesys.FileOps, path string) (*FileStore, error) {
+	f := &FileStore{MemoryStore: NewStore(), fs: fs, path: path}
+
+	data, err := fs.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		f.fresh = true
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading rule state: %w", err)
+	}
+
+	var st stateFile
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("decoding rule state %s: %w", path, err)
+	}
+	if st.Version != _stateVersion {
+		return nil, fmt.Errorf("rule state %s has unsupported version %d", path, st.Version)
+	}
+	for i := range st.Rules {
+		f.MemoryStore.Upsert(&st.Rules[i])
+	}
+	return f, nil
+}
+
+// Fresh reports whether no state file existed when the store was opened,
+// i.e. whether rules should be migrated from elsewhere.
+func (f *FileStore) Fresh() bool { return f.fresh }
+
+// Upsert inserts or updates a rule; see MemoryStore.Upsert.
+func (f *FileStore) Upsert(r *Rule) bool {
+	return f.mark(f.MemoryStore.Upsert(r))
+}
+
+// UpdateResolvedAt records a re-resolution; see MemoryStore.UpdateResolvedAt.
+func (f *FileStore) UpdateResolvedAt(id string, ts time.Time, ttl time.Duration) bool {
+	return f.mark(f.MemoryStore.UpdateResolvedAt(id, ts, ttl))
+}
+
+// SetExpiry changes a rule's expiry; see MemoryStore.SetExpiry.
+func (f *FileStore) SetExpiry(id string, expires time.Time, policy ExpiryPolicy) (Rule, error) {
+	r, err := f.MemoryStore.SetExpiry(id, expires, policy)
+	f.mark(err == nil)
+	return r, err
+}
+
+// Remove deletes by id; returns the rule for logging/PF diff.
+func (f *FileStore) Remove(id string) (*Rule, bool) {
+	r, ok := f.MemoryStore.Remove(id)
+	f.mark(ok)
+	return r, ok
+}
+
+// ExpireNow pops all entries older than now.
+func (f *FileStore) ExpireNow(now time.Time) []*Rule {
+	expired := f.MemoryStore.ExpireNow(now)
+	f.mark(len(expired) > 0)
+	return expired
+}
+
+// Flush writes the rules to the state file if anything changed since the
+// last successful Flush. A fresh store is always written once so later
+// opens know the state file is authoritative.
+func (f *FileStore) Flush() error {
+	if !f.dirty.Swap(false) && !f.fresh {
+		return nil
+	}
+	list := f.Snapshot()
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	if err := filesys.WriteJSON(f.fs, f.path, stateFile{Version: _stateVersion, Rules: list}, 0o600); err != nil {
+		f.dirty.Store(true) // retried on the next Flush
+		return fmt.Errorf("writing rule state: %w", err)
+	}
+	f.fresh = false
+	return nil
+}
+
+// mark records a change that needs flushing and passes changed through.
+func (f *FileStore) mark(changed bool) bool {
+	if changed {
+		f.dirty.Store(true)
+	}
+	return changed
+}
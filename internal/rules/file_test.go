@@ -0,0 +1,112 @@
+package rules
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FileStoreTestSuite struct {
+	suite.Suite
+	path string
+}
+
+func (s *FileStoreTestSuite) SetupTest() {
+	s.path = filepath.Join(s.T().TempDir(), "void.state")
+}
+
+func (s *FileStoreTestSuite) TestRoundTrip() {
+	f, err := NewFileStore(s.path)
+	s.Require().NoError(err)
+	s.True(f.Fresh())
+
+	base := time.Now().Truncate(time.Second)
+	f.Upsert(&Rule{
+		ID:         "a",
+		Domain:     "a.com",
+		IPs:        []net.IPAddr{{IP: net.ParseIP("1.2.3.4")}},
+		Expires:    base.Add(time.Hour),
+		ResolvedAt: base.Add(-time.Minute),
+		Created:    base.Add(-2 * time.Minute),
+		Group:      "social",
+	})
+	f.Upsert(&Rule{ID: "b", Domain: "b.com", Permanent: true, ResolvedAt: base})
+	f.Upsert(&Rule{ID: "c", Domain: "c.com", Permanent: true, Created: base,
+		Pending: true, Attempts: 3, NextAttempt: base.Add(time.Minute), LastError: "i/o timeout"})
+	s.Require().NoError(f.Flush())
+	s.False(f.Fresh())
+
+	reopened, err := NewFileStore(s.path)
+	s.Require().NoError(err)
+	s.False(reopened.Fresh())
+
+	a, ok := reopened.ByDomain("a.com")
+	s.Require().True(ok)
+	s.True(a.Expires.Equal(base.Add(time.Hour)))
+	s.True(a.ResolvedAt.Equal(base.Add(-time.Minute)))
+	s.True(a.Created.Equal(base.Add(-2 * time.Minute)))
+	s.Equal("social", a.Group)
+	s.Equal("1.2.3.4", a.IPs[0].IP.String())
+
+	// Temporary rules must be back in the expiry heap.
+	next, ok := reopened.NextExpiry()
+	s.True(ok)
+	s.True(next.Equal(base.Add(time.Hour)))
+
+	b, ok := reopened.ByDomain("b.com")
+	s.Require().True(ok)
+	s.True(b.Permanent)
+
+	c, ok := reopened.ByDomain("c.com")
+	s.Require().True(ok)
+	s.True(c.Pending)
+	s.Equal(3, c.Attempts)
+	s.True(c.NextAttempt.Equal(base.Add(time.Minute)))
+	s.Equal("i/o timeout", c.LastError)
+}
+
+func (s *FileStoreTestSuite) TestFlushPersistsRemovals() {
+	f, err := NewFileStore(s.path)
+	s.Require().NoError(err)
+	f.Upsert(&Rule{ID: "a", Domain: "a.com", Expires: time.Now().Add(-time.Second)})
+	f.Upsert(&Rule{ID: "b", Domain: "b.com", Permanent: true})
+	s.Require().NoError(f.Flush())
+
+	s.Len(f.ExpireNow(time.Now()), 1)
+	_, ok := f.Remove("b")
+	s.True(ok)
+	s.Require().NoError(f.Flush())
+
+	reopened, err := NewFileStore(s.path)
+	s.Require().NoError(err)
+	s.Empty(reopened.Snapshot())
+}
+
+func (s *FileStoreTestSuite) TestFreshStoreIsWrittenOnce() {
+	f, err := NewFileStore(s.path)
+	s.Require().NoError(err)
+	s.Require().NoError(f.Flush())
+
+	// An empty but existing state file is authoritative.
+	reopened, err := NewFileStore(s.path)
+	s.Require().NoError(err)
+	s.False(reopened.Fresh())
+}
+
+func (s *FileStoreTestSuite) TestCorruptState() {
+	s.Require().NoError(os.WriteFile(s.path, []byte("{not json"), 0o600))
+	_, err := NewFileStore(s.path)
+	s.Error(err)
+
+	s.Require().NoError(os.WriteFile(s.path, []byte(`{"version":99,"rules":[]}`), 0o600))
+	_, err = NewFileStore(s.path)
+	s.ErrorContains(err, "unsupported version")
+}
+
+func TestFileStoreSuite(t *testing.T) {
+	suite.Run(t, new(FileStoreTestSuite))
+}
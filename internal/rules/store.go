@@ -5,6 +5,7 @@ package rules
 
 import (
 	"container/heap"
+	"errors"
 	"net"
 	"strings"
 	"sync"
@@ -13,35 +14,139 @@ import (
 	"go.uber.org/atomic"
 )
 
+var (
+	// ErrNotFound is returned when no rule matches a lookup.
+	ErrNotFound = errors.New("rule not found")
+	// ErrAmbiguous is returned when an ID prefix matches more than one rule.
+	ErrAmbiguous = errors.New("ambiguous rule ID prefix")
+	// ErrLocked is returned when a change would weaken a locked rule.
+	ErrLocked = errors.New("rule is locked")
+	// ErrWouldShorten is returned when an ExtendOnly change would end a rule sooner.
+	ErrWouldShorten = errors.New("change would shorten the rule")
+)
+
+// ExpiryPolicy controls which expiry changes SetExpiry accepts.
+type ExpiryPolicy int
+
+const (
+	// ExtendOnly accepts only changes that keep the rule in force at least
+	// as long as before: a later expiry or becoming permanent.
+	ExtendOnly ExpiryPolicy = iota
+	// Replace accepts any new expiry, including an earlier one or turning
+	// a permanent rule temporary.
+	Replace
+)
+
+// String returns the policy's name as used by the API.
+func (p ExpiryPolicy) String() string {
+	if p == Replace {
+		return "replace"
+	}
+	return "extend"
+}
+
 // Rule represents a single domain blocking rule in the PF ruleset.
 // Each rule contains information about a domain to block, its associated
 // IP addresses, and metadata about expiration and resolution times.
 type Rule struct {
-	ID         string       // Unique identifier for the rule
-	Domain     string       // Domain name to block
-	IPs        []net.IPAddr // Resolved IP addresses for the domain
-	Expires    time.Time    // When the rule expires (zero for permanent rules)
-	Permanent  bool         // Whether the rule is permanent
-	ResolvedAt time.Time    // When the domain was last resolved to IPs
+	ID          string        // Unique identifier for the rule
+	Domain      string        // Domain name to block, or "*.zone" for a wildcard rule
+	Hosts       []string      // Concrete hostnames tracked by a wildcard rule
+	CNAMEs      []string      // Alias targets observed while resolving the rule
+	Targets     []string      // Service targets named by the rule's HTTPS records
+	IPs         []net.IPAddr  // Resolved IP addresses for the domain
+	Expires     time.Time     // When the rule expires (zero for permanent rules)
+	Permanent   bool          // Whether the rule is permanent
+	ResolvedAt  time.Time     // When the domain was last resolved to IPs
+	TTL         time.Duration // Lowest DNS TTL seen at the last resolution; 0 if unknown
+	Created     time.Time     // When the rule was first created
+	ScheduleID  string        // Schedule that activated the rule, if any
+	Group       string        // Group the rule was blocked through, if any
+	SessionID   string        // Focus session that blocked the rule, if any
+	LockedUntil time.Time     // Rule cannot be removed or shortened before this
+	// Strategy is how the rule's names are resolved ("first" or "union");
+	// empty means the resolver's default.
+	Strategy string
+	// Sources lists the upstreams that returned each IP, keyed by address,
+	// for rules resolved with the "union" strategy.
+	Sources map[string][]string
+	// Pending marks a rule whose names have not resolved yet. It blocks
+	// nothing until they do, but expires on time; the engine tries again
+	// at NextAttempt, backing off after each of Attempts failures, the
+	// last of which was LastError.
+	Pending     bool
+	Attempts    int
+	NextAttempt time.Time
+	LastError   string
+}
+
+// Locked reports whether the rule's commitment lock is still in force at now.
+func (r Rule) Locked(now time.Time) bool { return now.Before(r.LockedUntil) }
+
+// WildcardPrefix marks a domain as covering a zone and all its subdomains.
+const WildcardPrefix = "*."
+
+// IsWildcard reports whether domain has the "*.zone" wildcard form.
+func IsWildcard(domain string) bool {
+	return strings.HasPrefix(domain, WildcardPrefix) && len(domain) > len(WildcardPrefix)
+}
+
+// Wildcard reports whether the rule covers a whole zone.
+func (r Rule) Wildcard() bool { return IsWildcard(r.Domain) }
+
+// Zone returns the zone covered by a wildcard rule, e.g. "example.com"
+// for "*.example.com". For other rules it returns the domain unchanged.
+func (r Rule) Zone() string { return strings.TrimPrefix(r.Domain, WildcardPrefix) }
+
+// linked reports whether r was blocked through a group, focus session or
+// schedule rather than by the user directly.
+func (r Rule) linked() bool {
+	return r.Group != "" || r.SessionID != "" || r.ScheduleID != ""
+}
+
+// Covers reports whether host is the rule's domain or, for a wildcard
+// rule, the zone apex or any name beneath it.
+func (r Rule) Covers(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	zone := strings.ToLower(r.Zone())
+	if !r.Wildcard() {
+		return host == zone
+	}
+	return host == zone || strings.HasSuffix(host, "."+zone)
 }
 
 var _ Store = (*MemoryStore)(nil)
 
 type Store interface {
 	// Upsert inserts or updates a rule. Returns true if PF needs to be updated.
+	// An existing rule's expiry is only ever extended, and the rule stays
+	// with the group, focus session or schedule that blocked it unless the
+	// user blocks it directly.
 	Upsert(r *Rule) (changed bool)
-	// UpdateResolvedAt updates the ResolvedAt timestamp for a rule.
-	UpdateResolvedAt(id string, ts time.Time) bool
+	// SetExpiry changes the expiry of rule id under policy; a zero expires
+	// makes the rule permanent. It returns a copy of the updated rule.
+	SetExpiry(id string, expires time.Time, policy ExpiryPolicy) (Rule, error)
+	// UpdateResolvedAt records that a rule was re-resolved at ts with the
+	// given DNS TTL, without changing its addresses.
+	UpdateResolvedAt(id string, ts time.Time, ttl time.Duration) bool
 	// Remove deletes and returns the rule for logging/PF diff.
 	Remove(id string) (*Rule, bool)
-	// NextRefresh returns the earliest time any rule needs a DNS refresh.
-	NextRefresh(rr time.Duration) time.Time
+	// ByDomain returns a copy of the rule covering domain, if any.
+	ByDomain(domain string) (Rule, bool)
+	// Lookup resolves a rule ID, domain or unique ID prefix to a rule.
+	Lookup(ref string) (Rule, error)
+	// NextRefresh returns the earliest time any rule needs a DNS refresh,
+	// given how long after resolution each rule is due, or ok=false if
+	// there are no rules. Pending rules are due at their NextAttempt.
+	NextRefresh(after func(Rule) time.Duration) (time.Time, bool)
 	// NextExpiry returns the soonest expiry time, or ok=false if none.
 	NextExpiry() (time.Time, bool)
 	// ExpireNow pops all entries older than now.
 	ExpireNow(now time.Time) []*Rule
 	// Snapshot returns a copy of the current ruleset.
 	Snapshot() []Rule
+	// Flush persists changes made since the last Flush, if the store is durable.
+	Flush() error
 }
 
 // NewStore creates a new in-memory rule store.
@@ -65,7 +170,17 @@ type MemoryStore struct {
 	count atomic.Int64      // metrics: total rules
 }
 
-// Upsert inserts or upgrades a rule. Returns true if PF needs to be updated:
+// Upsert inserts or upgrades a rule. Returns true if PF needs to be updated.
+// Merging into an existing temporary rule keeps the later of the two
+// expiries; use SetExpiry to bring an expiry forward. Merging a pending
+// rule never replaces addresses the existing rule already resolved, and
+// merging a resolved one ends the existing rule's pending state.
+//
+// A rule belongs to whatever blocked it first. Blocking it again through a
+// group, focus session or schedule does not hand it over, so unblocking
+// the group, stopping the session or removing the schedule leaves a block
+// the user made alone; a block the user makes directly, linked to none of
+// them, takes the rule over.
 func (s *MemoryStore) Upsert(r *Rule) (changed bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -73,18 +188,37 @@ func (s *MemoryStore) Upsert(r *Rule) (changed bool) {
 	dom := strings.ToLower(r.Domain)
 
 	if cur, ok := s.byDom[dom]; ok {
+		if !r.linked() {
+			cur.Group, cur.SessionID, cur.ScheduleID = "", "", ""
+		}
+		if r.Strategy != "" {
+			cur.Strategy = r.Strategy
+		}
+		if r.LockedUntil.After(cur.LockedUntil) {
+			cur.LockedUntil = r.LockedUntil // locks only ever grow
+		}
+		if r.Pending {
+			if cur.Pending {
+				cur.Attempts, cur.NextAttempt, cur.LastError = r.Attempts, r.NextAttempt, r.LastError
+			}
+			s.mergeExpiry(cur, r)
+			return true
+		}
 		// If the existing rule is temporary & new is permanent, upgrade in place.
-		if !cur.Permanent && r.Permanent {
-			cur.Permanent = true
-			cur.Expires = time.Time{}
-			// permanent rules don't exist in the heap.
-			heap.Remove(&s.expH, cur.heapIdx)
+		if !cur.Permanent && r.Permanent && !cur.Pending {
+			s.mergeExpiry(cur, r)
 			return true
 		}
 		// otherwise, update the existing rule.
+		cur.Pending, cur.Attempts, cur.NextAttempt, cur.LastError = false, 0, time.Time{}, ""
+		cur.Hosts = r.Hosts
+		cur.CNAMEs = r.CNAMEs
+		cur.Targets = r.Targets
 		cur.IPs = r.IPs
+		cur.Sources = r.Sources
 		cur.ResolvedAt = r.ResolvedAt
-		cur.Expires = r.Expires
+		cur.TTL = r.TTL
+		s.mergeExpiry(cur, r)
 		return true
 	}
 
@@ -100,8 +234,61 @@ func (s *MemoryStore) Upsert(r *Rule) (changed bool) {
 	return true
 }
 
-// UpdateResolvedAt updates the ResolvedAt timestamp for a rule.
-func (s *MemoryStore) UpdateResolvedAt(id string, ts time.Time) bool {
+// mergeExpiry makes cur permanent if r is, or moves its expiry to r's if
+// that is later. s.mu must be held.
+func (s *MemoryStore) mergeExpiry(cur *entry, r *Rule) {
+	switch {
+	case cur.Permanent:
+	case r.Permanent:
+		cur.Permanent = true
+		cur.Expires = time.Time{}
+		// permanent rules don't exist in the heap.
+		heap.Remove(&s.expH, cur.heapIdx)
+	case r.Expires.After(cur.Expires):
+		cur.Expires = r.Expires
+		heap.Fix(&s.expH, cur.heapIdx)
+	}
+}
+
+// SetExpiry changes the expiry of rule id under policy and keeps the
+// expiry heap consistent. A zero expires makes the rule permanent; a
+// non-zero one makes a permanent rule temporary, which ExtendOnly refuses.
+func (s *MemoryStore) SetExpiry(id string, expires time.Time, policy ExpiryPolicy) (Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.byID[id]
+	if !ok {
+		return Rule{}, ErrNotFound
+	}
+
+	switch {
+	case expires.IsZero():
+		if !cur.Permanent {
+			heap.Remove(&s.expH, cur.heapIdx)
+			cur.Permanent = true
+			cur.Expires = time.Time{}
+		}
+	case cur.Permanent:
+		if policy == ExtendOnly {
+			return Rule{}, ErrWouldShorten
+		}
+		cur.Permanent = false
+		cur.Expires = expires
+		heap.Push(&s.expH, cur)
+	default:
+		if policy == ExtendOnly && expires.Before(cur.Expires) {
+			return Rule{}, ErrWouldShorten
+		}
+		cur.Expires = expires
+		heap.Fix(&s.expH, cur.heapIdx)
+	}
+	return *cur.Rule, nil
+}
+
+// UpdateResolvedAt records that a rule was re-resolved at ts with the
+// given DNS TTL, without changing its addresses.
+func (s *MemoryStore) UpdateResolvedAt(id string, ts time.Time, ttl time.Duration) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -110,6 +297,7 @@ func (s *MemoryStore) UpdateResolvedAt(id string, ts time.Time) bool {
 		return false
 	}
 	cur.ResolvedAt = ts
+	cur.TTL = ttl
 
 	// Update the heap if this is a temporary rule.
 	if !cur.Permanent {
@@ -146,19 +334,73 @@ func (s *MemoryStore) Remove(id string) (*Rule, bool) {
 	return cur.Rule, true
 }
 
-// NextRefresh returns the earliest time any rule needs a DNS refresh.
-func (s *MemoryStore) NextRefresh(rr time.Duration) time.Time {
+// ByDomain returns a copy of the rule covering domain, if any.
+// The lookup is case-insensitive.
+func (s *MemoryStore) ByDomain(domain string) (Rule, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	soonest := time.Now().Add(rr) // upper bound
+	cur, ok := s.byDom[strings.ToLower(domain)]
+	if !ok {
+		return Rule{}, false
+	}
+	return *cur.Rule, true
+}
+
+// Lookup resolves ref to a rule. ref is tried, in order, as an exact rule
+// ID, a domain (case-insensitive) and finally a rule ID prefix. A prefix
+// that matches several rules yields ErrAmbiguous; no match yields ErrNotFound.
+func (s *MemoryStore) Lookup(ref string) (Rule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if ref == "" {
+		return Rule{}, ErrNotFound
+	}
+	if cur, ok := s.byID[ref]; ok {
+		return *cur.Rule, nil
+	}
+	if cur, ok := s.byDom[strings.ToLower(ref)]; ok {
+		return *cur.Rule, nil
+	}
+
+	var match *entry
+	for id, e := range s.byID {
+		if !strings.HasPrefix(id, ref) {
+			continue
+		}
+		if match != nil {
+			return Rule{}, ErrAmbiguous
+		}
+		match = e
+	}
+	if match == nil {
+		return Rule{}, ErrNotFound
+	}
+	return *match.Rule, nil
+}
+
+// NextRefresh returns the earliest time any rule needs a DNS refresh,
+// given how long after resolution each rule is due, or ok=false if
+// there are no rules. A pending rule is due at its NextAttempt.
+func (s *MemoryStore) NextRefresh(after func(Rule) time.Duration) (time.Time, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var (
+		soonest time.Time
+		found   bool
+	)
 	for _, e := range s.byID {
-		ts := e.ResolvedAt.Add(rr)
-		if ts.Before(soonest) {
-			soonest = ts
+		ts := e.ResolvedAt.Add(after(*e.Rule))
+		if e.Pending {
+			ts = e.NextAttempt
+		}
+		if !found || ts.Before(soonest) {
+			soonest, found = ts, true
 		}
 	}
-	return soonest
+	return soonest, found
 }
 
 // NextExpiry returns the soonest expiry time, or ok=false if none.
@@ -198,6 +440,9 @@ func (s *MemoryStore) ExpireNow(now time.Time) []*Rule {
 	return expired
 }
 
+// Flush is a no-op: a MemoryStore lives only as long as the process.
+func (s *MemoryStore) Flush() error { return nil }
+
 // Snapshot returns a copy of the current ruleset.
 func (s *MemoryStore) Snapshot() []Rule {
 	s.mu.RLock()
@@ -308,6 +308,308 @@ func (s *StoreTestSuite) TestRemove() {
 	}
 }
 
+func (s *StoreTestSuite) TestUpsertKeepsHeapOrdered() {
+	base := time.Now()
+	s.store.Upsert(&Rule{ID: "a", Domain: "a.com", Expires: base.Add(time.Minute)})
+	s.store.Upsert(&Rule{ID: "b", Domain: "b.com", Expires: base.Add(time.Hour)})
+
+	// Pushing a.com past b.com must re-order the heap.
+	s.store.Upsert(&Rule{ID: "a2", Domain: "a.com", Expires: base.Add(2 * time.Hour)})
+
+	next, ok := s.store.NextExpiry()
+	s.True(ok)
+	s.Equal(base.Add(time.Hour).Unix(), next.Unix())
+
+	expired := s.store.ExpireNow(base.Add(90 * time.Minute))
+	s.Len(expired, 1)
+	s.Equal("b", expired[0].ID)
+}
+
+func (s *StoreTestSuite) TestNextRefresh() {
+	byTTL := func(r Rule) time.Duration {
+		if r.TTL > 0 {
+			return r.TTL
+		}
+		return time.Hour
+	}
+	_, ok := s.store.NextRefresh(byTTL)
+	s.False(ok, "no rules, nothing to refresh")
+
+	base := time.Now()
+	s.store.Upsert(&Rule{ID: "a", Domain: "a.com", Permanent: true, ResolvedAt: base})
+	s.store.Upsert(&Rule{ID: "b", Domain: "b.com", Permanent: true, ResolvedAt: base.Add(-30 * time.Minute)})
+
+	next, ok := s.store.NextRefresh(byTTL)
+	s.True(ok)
+	s.Equal(base.Add(30*time.Minute), next)
+
+	// A short TTL brings a freshly resolved rule forward.
+	s.True(s.store.UpdateResolvedAt("a", base, time.Minute))
+	next, ok = s.store.NextRefresh(byTTL)
+	s.True(ok)
+	s.Equal(base.Add(time.Minute), next)
+
+	// A pending rule is due at its next attempt, whenever it was created.
+	s.store.Upsert(&Rule{ID: "c", Domain: "c.com", Permanent: true, Pending: true, NextAttempt: base.Add(10 * time.Second)})
+	next, ok = s.store.NextRefresh(byTTL)
+	s.True(ok)
+	s.Equal(base.Add(10*time.Second), next)
+}
+
+func (s *StoreTestSuite) TestUpsertPending() {
+	base := time.Now()
+	ip := []net.IPAddr{{IP: net.ParseIP("1.2.3.4")}}
+
+	// A failed lookup never wipes addresses that already resolved.
+	s.store.Upsert(&Rule{ID: "a", Domain: "a.com", IPs: ip, Expires: base.Add(time.Hour)})
+	s.store.Upsert(&Rule{ID: "b", Domain: "a.com", Pending: true, Attempts: 1, Expires: base.Add(2 * time.Hour)})
+	r, ok := s.store.ByDomain("a.com")
+	s.Require().True(ok)
+	s.False(r.Pending)
+	s.Zero(r.Attempts)
+	s.Equal(ip, r.IPs)
+	s.Equal(base.Add(2*time.Hour), r.Expires, "the expiry still merges")
+
+	// A pending rule records each failed attempt.
+	s.store.Upsert(&Rule{ID: "c", Domain: "c.com", Pending: true, Attempts: 1, Expires: base.Add(time.Hour)})
+	s.store.Upsert(&Rule{ID: "c", Domain: "c.com", Pending: true, Attempts: 2, LastError: "timeout",
+		NextAttempt: base.Add(time.Minute), Expires: base.Add(time.Hour)})
+	r, ok = s.store.ByDomain("c.com")
+	s.Require().True(ok)
+	s.True(r.Pending)
+	s.Equal(2, r.Attempts)
+	s.Equal("timeout", r.LastError)
+	s.Equal(base.Add(time.Minute), r.NextAttempt)
+
+	// Resolving ends the pending state, even when it also makes the rule permanent.
+	s.store.Upsert(&Rule{ID: "c", Domain: "c.com", IPs: ip, ResolvedAt: base, Permanent: true})
+	r, ok = s.store.ByDomain("c.com")
+	s.Require().True(ok)
+	s.False(r.Pending)
+	s.Zero(r.Attempts)
+	s.Empty(r.LastError)
+	s.Equal(ip, r.IPs)
+	s.True(r.Permanent)
+	next, ok := s.store.NextExpiry()
+	s.True(ok)
+	s.Equal(base.Add(2*time.Hour), next, "only a.com is left in the expiry heap")
+}
+
+func (s *StoreTestSuite) TestUpsertNeverShortens() {
+	base := time.Now()
+	s.store.Upsert(&Rule{ID: "a", Domain: "a.com", Expires: base.Add(3 * time.Hour)})
+	s.store.Upsert(&Rule{ID: "b", Domain: "a.com", Expires: base.Add(time.Hour)})
+
+	r, ok := s.store.ByDomain("a.com")
+	s.Require().True(ok)
+	s.Equal(base.Add(3*time.Hour), r.Expires)
+}
+
+func (s *StoreTestSuite) TestSetExpiry() {
+	base := time.Now()
+	testCases := []struct {
+		name      string
+		permanent bool
+		expires   time.Time
+		policy    ExpiryPolicy
+		err       error
+		expected  Rule // only Expires and Permanent are compared
+	}{
+		{name: "extend", expires: base.Add(2 * time.Hour), policy: ExtendOnly,
+			expected: Rule{Expires: base.Add(2 * time.Hour)}},
+		{name: "shorten refused", expires: base.Add(time.Minute), policy: ExtendOnly,
+			err: ErrWouldShorten},
+		{name: "shorten replaced", expires: base.Add(time.Minute), policy: Replace,
+			expected: Rule{Expires: base.Add(time.Minute)}},
+		{name: "make permanent", policy: ExtendOnly,
+			expected: Rule{Permanent: true}},
+		{name: "permanent to temporary refused", permanent: true, expires: base.Add(time.Hour), policy: ExtendOnly,
+			err: ErrWouldShorten},
+		{name: "permanent to temporary replaced", permanent: true, expires: base.Add(time.Hour), policy: Replace,
+			expected: Rule{Expires: base.Add(time.Hour)}},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			s.SetupTest()
+			r := &Rule{ID: "a", Domain: "a.com", Permanent: tc.permanent}
+			if !tc.permanent {
+				r.Expires = base.Add(time.Hour)
+			}
+			s.store.Upsert(r)
+			// A second rule keeps the heap honest about ordering.
+			s.store.Upsert(&Rule{ID: "b", Domain: "b.com", Expires: base.Add(90 * time.Minute)})
+
+			got, err := s.store.SetExpiry("a", tc.expires, tc.policy)
+			if tc.err != nil {
+				s.ErrorIs(err, tc.err)
+				return
+			}
+			s.Require().NoError(err)
+			s.Equal(tc.expected.Permanent, got.Permanent)
+			s.Equal(tc.expected.Expires, got.Expires)
+
+			// The heap must agree with the new expiry.
+			next, ok := s.store.NextExpiry()
+			s.Require().True(ok)
+			want := base.Add(90 * time.Minute)
+			if !got.Permanent && got.Expires.Before(want) {
+				want = got.Expires
+			}
+			s.Equal(want, next)
+		})
+	}
+
+	_, err := s.store.SetExpiry("missing", base, Replace)
+	s.ErrorIs(err, ErrNotFound)
+}
+
+func (s *StoreTestSuite) TestUpsertKeepsLongestLock() {
+	base := time.Now()
+	s.store.Upsert(&Rule{ID: "a", Domain: "a.com", Expires: base.Add(4 * time.Hour), LockedUntil: base.Add(2 * time.Hour)})
+
+	// A merge without a lock (or with a shorter one) must not weaken it.
+	s.store.Upsert(&Rule{ID: "b", Domain: "a.com", Expires: base.Add(4 * time.Hour)})
+	s.store.Upsert(&Rule{ID: "c", Domain: "a.com", Expires: base.Add(4 * time.Hour), LockedUntil: base.Add(time.Hour)})
+	r, ok := s.store.ByDomain("a.com")
+	s.Require().True(ok)
+	s.Equal(base.Add(2*time.Hour), r.LockedUntil)
+	s.True(r.Locked(base.Add(119 * time.Minute)))
+	s.False(r.Locked(base.Add(2 * time.Hour)))
+
+	s.store.Upsert(&Rule{ID: "d", Domain: "a.com", Expires: base.Add(4 * time.Hour), LockedUntil: base.Add(3 * time.Hour)})
+	r, _ = s.store.ByDomain("a.com")
+	s.Equal(base.Add(3*time.Hour), r.LockedUntil)
+}
+
+func (s *StoreTestSuite) TestUpsertScheduleOwnership() {
+	base := time.Now()
+
+	// A schedule extends a rule the user blocked, but does not take it over.
+	s.store.Upsert(&Rule{ID: "a", Domain: "a.com", Expires: base.Add(time.Hour)})
+	s.store.Upsert(&Rule{ID: "b", Domain: "a.com", Expires: base.Add(2 * time.Hour), ScheduleID: "sched"})
+	r, ok := s.store.ByDomain("a.com")
+	s.Require().True(ok)
+	s.Empty(r.ScheduleID)
+	s.Equal(base.Add(2*time.Hour), r.Expires)
+
+	// The user blocking a rule the schedule created takes it over.
+	s.store.Upsert(&Rule{ID: "c", Domain: "c.com", Expires: base.Add(time.Hour), ScheduleID: "sched"})
+	s.store.Upsert(&Rule{ID: "d", Domain: "c.com", Expires: base.Add(time.Hour)})
+	r, _ = s.store.ByDomain("c.com")
+	s.Empty(r.ScheduleID)
+}
+
+func (s *StoreTestSuite) TestUpsertGroupOwnership() {
+	base := time.Now()
+
+	// A group extends a rule the user blocked, but does not take it over.
+	s.store.Upsert(&Rule{ID: "a", Domain: "a.com", Expires: base.Add(time.Hour)})
+	s.store.Upsert(&Rule{ID: "b", Domain: "a.com", Expires: base.Add(2 * time.Hour), Group: "social"})
+	r, ok := s.store.ByDomain("a.com")
+	s.Require().True(ok)
+	s.Empty(r.Group)
+	s.Equal(base.Add(2*time.Hour), r.Expires)
+
+	// Nor one another group blocked first.
+	s.store.Upsert(&Rule{ID: "c", Domain: "c.com", Expires: base.Add(time.Hour), Group: "social"})
+	s.store.Upsert(&Rule{ID: "d", Domain: "c.com", Expires: base.Add(time.Hour), Group: "work"})
+	r, _ = s.store.ByDomain("c.com")
+	s.Equal("social", r.Group)
+
+	// The user blocking it directly does.
+	s.store.Upsert(&Rule{ID: "e", Domain: "c.com", Expires: base.Add(time.Hour)})
+	r, _ = s.store.ByDomain("c.com")
+	s.Empty(r.Group)
+}
+
+func (s *StoreTestSuite) TestUpsertSessionOwnership() {
+	base := time.Now()
+
+	// A focus session extends a rule the user blocked, but does not take it over.
+	s.store.Upsert(&Rule{ID: "a", Domain: "a.com", Expires: base.Add(time.Hour)})
+	s.store.Upsert(&Rule{ID: "b", Domain: "a.com", Expires: base.Add(2 * time.Hour), SessionID: "sess"})
+	r, ok := s.store.ByDomain("a.com")
+	s.Require().True(ok)
+	s.Empty(r.SessionID)
+
+	// The user blocking a rule the session created takes it over.
+	s.store.Upsert(&Rule{ID: "c", Domain: "c.com", Expires: base.Add(time.Hour), SessionID: "sess", Group: "social"})
+	s.store.Upsert(&Rule{ID: "d", Domain: "c.com", Expires: base.Add(time.Hour)})
+	r, _ = s.store.ByDomain("c.com")
+	s.Empty(r.SessionID)
+	s.Empty(r.Group)
+}
+
+func (s *StoreTestSuite) TestByDomain() {
+	s.store.Upsert(&Rule{
+		ID:      "test1",
+		Domain:  "example.com",
+		Expires: time.Now().Add(time.Hour),
+	})
+	// Merged into test1; the new ID must not leak out.
+	s.store.Upsert(&Rule{
+		ID:      "test2",
+		Domain:  "Example.com",
+		Expires: time.Now().Add(2 * time.Hour),
+	})
+
+	r, ok := s.store.ByDomain("EXAMPLE.COM")
+	s.True(ok)
+	s.Equal("test1", r.ID)
+
+	_, ok = s.store.ByDomain("example.org")
+	s.False(ok)
+}
+
+func (s *StoreTestSuite) TestLookup() {
+	s.store.Upsert(&Rule{ID: "abc123", Domain: "example.com", Permanent: true})
+	s.store.Upsert(&Rule{ID: "abd456", Domain: "example.org", Permanent: true})
+
+	testCases := []struct {
+		name      string
+		ref       string
+		expectID  string
+		expectErr error
+	}{
+		{name: "exact id", ref: "abc123", expectID: "abc123"},
+		{name: "domain", ref: "Example.ORG", expectID: "abd456"},
+		{name: "unique prefix", ref: "abc", expectID: "abc123"},
+		{name: "ambiguous prefix", ref: "ab", expectErr: ErrAmbiguous},
+		{name: "unknown", ref: "zzz", expectErr: ErrNotFound},
+		{name: "empty", ref: "", expectErr: ErrNotFound},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			r, err := s.store.Lookup(tc.ref)
+			if tc.expectErr != nil {
+				s.ErrorIs(err, tc.expectErr)
+				return
+			}
+			s.NoError(err)
+			s.Equal(tc.expectID, r.ID)
+		})
+	}
+}
+
+func (s *StoreTestSuite) TestCovers() {
+	wild := Rule{Domain: "*.example.com"}
+	exact := Rule{Domain: "example.com"}
+
+	s.True(wild.Wildcard())
+	s.Equal("example.com", wild.Zone())
+	s.True(wild.Covers("example.com"))
+	s.True(wild.Covers("www.Example.com."))
+	s.True(wild.Covers("a.b.example.com"))
+	s.False(wild.Covers("badexample.com"))
+
+	s.False(exact.Wildcard())
+	s.True(exact.Covers("example.com"))
+	s.False(exact.Covers("www.example.com"))
+	s.False(IsWildcard("*."))
+}
+
 // Helper function to create time pointer
 func timePtr(t time.Time) *time.Time {
 	return &t
@@ -0,0 +1,241 @@
+// Package schedule describes recurring block windows ("weekdays 09:00–17:00")
+// and persists them for the Void daemon. It only answers questions about
+// time; turning an active window into a pf rule is the engine's job.
+//
+// Windows are evaluated in the location of the time passed in (the daemon
+// uses time.Local). Boundaries are computed with time.Date rather than by
+// adding fixed durations, so a window keeps its wall-clock times across
+// DST transitions.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lc/void/internal/filesys"
+)
+
+// DefaultPath is where the daemon keeps schedules, next to the pf anchor.
+const DefaultPath = "/etc/pf.anchors/void.schedules"
+
+var (
+	// ErrInvalidDays is returned for an unparseable day specification.
+	ErrInvalidDays = errors.New("invalid days")
+	// ErrInvalidClock is returned for an unparseable HH:MM time of day.
+	ErrInvalidClock = errors.New("invalid time of day")
+	// ErrEmptyWindow is returned when a schedule's window has no length.
+	ErrEmptyWindow = errors.New("window start and end are equal")
+)
+
+// Schedule blocks Domain during the daily window [From, To) on each of Days.
+// A window whose To is not after From runs past midnight and ends on the
+// following day.
+type Schedule struct {
+	ID     string   `json:"id"`
+	Domain string   `json:"domain"`
+	Days   Weekdays `json:"days"`
+	From   Clock    `json:"from"`
+	To     Clock    `json:"to"`
+}
+
+// Validate reports whether the schedule can ever be active.
+func (s Schedule) Validate() error {
+	if strings.TrimSpace(s.Domain) == "" {
+		return errors.New("domain required")
+	}
+	if s.Days == 0 {
+		return fmt.Errorf("%w: no days selected", ErrInvalidDays)
+	}
+	if s.From == s.To {
+		return ErrEmptyWindow
+	}
+	return nil
+}
+
+// Window returns the occurrence of the schedule that contains t, if any.
+func (s Schedule) Window(t time.Time) (start, end time.Time, ok bool) {
+	// Only today's window or an overnight window from yesterday can contain t.
+	for _, back := range [...]int{0, 1} {
+		start, end = s.occurrence(t, -back)
+		if s.Days.Has(start.Weekday()) && !t.Before(start) && t.Before(end) {
+			return start, end, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// Next returns the start of the first occurrence beginning after t.
+// It returns the zero time if the schedule has no days.
+func (s Schedule) Next(t time.Time) time.Time {
+	for day := 0; day <= 7; day++ {
+		start, _ := s.occurrence(t, day)
+		if s.Days.Has(start.Weekday()) && start.After(t) {
+			return start
+		}
+	}
+	return time.Time{}
+}
+
+// occurrence returns the window that starts on the calendar day of t
+// shifted by days, whether or not that weekday is selected.
+func (s Schedule) occurrence(t time.Time, days int) (start, end time.Time) {
+	y, m, d := t.Date()
+	loc := t.Location()
+	start = time.Date(y, m, d+days, s.From.Hour(), s.From.Minute(), 0, 0, loc)
+	endDay := d + days
+	if s.To <= s.From {
+		endDay++ // runs past midnight
+	}
+	end = time.Date(y, m, endDay, s.To.Hour(), s.To.Minute(), 0, 0, loc)
+	return start, end
+}
+
+// Clock is a time of day with minute precision, stored as minutes after midnight.
+type Clock int
+
+// ParseClock parses a 24-hour "HH:MM" time of day.
+func ParseClock(s string) (Clock, error) {
+	hh, mm, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidClock, s)
+	}
+	h, errH := strconv.Atoi(hh)
+	m, errM := strconv.Atoi(mm)
+	if errH != nil || errM != nil || h < 0 || h > 23 || m < 0 || m > 59 || len(mm) != 2 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidClock, s)
+	}
+	return Clock(h*60 + m), nil
+}
+
+// Hour returns the hour of the day, 0–23.
+func (c Clock) Hour() int { return int(c) / 60 }
+
+// Minute returns the minute of the hour, 0–59.
+func (c Clock) Minute() int { return int(c) % 60 }
+
+// String formats the clock as "HH:MM".
+func (c Clock) String() string { return fmt.Sprintf("%02d:%02d", c.Hour(), c.Minute()) }
+
+// MarshalText implements encoding.TextMarshaler.
+func (c Clock) MarshalText() ([]byte, error) { return []byte(c.String()), nil }
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *Clock) UnmarshalText(b []byte) error {
+	v, err := ParseClock(string(b))
+	if err != nil {
+		return err
+	}
+	*c = v
+	return nil
+}
+
+// Weekdays is a set of days of the week, one bit per time.Weekday.
+type Weekdays uint8
+
+var _dayNames = [...]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Has reports whether d is in the set.
+func (w Weekdays) Has(d time.Weekday) bool { return w&(1<<d) != 0 }
+
+// ParseWeekdays parses a comma-separated list of day names or ranges,
+// e.g. "mon-fri", "mon,wed,fri" or "sat-sun". The shorthands "daily",
+// "weekdays" and "weekends" are also accepted. Ranges may wrap ("fri-mon").
+func ParseWeekdays(s string) (Weekdays, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "daily", "all":
+		return 0x7f, nil
+	case "weekdays":
+		return ParseWeekdays("mon-fri")
+	case "weekends":
+		return ParseWeekdays("sat-sun")
+	}
+
+	var w Weekdays
+	for _, part := range strings.Split(s, ",") {
+		lo, hi, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		from, ok := dayIndex(lo)
+		if !ok {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidDays, part)
+		}
+		to := from
+		if isRange {
+			if to, ok = dayIndex(hi); !ok {
+				return 0, fmt.Errorf("%w: %q", ErrInvalidDays, part)
+			}
+		}
+		for d := from; ; d = (d + 1) % 7 {
+			w |= 1 << d
+			if d == to {
+				break
+			}
+		}
+	}
+	return w, nil
+}
+
+// String formats the set as a comma-separated list of day names.
+func (w Weekdays) String() string {
+	if w&0x7f == 0x7f {
+		return "daily"
+	}
+	var names []string
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if w.Has(d) {
+			names = append(names, _dayNames[d])
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (w Weekdays) MarshalText() ([]byte, error) { return []byte(w.String()), nil }
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (w *Weekdays) UnmarshalText(b []byte) error {
+	v, err := ParseWeekdays(string(b))
+	if err != nil {
+		return err
+	}
+	*w = v
+	return nil
+}
+
+// dayIndex maps a day name ("mon", "Monday", …) to its time.Weekday value.
+func dayIndex(name string) (int, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if len(name) < 3 {
+		return 0, false
+	}
+	for i, d := range _dayNames {
+		if strings.HasPrefix(name, d) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// FileStore persists schedules as JSON using crash-safe writes.
+type FileStore struct {
+	fs   filesys.FileOps
+	path string
+}
+
+// NewFileStore returns a FileStore backed by the local file at path.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{fs: filesys.OS(), path: path}
+}
+
+// Load reads all schedules. A missing file yields no schedules.
+func (f *FileStore) Load() ([]Schedule, error) {
+	var out []Schedule
+	err := filesys.ReadJSON(f.fs, f.path, &out)
+	return out, err
+}
+
+// Save atomically replaces the stored schedules.
+func (f *FileStore) Save(list []Schedule) error {
+	return filesys.WriteJSON(f.fs, f.path, list, 0o644)
+}
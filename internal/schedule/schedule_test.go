@@ -0,0 +1,138 @@
+package schedule
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ScheduleTestSuite struct {
+	suite.Suite
+	loc *time.Location
+}
+
+func (s *ScheduleTestSuite) SetupTest() {
+	loc, err := time.LoadLocation("America/New_York")
+	s.Require().NoError(err)
+	s.loc = loc
+}
+
+func (s *ScheduleTestSuite) TestParseWeekdays() {
+	testCases := []struct {
+		in       string
+		expected string
+		err      bool
+	}{
+		{in: "mon-fri", expected: "mon,tue,wed,thu,fri"},
+		{in: "Mon,Wed,Friday", expected: "mon,wed,fri"},
+		{in: "fri-mon", expected: "sun,mon,fri,sat"},
+		{in: "weekends", expected: "sun,sat"},
+		{in: "daily", expected: "daily"},
+		{in: "sun-sat", expected: "daily"},
+		{in: "mo", err: true},
+		{in: "mon-xyz", err: true},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.in, func() {
+			w, err := ParseWeekdays(tc.in)
+			if tc.err {
+				s.ErrorIs(err, ErrInvalidDays)
+				return
+			}
+			s.NoError(err)
+			s.Equal(tc.expected, w.String())
+		})
+	}
+}
+
+func (s *ScheduleTestSuite) TestParseClock() {
+	c, err := ParseClock("09:05")
+	s.NoError(err)
+	s.Equal(9, c.Hour())
+	s.Equal(5, c.Minute())
+	s.Equal("09:05", c.String())
+
+	for _, bad := range []string{"", "9", "24:00", "12:60", "12:5", "ab:cd"} {
+		_, err := ParseClock(bad)
+		s.ErrorIs(err, ErrInvalidClock, bad)
+	}
+}
+
+func (s *ScheduleTestSuite) TestWindow() {
+	weekdays, _ := ParseWeekdays("mon-fri")
+	office := Schedule{Days: weekdays, From: 9 * 60, To: 17 * 60}
+	night := Schedule{Days: weekdays, From: 22 * 60, To: 6 * 60}
+
+	at := func(day, hour, minute int) time.Time {
+		// 2025-03-03 is a Monday.
+		return time.Date(2025, 3, day, hour, minute, 0, 0, s.loc)
+	}
+
+	testCases := []struct {
+		name     string
+		sched    Schedule
+		t        time.Time
+		active   bool
+		expStart time.Time
+		expEnd   time.Time
+	}{
+		{name: "inside office hours", sched: office, t: at(3, 10, 0), active: true, expStart: at(3, 9, 0), expEnd: at(3, 17, 0)},
+		{name: "end is exclusive", sched: office, t: at(3, 17, 0)},
+		{name: "before start", sched: office, t: at(3, 8, 59)},
+		{name: "saturday", sched: office, t: at(8, 10, 0)},
+		{name: "overnight before midnight", sched: night, t: at(7, 23, 0), active: true, expStart: at(7, 22, 0), expEnd: at(8, 6, 0)},
+		{name: "overnight after midnight", sched: night, t: at(8, 5, 0), active: true, expStart: at(7, 22, 0), expEnd: at(8, 6, 0)},
+		{name: "overnight not started on saturday", sched: night, t: at(8, 23, 0)},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			start, end, ok := tc.sched.Window(tc.t)
+			s.Equal(tc.active, ok)
+			if tc.active {
+				s.True(tc.expStart.Equal(start), "start %v", start)
+				s.True(tc.expEnd.Equal(end), "end %v", end)
+			}
+		})
+	}
+}
+
+func (s *ScheduleTestSuite) TestWindowAcrossDST() {
+	// Clocks spring forward at 02:00 on 2025-03-09 in New York, so the
+	// overnight window is one hour shorter but keeps its wall-clock end.
+	daily, _ := ParseWeekdays("daily")
+	night := Schedule{Days: daily, From: 22 * 60, To: 6 * 60}
+
+	t := time.Date(2025, 3, 9, 1, 30, 0, 0, s.loc)
+	start, end, ok := night.Window(t)
+	s.True(ok)
+	s.Equal(6, end.Hour())
+	s.Equal(7*time.Hour, end.Sub(start))
+}
+
+func (s *ScheduleTestSuite) TestNext() {
+	weekdays, _ := ParseWeekdays("mon-fri")
+	office := Schedule{Days: weekdays, From: 9 * 60, To: 17 * 60}
+
+	// Friday evening -> Monday morning.
+	t := time.Date(2025, 3, 7, 18, 0, 0, 0, s.loc)
+	s.True(time.Date(2025, 3, 10, 9, 0, 0, 0, s.loc).Equal(office.Next(t)))
+}
+
+func (s *ScheduleTestSuite) TestJSONRoundTrip() {
+	in := Schedule{ID: "a", Domain: "x.com", Days: 0x3e, From: 9 * 60, To: 17 * 60}
+	data, err := json.Marshal(in)
+	s.NoError(err)
+	s.JSONEq(`{"id":"a","domain":"x.com","days":"mon,tue,wed,thu,fri","from":"09:00","to":"17:00"}`, string(data))
+
+	var out Schedule
+	s.NoError(json.Unmarshal(data, &out))
+	s.Equal(in, out)
+}
+
+func TestScheduleSuite(t *testing.T) {
+	suite.Run(t, new(ScheduleTestSuite))
+}
@@ -0,0 +1,42 @@
+package socket
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ErrNoPeerCred is returned when a connection's peer cannot be identified.
+var ErrNoPeerCred = errors.New("peer credentials unavailable")
+
+// PeerCred identifies the process on the other end of a Unix socket.
+type PeerCred struct {
+	UID uint32
+	GID uint32
+	PID int32 // 0 if the OS does not report it
+}
+
+// PeerCredentials returns the credentials the kernel recorded for the
+// process that connected conn. conn must be a *net.UnixConn.
+func PeerCredentials(conn net.Conn) (PeerCred, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return PeerCred{}, fmt.Errorf("%w: %T is not a unix socket", ErrNoPeerCred, conn)
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return PeerCred{}, fmt.Errorf("%w: %w", ErrNoPeerCred, err)
+	}
+
+	var (
+		cred PeerCred
+		cerr error
+	)
+	if err := raw.Control(func(fd uintptr) { cred, cerr = peerCred(int(fd)) }); err != nil {
+		return PeerCred{}, fmt.Errorf("%w: %w", ErrNoPeerCred, err)
+	}
+	if cerr != nil {
+		return PeerCred{}, fmt.Errorf("%w: %w", ErrNoPeerCred, cerr)
+	}
+	return cred, nil
+}
@@ -0,0 +1,19 @@
+package socket
+
+import "golang.org/x/sys/unix"
+
+func peerCred(fd int) (PeerCred, error) {
+	xu, err := unix.GetsockoptXucred(fd, unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+	if err != nil {
+		return PeerCred{}, err
+	}
+	cred := PeerCred{UID: xu.Uid}
+	if xu.Ngroups > 0 {
+		cred.GID = xu.Groups[0]
+	}
+	// The PID is best effort; uid/gid are what matter for auditing.
+	if pid, err := unix.GetsockoptInt(fd, unix.SOL_LOCAL, unix.LOCAL_PEERPID); err == nil {
+		cred.PID = int32(pid)
+	}
+	return cred, nil
+}
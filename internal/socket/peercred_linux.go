@@ -0,0 +1,11 @@
+package socket
+
+import "golang.org/x/sys/unix"
+
+func peerCred(fd int) (PeerCred, error) {
+	uc, err := unix.GetsockoptUcred(fd, unix.SOL_SOCKET, unix.SO_PEERCRED)
+	if err != nil {
+		return PeerCred{}, err
+	}
+	return PeerCred{UID: uc.Uid, GID: uc.Gid, PID: uc.Pid}, nil
+}
@@ -5,6 +5,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
 
@@ -264,6 +265,37 @@ func (s *SocketTestSuite) TestRetryBehavior() {
 	s.Less(duration, 2*time.Second, "Should not have waited too long")
 }
 
+func (s *SocketTestSuite) TestPeerCredentials() {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		s.T().Skip("peer credentials not supported on " + runtime.GOOS)
+	}
+	l, err := socket.Listen(s.sockPath)
+	s.Require().NoError(err)
+	defer l.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := l.Accept()
+		accepted <- conn
+	}()
+
+	client, err := net.Dial("unix", s.sockPath)
+	s.Require().NoError(err)
+	defer client.Close()
+	server := <-accepted
+	s.Require().NotNil(server)
+	defer server.Close()
+
+	// Both ends are this test process.
+	cred, err := socket.PeerCredentials(server)
+	s.Require().NoError(err)
+	s.Equal(uint32(os.Getuid()), cred.UID)
+	s.Equal(int32(os.Getpid()), cred.PID)
+
+	_, err = socket.PeerCredentials(&net.TCPConn{})
+	s.ErrorIs(err, socket.ErrNoPeerCred)
+}
+
 func TestSocketSuite(t *testing.T) {
 	suite.Run(t, new(SocketTestSuite))
 }
@@ -8,67 +8,197 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
+	"go.uber.org/multierr"
+
+	"github.com/lc/void/internal/audit"
 	"github.com/lc/void/internal/buildinfo"
+	"github.com/lc/void/internal/dnsresolver"
 	"github.com/lc/void/internal/engine"
+	"github.com/lc/void/internal/events"
+	"github.com/lc/void/internal/focus"
+	"github.com/lc/void/internal/group"
+	"github.com/lc/void/internal/log"
+	"github.com/lc/void/internal/pf"
+	"github.com/lc/void/internal/rules"
+	"github.com/lc/void/internal/schedule"
 	"github.com/lc/void/internal/socket"
 )
 
-// BlockRequest represents a request to block a domain.
+// BlockRequest represents a request to block a domain or a group.
+// Exactly one of Domain or Group must be set.
 type BlockRequest struct {
-	Domain string        `json:"domain"`
-	TTL    time.Duration `json:"ttl,omitempty"` // 0 = permanent
+	Domain string        `json:"domain,omitempty"`
+	Group  string        `json:"group,omitempty"`
+	TTL    time.Duration `json:"ttl,omitempty"`  // 0 = permanent
+	Lock   time.Duration `json:"lock,omitempty"` // refuse unblock/shortening for this long
+	// Strategy overrides how the blocked names are resolved: "first" or
+	// "union". Empty means the daemon's default.
+	Strategy dnsresolver.Strategy `json:"strategy,omitempty"`
 }
 
 // BlockResponse represents a response to a block request.
+// For a group, IDs lists every member rule and Errors any member that
+// could not be blocked. Pending lists the rules that were kept but could
+// not be resolved yet; the daemon retries them until they resolve.
 type BlockResponse struct {
-	ID string `json:"id"`
+	ID      string   `json:"id,omitempty"`
+	IDs     []string `json:"ids,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+	Pending []string `json:"pending,omitempty"`
 }
 
-// UnblockRequest represents a request to unblock a domain.
+// UnblockRequest represents a request to unblock one or more rules.
+// Each target may be a domain, a rule ID or a unique rule ID prefix.
 type UnblockRequest struct {
-	ID string `json:"id"`
+	ID      string   `json:"id,omitempty"` // Deprecated: use Targets.
+	Targets []string `json:"targets,omitempty"`
+	Group   string   `json:"group,omitempty"` // unblock every rule of this group
+}
+
+// UnblockResponse reports the outcome for every requested target.
+type UnblockResponse struct {
+	Results []UnblockResult `json:"results"`
+}
+
+// UnblockResult is the outcome of unblocking a single target.
+// Exactly one of ID or Error is set.
+type UnblockResult struct {
+	Target string `json:"target"`
+	ID     string `json:"id,omitempty"`
+	Domain string `json:"domain,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ExpiryRequest changes the expiry of an existing rule (PATCH /v1/rules).
+// Exactly one of Extend, TTL or Permanent must be set.
+type ExpiryRequest struct {
+	Target    string        `json:"target"`              // domain, rule ID or unique ID prefix
+	Extend    time.Duration `json:"extend,omitempty"`    // move the current expiry; negative shortens
+	TTL       time.Duration `json:"ttl,omitempty"`       // expire this long from now
+	Permanent bool          `json:"permanent,omitempty"` // make the rule permanent
+	Policy    string        `json:"policy,omitempty"`    // "extend" (default) or "replace"
+}
+
+// RuleResponse is a rule as listed by GET /v1/rules. Counters is nil when
+// pf has not loaded the rule or its counters could not be read.
+type RuleResponse struct {
+	rules.Rule
+	Counters *pf.Counters `json:"counters,omitempty"`
+}
+
+// RuleDetail is a single rule as returned by GET /v1/rules?target=, with
+// the routing suffix its names match and the upstreams that resolve them.
+type RuleDetail struct {
+	RuleResponse
+	Route     string   `json:"route,omitempty"`
+	Upstreams []string `json:"upstreams,omitempty"`
+}
+
+// GroupRequest represents a request to define (or redefine) a group.
+type GroupRequest struct {
+	Name    string   `json:"name"`
+	Domains []string `json:"domains"`
+}
+
+// ScheduleRequest represents a request to add a recurring block window.
+type ScheduleRequest struct {
+	Domain string `json:"domain"`
+	Days   string `json:"days"` // e.g. "mon-fri", "sat,sun", "daily"
+	From   string `json:"from"` // local time of day, "HH:MM"
+	To     string `json:"to"`   // local time of day, "HH:MM"; may be before From
+}
+
+// ScheduleResponse describes a schedule and its current state.
+type ScheduleResponse struct {
+	schedule.Schedule
+	Active bool      `json:"active"`
+	Next   time.Time `json:"next"` // start of the next window
+}
+
+// SessionRequest represents a request to start a focus session.
+// At least one of Domains or Group must be set.
+type SessionRequest struct {
+	Domains []string      `json:"domains,omitempty"`
+	Group   string        `json:"group,omitempty"`
+	Work    time.Duration `json:"work"`
+	Break   time.Duration `json:"break"`
+	Cycles  int           `json:"cycles"`
+}
+
+// SessionResponse describes a focus session and its current phase.
+type SessionResponse struct {
+	focus.Session
+	State focus.State `json:"state"`
 }
 
+// StreamDropped is sent on /v1/events when the client read too slowly and
+// events were discarded.
+const StreamDropped events.Type = "stream.dropped"
+
+// ReasonHeader carries the caller's free-form reason for a change. It is
+// recorded with the change in the audit log.
+const ReasonHeader = "X-Void-Reason"
+
 // StatusResponse represents the server status response.
 type StatusResponse struct {
 	Rules   int           `json:"rules"`
 	Uptime  time.Duration `json:"uptime"`
 	Version string        `json:"version"`
 	Commit  string        `json:"commit"`
+	// Resolvers is the health of each upstream DNS resolver queried so far.
+	Resolvers []dnsresolver.UpstreamHealth `json:"resolvers,omitempty"`
+}
+
+// CacheFlushResponse reports how many DNS answers DELETE /v1/cache dropped.
+type CacheFlushResponse struct {
+	Flushed int `json:"flushed"`
 }
 
 // -------- server -----------------------------------------------------
 
 // Server handles HTTP API requests over a Unix domain socket.
 type Server struct {
-	eng   *engine.Engine
-	start time.Time
-	mux   *http.ServeMux
-	srv   *http.Server
+	eng     *engine.Engine
+	start   time.Time
+	mux     *http.ServeMux
+	srv     *http.Server
+	closing chan struct{} // closed on Shutdown to end long-lived streams
 }
 
 // New creates a new API server with the given engine.
 // It sets up the HTTP routes and returns a server ready to listen.
 func New(eng *engine.Engine) *Server {
 	s := &Server{
-		eng:   eng,
-		start: time.Now(),
-		mux:   http.NewServeMux(),
+		eng:     eng,
+		start:   time.Now(),
+		mux:     http.NewServeMux(),
+		closing: make(chan struct{}),
 	}
 
 	s.mux.HandleFunc("/v1/block", s.handleBlock)
 	s.mux.HandleFunc("/v1/unblock", s.handleUnblock)
 	s.mux.HandleFunc("/v1/status", s.handleStatus)
 	s.mux.HandleFunc("/v1/rules", s.handleRules)
+	s.mux.HandleFunc("/v1/schedules", s.handleSchedules)
+	s.mux.HandleFunc("/v1/groups", s.handleGroups)
+	s.mux.HandleFunc("/v1/sessions", s.handleSessions)
+	s.mux.HandleFunc("/v1/events", s.handleEvents)
+	s.mux.HandleFunc("/v1/audit", s.handleAudit)
+	s.mux.HandleFunc("/v1/cache", s.handleCache)
 
 	s.srv = &http.Server{
-		Handler:           s.mux,
+		Handler:           withReason(s.mux),
 		ReadHeaderTimeout: 10 * time.Second,
+		ConnContext:       withPeer,
 	}
+	s.srv.RegisterOnShutdown(func() { close(s.closing) })
 	return s
 }
 
@@ -84,6 +214,27 @@ func (s *Server) ListenAndServe(path string) error {
 // Shutdown gracefully shuts down the server.
 func (s *Server) Shutdown(ctx context.Context) error { return s.srv.Shutdown(ctx) }
 
+// withPeer attributes every request on conn to the process at the other
+// end of the socket, as reported by the kernel.
+func withPeer(ctx context.Context, conn net.Conn) context.Context {
+	cred, err := socket.PeerCredentials(conn)
+	if err != nil {
+		log.Debugf("api: %v", err)
+		return ctx
+	}
+	return audit.WithActor(ctx, audit.ActorFor(cred))
+}
+
+// withReason moves the ReasonHeader, if any, into the request context.
+func withReason(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reason := strings.TrimSpace(r.Header.Get(ReasonHeader)); reason != "" {
+			r = r.WithContext(audit.WithReason(r.Context(), reason))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // handleBlock adds a domain to the ruleset.
 func (s *Server) handleBlock(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -95,19 +246,51 @@ func (s *Server) handleBlock(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if req.Domain == "" {
-		http.Error(w, "domain required", http.StatusBadRequest)
+	if (req.Domain == "") == (req.Group == "") {
+		http.Error(w, "exactly one of domain or group required", http.StatusBadRequest)
+		return
+	}
+	if req.Lock < 0 || (req.TTL > 0 && req.Lock > req.TTL) {
+		http.Error(w, "lock must not be negative or outlast the block", http.StatusBadRequest)
+		return
+	}
+	if _, err := dnsresolver.ParseStrategy(string(req.Strategy)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err := s.eng.BlockDomain(r.Context(), req.Domain, req.TTL); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if req.Group != "" {
+		res, err := s.eng.BlockGroup(r.Context(), req.Group, req.TTL, req.Lock, req.Strategy)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		resp := BlockResponse{IDs: res.IDs, Pending: s.pending(res.IDs...)}
+		for _, e := range multierr.Errors(res.Failed) {
+			resp.Errors = append(resp.Errors, e.Error())
+		}
+		writeJSON(w, resp)
 		return
 	}
-	// ID is generated inside BlockDomain; we don’t have it here-return 204.
-	w.WriteHeader(http.StatusNoContent)
+	id, err := s.eng.BlockDomain(r.Context(), req.Domain, req.TTL, req.Lock, req.Strategy)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, BlockResponse{ID: id, Pending: s.pending(id)})
 }
 
-// handleUnblock removes a domain from the ruleset.
+// pending returns those of ids whose rules are still pending.
+func (s *Server) pending(ids ...string) []string {
+	var out []string
+	for _, id := range ids {
+		if rule, err := s.eng.Rule(id); err == nil && rule.Pending {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// handleUnblock removes domains or rules from the ruleset.
 func (s *Server) handleUnblock(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -118,12 +301,124 @@ func (s *Server) handleUnblock(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if req.ID == "" {
-		http.Error(w, "id required", http.StatusBadRequest)
+	targets := req.Targets
+	if req.ID != "" {
+		targets = append(targets, req.ID)
+	}
+	if len(targets) == 0 && req.Group == "" {
+		http.Error(w, "targets or group required", http.StatusBadRequest)
+		return
+	}
+	var (
+		results []engine.UnblockResult
+		err     error
+	)
+	if req.Group != "" {
+		results, err = s.eng.UnblockGroup(r.Context(), req.Group)
+	} else {
+		results, err = s.eng.Unblock(r.Context(), targets)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	resp := UnblockResponse{Results: make([]UnblockResult, 0, len(results))}
+	for _, res := range results {
+		out := UnblockResult{Target: res.Target}
+		if res.Err != nil {
+			out.Error = res.Err.Error()
+		} else {
+			out.ID = res.Rule.ID
+			out.Domain = res.Rule.Domain
+		}
+		resp.Results = append(resp.Results, out)
+	}
+	writeJSON(w, resp)
+}
+
+// handleEvents streams engine events as newline-delimited JSON until the
+// client disconnects or the server shuts down. If the client falls behind,
+// the stream carries a StreamDropped event saying how many were lost.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	sub := s.eng.Subscribe(0)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	enc := json.NewEncoder(w)
+	var dropped uint64
+	for {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if n := sub.Dropped(); n > dropped {
+				lost := events.Event{Time: ev.Time, Type: StreamDropped, Detail: fmt.Sprintf("%d events dropped", n-dropped)}
+				dropped = n
+				if enc.Encode(lost) != nil {
+					return
+				}
+			}
+			if enc.Encode(ev) != nil {
+				return // client went away
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-s.closing:
+			return
+		}
+	}
+}
+
+// handleAudit returns the audit log entries of the last ?since= (a
+// duration such as "24h", or an RFC 3339 time). The default is 24h.
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	since, err := parseSince(r.URL.Query().Get("since"), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := s.eng.Audit(since)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if entries == nil {
+		entries = []events.Event{}
+	}
+	writeJSON(w, entries)
+}
+
+// handleCache flushes the daemon's DNS cache (DELETE).
+func (s *Server) handleCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	n, err := s.eng.FlushDNSCache()
+	if err != nil {
+		writeError(w, err)
 		return
 	}
-	s.eng.UnblockDomain(req.ID)
-	w.WriteHeader(http.StatusNoContent)
+	writeJSON(w, CacheFlushResponse{Flushed: n})
 }
 
 // handleStatus returns the server status.
@@ -133,10 +428,11 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	resp := StatusResponse{
-		Rules:   len(s.eng.Snapshot()),
-		Uptime:  time.Since(s.start),
-		Version: buildinfo.Version,
-		Commit:  buildinfo.Commit,
+		Rules:     len(s.eng.Snapshot()),
+		Uptime:    time.Since(s.start),
+		Version:   buildinfo.Version,
+		Commit:    buildinfo.Commit,
+		Resolvers: s.eng.ResolverHealth(),
 	}
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		http.Error(w, fmt.Sprintf("Error encoding response: %v", err), http.StatusInternalServerError)
@@ -144,14 +440,312 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleRules returns the current ruleset.
+// handleRules returns the current ruleset with pf counters (GET), one
+// rule in detail (GET ?target=) or changes the expiry of one rule (PATCH).
 func (s *Server) handleRules(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+	switch r.Method {
+	case http.MethodGet:
+		if target := r.URL.Query().Get("target"); target != "" {
+			s.handleRule(w, r, target)
+			return
+		}
+		snap := s.eng.Snapshot()
+		counters, err := s.eng.Counters(r.Context())
+		if err != nil {
+			log.Debugf("api: rules without counters: %v", err)
+		}
+		resp := make([]RuleResponse, len(snap))
+		for i, rule := range snap {
+			resp[i].Rule = rule
+			if c, ok := counters[rule.ID]; ok {
+				resp[i].Counters = &c
+			}
+		}
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, fmt.Sprintf("Error encoding response: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+	case http.MethodPatch:
+		var req ExpiryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		u, err := parseExpiry(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rule, err := s.eng.UpdateExpiry(r.Context(), req.Target, u)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, rule)
+
+	default:
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRule returns the rule target names along with its pf counters
+// and the route its names are resolved by.
+func (s *Server) handleRule(w http.ResponseWriter, r *http.Request, target string) {
+	rule, err := s.eng.Rule(target)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
-	if err := json.NewEncoder(w).Encode(s.eng.Snapshot()); err != nil {
+	resp := RuleDetail{RuleResponse: RuleResponse{Rule: rule}}
+	resp.Route, resp.Upstreams = s.eng.Route(rule)
+	counters, err := s.eng.Counters(r.Context())
+	if err != nil {
+		log.Debugf("api: rule without counters: %v", err)
+	}
+	if c, ok := counters[rule.ID]; ok {
+		resp.Counters = &c
+	}
+	writeJSON(w, resp)
+}
+
+// handleGroups lists (GET), defines (POST) or deletes (DELETE ?name=) groups.
+func (s *Server) handleGroups(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.eng.Groups())
+
+	case http.MethodPost:
+		var req GroupRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		g := group.New(req.Name, req.Domains)
+		if err := g.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.eng.DefineGroup(r.Context(), g); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, g)
+
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			http.Error(w, "name required", http.StatusBadRequest)
+			return
+		}
+		if err := s.eng.DeleteGroup(r.Context(), name); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSessions inspects (GET), starts (POST) or stops (DELETE) the
+// current focus session.
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		sess, ok := s.eng.Session()
+		if !ok {
+			writeError(w, engine.ErrNoSession)
+			return
+		}
+		writeJSON(w, SessionResponse{Session: sess, State: sess.At(time.Now())})
+
+	case http.MethodPost:
+		var req SessionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sess, err := s.eng.StartSession(r.Context(), focus.Session{
+			Domains: req.Domains,
+			Group:   req.Group,
+			Work:    req.Work,
+			Break:   req.Break,
+			Cycles:  req.Cycles,
+		})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, SessionResponse{Session: sess, State: sess.At(time.Now())})
+
+	case http.MethodDelete:
+		sess, err := s.eng.StopSession(r.Context())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, SessionResponse{Session: sess, State: sess.At(time.Now())})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSchedules lists (GET), adds (POST) or removes (DELETE ?id=) schedules.
+func (s *Server) handleSchedules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		now := time.Now()
+		list := s.eng.Schedules()
+		out := make([]ScheduleResponse, 0, len(list))
+		for _, sc := range list {
+			out = append(out, scheduleResponse(sc, now))
+		}
+		writeJSON(w, out)
+
+	case http.MethodPost:
+		var req ScheduleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sc, err := parseSchedule(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sc, err = s.eng.AddSchedule(r.Context(), sc)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, scheduleResponse(sc, time.Now()))
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id required", http.StatusBadRequest)
+			return
+		}
+		sc, err := s.eng.RemoveSchedule(r.Context(), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, scheduleResponse(sc, time.Now()))
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// -------- helpers ----------------------------------------------------
+
+// parseExpiry validates an ExpiryRequest and converts it.
+func parseExpiry(req ExpiryRequest) (engine.ExpiryUpdate, error) {
+	u := engine.ExpiryUpdate{Extend: req.Extend, TTL: req.TTL, Permanent: req.Permanent}
+	switch req.Policy {
+	case "", rules.ExtendOnly.String():
+		u.Policy = rules.ExtendOnly
+	case rules.Replace.String():
+		u.Policy = rules.Replace
+	default:
+		return u, fmt.Errorf("unknown policy %q", req.Policy)
+	}
+
+	set := 0
+	for _, ok := range []bool{req.Extend != 0, req.TTL != 0, req.Permanent} {
+		if ok {
+			set++
+		}
+	}
+	switch {
+	case req.Target == "":
+		return u, errors.New("target required")
+	case set != 1:
+		return u, errors.New("exactly one of extend, ttl or permanent required")
+	case req.TTL < 0:
+		return u, errors.New("ttl must be positive")
+	}
+	return u, nil
+}
+
+// parseSince parses the since query parameter of the audit log: a duration
+// back from now or an RFC 3339 time. Empty means the last 24 hours.
+func parseSince(v string, now time.Time) (time.Time, error) {
+	if v == "" {
+		return now.Add(-24 * time.Hour), nil
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		if d < 0 {
+			return time.Time{}, errors.New("since must not be negative")
+		}
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since %q: want a duration or RFC 3339 time", v)
+	}
+	return t, nil
+}
+
+// parseSchedule validates a ScheduleRequest and converts it.
+func parseSchedule(req ScheduleRequest) (schedule.Schedule, error) {
+	days, err := schedule.ParseWeekdays(req.Days)
+	if err != nil {
+		return schedule.Schedule{}, err
+	}
+	from, err := schedule.ParseClock(req.From)
+	if err != nil {
+		return schedule.Schedule{}, err
+	}
+	to, err := schedule.ParseClock(req.To)
+	if err != nil {
+		return schedule.Schedule{}, err
+	}
+	sc := schedule.Schedule{Domain: req.Domain, Days: days, From: from, To: to}
+	return sc, sc.Validate()
+}
+
+// scheduleResponse annotates sc with its state at now.
+func scheduleResponse(sc schedule.Schedule, now time.Time) ScheduleResponse {
+	_, _, active := sc.Window(now)
+	return ScheduleResponse{Schedule: sc, Active: active, Next: sc.Next(now)}
+}
+
+// writeJSON encodes v as the response body.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
 		http.Error(w, fmt.Sprintf("Error encoding response: %v", err), http.StatusInternalServerError)
-		return
 	}
 }
+
+// writeError maps engine errors onto HTTP status codes.
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, engine.ErrRuleNotFound), errors.Is(err, engine.ErrGroupNotFound),
+		errors.Is(err, engine.ErrNoSession):
+		code = http.StatusNotFound
+	case errors.Is(err, engine.ErrLocked):
+		code = http.StatusForbidden
+	case errors.Is(err, engine.ErrWouldShorten), errors.Is(err, engine.ErrAmbiguousRef):
+		code = http.StatusConflict
+	case errors.Is(err, engine.ErrInvalidExpiry):
+		code = http.StatusBadRequest
+	case errors.Is(err, engine.ErrSessionActive):
+		code = http.StatusConflict
+	case errors.Is(err, focus.ErrInvalidSession):
+		code = http.StatusBadRequest
+	case errors.Is(err, engine.ErrAuditDisabled), errors.Is(err, engine.ErrNoCache):
+		code = http.StatusNotImplemented
+	case errors.Is(err, engine.ErrResolve):
+		code = http.StatusBadGateway
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		code = http.StatusServiceUnavailable
+	}
+	http.Error(w, err.Error(), code)
+}
@@ -0,0 +1,320 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/lc/void/internal/audit"
+	"github.com/lc/void/internal/dnsresolver"
+	"github.com/lc/void/internal/engine"
+	"github.com/lc/void/internal/events"
+	"github.com/lc/void/internal/focus"
+	"github.com/lc/void/internal/pf"
+	"github.com/lc/void/internal/rules"
+)
+
+// fakePF accepts every ruleset and reports a reload.
+type fakePF struct{}
+
+func (fakePF) CurrentRules() ([]rules.Rule, error) { return nil, fs.ErrNotExist }
+
+func (fakePF) Sync(context.Context, []rules.Rule) (bool, error) { return true, nil }
+
+func (fakePF) Counters(context.Context) (map[string]pf.Counters, error) { return nil, nil }
+
+// fakeResolver resolves every name to 192.0.2.1.
+type fakeResolver struct{}
+
+func (f fakeResolver) LookupHost(ctx context.Context, host string) ([]net.IPAddr, error) {
+	res, err := f.Resolve(ctx, host)
+	return res.Addrs, err
+}
+
+func (fakeResolver) Resolve(context.Context, string) (dnsresolver.Resolution, error) {
+	return dnsresolver.Resolution{Addrs: []net.IPAddr{{IP: net.ParseIP("192.0.2.1")}}, TTL: time.Hour}, nil
+}
+
+// blockedWriter is a streaming ResponseWriter whose writes wait until
+// release is called, like a client that stopped reading.
+type blockedWriter struct {
+	header   http.Header
+	started  chan struct{} // closed by WriteHeader
+	stalled  chan struct{} // closed by the first Write
+	gate     chan struct{}
+	stallOne sync.Once
+	gateOne  sync.Once
+	mu       sync.Mutex
+	body     bytes.Buffer
+}
+
+func newBlockedWriter() *blockedWriter {
+	return &blockedWriter{
+		header:  http.Header{},
+		started: make(chan struct{}),
+		stalled: make(chan struct{}),
+		gate:    make(chan struct{}),
+	}
+}
+
+func (w *blockedWriter) Header() http.Header { return w.header }
+
+func (w *blockedWriter) WriteHeader(int) { close(w.started) }
+
+func (w *blockedWriter) Flush() {}
+
+func (w *blockedWriter) Write(p []byte) (int, error) {
+	w.stallOne.Do(func() { close(w.stalled) })
+	<-w.gate
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.body.Write(p)
+}
+
+func (w *blockedWriter) release() { w.gateOne.Do(func() { close(w.gate) }) }
+
+// lines returns how many lines were written.
+func (w *blockedWriter) lines() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return bytes.Count(w.body.Bytes(), []byte("\n"))
+}
+
+type APITestSuite struct {
+	suite.Suite
+	ctx    context.Context
+	engine *engine.Engine
+	server *Server
+}
+
+func (s *APITestSuite) SetupTest() {
+	s.ctx = context.Background()
+	s.serve()
+}
+
+func (s *APITestSuite) TearDownTest() {
+	s.engine.Close()
+}
+
+// serve replaces the server with one over a new engine built with opts.
+func (s *APITestSuite) serve(opts ...engine.Opt) {
+	s.serveWith(dnsresolver.NewCache(fakeResolver{}), opts...)
+}
+
+func (s *APITestSuite) serveWith(resolver dnsresolver.Clienter, opts ...engine.Opt) {
+	if s.engine != nil {
+		s.engine.Close()
+	}
+	s.engine = engine.New(fakePF{}, resolver, time.Hour, opts...)
+	s.engine.Run(s.ctx)
+	s.server = New(s.engine)
+}
+
+// do sends a request with a JSON body, if any, and returns the response.
+func (s *APITestSuite) do(method, target string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		s.Require().NoError(json.NewEncoder(&buf).Encode(body))
+	}
+	rec := httptest.NewRecorder()
+	s.server.srv.Handler.ServeHTTP(rec, httptest.NewRequest(method, target, &buf))
+	return rec
+}
+
+func (s *APITestSuite) block(domain string) string {
+	rec := s.do(http.MethodPost, "/v1/block", BlockRequest{Domain: domain, TTL: time.Hour})
+	s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	var resp BlockResponse
+	s.Require().NoError(json.NewDecoder(rec.Body).Decode(&resp))
+	return resp.ID
+}
+
+func (s *APITestSuite) TestWriteError() {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{fmt.Errorf("%w: %q", engine.ErrRuleNotFound, "x"), http.StatusNotFound},
+		{engine.ErrGroupNotFound, http.StatusNotFound},
+		{engine.ErrNoSession, http.StatusNotFound},
+		{fmt.Errorf("%w until noon: %q", engine.ErrLocked, "x"), http.StatusForbidden},
+		{engine.ErrWouldShorten, http.StatusConflict},
+		{engine.ErrAmbiguousRef, http.StatusConflict},
+		{engine.ErrInvalidExpiry, http.StatusBadRequest},
+		{engine.ErrSessionActive, http.StatusConflict},
+		{fmt.Errorf("%w: no work", focus.ErrInvalidSession), http.StatusBadRequest},
+		{engine.ErrAuditDisabled, http.StatusNotImplemented},
+		{engine.ErrNoCache, http.StatusNotImplemented},
+		{engine.ErrNoSuchDomain, http.StatusBadGateway},
+		{fmt.Errorf("%w: timeout", engine.ErrResolve), http.StatusBadGateway},
+		{context.DeadlineExceeded, http.StatusServiceUnavailable},
+		{context.Canceled, http.StatusServiceUnavailable},
+		{errors.New("disk full"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		writeError(rec, tt.err)
+		s.Equal(tt.code, rec.Code, "%v", tt.err)
+		s.Equal(tt.err.Error(), strings.TrimSpace(rec.Body.String()))
+	}
+}
+
+func (s *APITestSuite) TestPatchRulesValidation() {
+	s.block("a.com")
+
+	tests := []struct {
+		name string
+		body any
+		code int
+	}{
+		{"malformed", "{", http.StatusBadRequest},
+		{"no target", ExpiryRequest{Extend: time.Hour}, http.StatusBadRequest},
+		{"no change", ExpiryRequest{Target: "a.com"}, http.StatusBadRequest},
+		{"two changes", ExpiryRequest{Target: "a.com", TTL: time.Hour, Permanent: true}, http.StatusBadRequest},
+		{"negative ttl", ExpiryRequest{Target: "a.com", TTL: -time.Hour}, http.StatusBadRequest},
+		{"unknown policy", ExpiryRequest{Target: "a.com", TTL: time.Hour, Policy: "shrink"}, http.StatusBadRequest},
+		{"unknown target", ExpiryRequest{Target: "b.com", Extend: time.Hour}, http.StatusNotFound},
+		{"shortens", ExpiryRequest{Target: "a.com", TTL: time.Minute}, http.StatusConflict},
+		{"extends", ExpiryRequest{Target: "a.com", Extend: time.Hour}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		var rec *httptest.ResponseRecorder
+		if raw, ok := tt.body.(string); ok {
+			rec = httptest.NewRecorder()
+			s.server.srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/v1/rules", strings.NewReader(raw)))
+		} else {
+			rec = s.do(http.MethodPatch, "/v1/rules", tt.body)
+		}
+		s.Equal(tt.code, rec.Code, "%s: %s", tt.name, rec.Body.String())
+	}
+
+	rule, err := s.engine.Rule("a.com")
+	s.Require().NoError(err)
+	s.WithinDuration(time.Now().Add(2*time.Hour), rule.Expires, time.Minute)
+}
+
+func (s *APITestSuite) TestRuleByTarget() {
+	id := s.block("a.com")
+
+	rec := s.do(http.MethodGet, "/v1/rules?target=a.com", nil)
+	s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	var detail RuleDetail
+	s.Require().NoError(json.NewDecoder(rec.Body).Decode(&detail))
+	s.Equal(id, detail.ID)
+	s.Equal("a.com", detail.Domain)
+
+	rec = s.do(http.MethodGet, "/v1/rules?target="+id[:8], nil)
+	s.Equal(http.StatusOK, rec.Code, "a unique ID prefix names the rule")
+
+	rec = s.do(http.MethodGet, "/v1/rules?target=b.com", nil)
+	s.Equal(http.StatusNotFound, rec.Code)
+}
+
+func (s *APITestSuite) TestEventsStream() {
+	ctx, cancel := context.WithCancel(s.ctx)
+	w := newBlockedWriter()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.server.handleEvents(w, httptest.NewRequest(http.MethodGet, "/v1/events", nil).WithContext(ctx))
+	}()
+	<-w.started
+
+	// The stream takes the first event and stalls writing it, while more
+	// than a subscriber's queue of events follows.
+	s.block("first.com")
+	<-w.stalled
+	for i := range events.DefaultBuffer {
+		s.block(fmt.Sprintf("d%d.com", i))
+	}
+	w.release()
+	s.Eventually(func() bool { return w.lines() >= 2 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	s.Equal("application/x-ndjson", w.header.Get("Content-Type"))
+	var got []events.Event
+	sc := bufio.NewScanner(&w.body)
+	for sc.Scan() {
+		var ev events.Event
+		s.Require().NoError(json.Unmarshal(sc.Bytes(), &ev))
+		got = append(got, ev)
+	}
+	s.Require().GreaterOrEqual(len(got), 2)
+	s.Equal(events.RuleAdded, got[0].Type)
+	s.Equal("first.com", got[0].Domain)
+	s.Equal(StreamDropped, got[1].Type, "the stream says events were lost before going on")
+	s.Regexp(`^\d+ events dropped$`, got[1].Detail)
+}
+
+func (s *APITestSuite) TestEventsMethod() {
+	rec := s.do(http.MethodPost, "/v1/events", nil)
+	s.Equal(http.StatusMethodNotAllowed, rec.Code)
+}
+
+func (s *APITestSuite) TestAudit() {
+	rec := s.do(http.MethodGet, "/v1/audit", nil)
+	s.Equal(http.StatusNotImplemented, rec.Code, "no audit log configured")
+
+	l, err := audit.Open(filepath.Join(s.T().TempDir(), "audit.log"))
+	s.Require().NoError(err)
+	defer l.Close()
+	s.serve(engine.WithAuditLog(l))
+	before := time.Now()
+	s.block("a.com")
+
+	decode := func(rec *httptest.ResponseRecorder) []events.Event {
+		s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+		var out []events.Event
+		s.Require().NoError(json.NewDecoder(rec.Body).Decode(&out))
+		return out
+	}
+	got := decode(s.do(http.MethodGet, "/v1/audit?since=1h", nil))
+	s.Require().NotEmpty(got)
+	s.Equal(events.RuleAdded, got[0].Type)
+	s.Equal("a.com", got[0].Domain)
+
+	s.Len(decode(s.do(http.MethodGet, "/v1/audit?since="+before.Add(-time.Second).Format(time.RFC3339), nil)), len(got))
+	s.Equal([]events.Event{}, decode(s.do(http.MethodGet, "/v1/audit?since="+before.Add(time.Hour).Format(time.RFC3339), nil)),
+		"nothing recorded since is an empty list, not null")
+
+	for _, since := range []string{"-1h", "yesterday"} {
+		rec := s.do(http.MethodGet, "/v1/audit?since="+since, nil)
+		s.Equal(http.StatusBadRequest, rec.Code, since)
+	}
+}
+
+func (s *APITestSuite) TestFlushCache() {
+	s.block("a.com")
+
+	rec := s.do(http.MethodDelete, "/v1/cache", nil)
+	s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	var resp CacheFlushResponse
+	s.Require().NoError(json.NewDecoder(rec.Body).Decode(&resp))
+	s.Equal(1, resp.Flushed)
+
+	rec = s.do(http.MethodGet, "/v1/cache", nil)
+	s.Equal(http.StatusMethodNotAllowed, rec.Code)
+
+	s.serveWith(fakeResolver{})
+	rec = s.do(http.MethodDelete, "/v1/cache", nil)
+	s.Equal(http.StatusNotImplemented, rec.Code, "no cache configured")
+}
+
+func TestAPISuite(t *testing.T) {
+	suite.Run(t, new(APITestSuite))
+}
@@ -7,19 +7,27 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/lc/void/internal/dnsresolver"
+	"github.com/lc/void/internal/events"
+	"github.com/lc/void/internal/group"
 	"github.com/lc/void/internal/rules"
 	"github.com/lc/void/pkg/api"
 )
 
 // Client holds an http.Client wired to a Unix socket.
 type Client struct {
-	hc   *http.Client
-	base string // dummy scheme+host for Request.URL (http://unix)
+	hc     *http.Client
+	base   string // dummy scheme+host for Request.URL (http://unix)
+	reason string // sent with every change; see SetReason
 }
 
 // New returns a Client that dials the given Unix‑domain socket path.
@@ -31,19 +39,55 @@ func New(socketPath string) *Client {
 	return &Client{hc: &http.Client{Transport: tr}, base: "http://unix"}
 }
 
+// SetReason attaches reason to every change sent from now on. The daemon
+// records it next to the change in its audit log.
+func (c *Client) SetReason(reason string) {
+	c.reason = reason
+}
+
 // --------------------------- commands ------------------------------
 
-// Block sends a request to block the specified domain.
-// If ttl is 0, the domain will be blocked permanently.
-func (c *Client) Block(ctx context.Context, domain string, ttl time.Duration) error {
-	req := api.BlockRequest{Domain: domain, TTL: ttl}
-	return c.post(ctx, "/v1/block", req)
+// Block sends a request to block the specified domain. The response holds
+// the ID of the rule covering it, which is also listed in Pending if the
+// domain could not be resolved yet. If ttl is 0, the domain will be blocked permanently.
+// A non-zero lock keeps the rule from being unblocked or shortened for that long.
+// A non-empty strategy overrides how the daemon resolves the domain.
+func (c *Client) Block(ctx context.Context, domain string, ttl, lock time.Duration, strategy dnsresolver.Strategy) (api.BlockResponse, error) {
+	req := api.BlockRequest{Domain: domain, TTL: ttl, Lock: lock, Strategy: strategy}
+	var out api.BlockResponse
+	err := c.post(ctx, "/v1/block", req, &out)
+	return out, err
+}
+
+// BlockGroup sends a request to block every domain of the named group with
+// one shared expiry, lock and strategy. If ttl is 0, the group will be blocked permanently.
+func (c *Client) BlockGroup(ctx context.Context, name string, ttl, lock time.Duration, strategy dnsresolver.Strategy) (api.BlockResponse, error) {
+	req := api.BlockRequest{Group: name, TTL: ttl, Lock: lock, Strategy: strategy}
+	var out api.BlockResponse
+	err := c.post(ctx, "/v1/block", req, &out)
+	return out, err
 }
 
-// Unblock sends a request to unblock the rule with the specified ID.
-func (c *Client) Unblock(ctx context.Context, id string) error {
-	req := api.UnblockRequest{ID: id}
-	return c.post(ctx, "/v1/unblock", req)
+// UnblockGroup sends a request to remove every rule blocked through the named group.
+func (c *Client) UnblockGroup(ctx context.Context, name string) ([]api.UnblockResult, error) {
+	req := api.UnblockRequest{Group: name}
+	var out api.UnblockResponse
+	if err := c.post(ctx, "/v1/unblock", req, &out); err != nil {
+		return nil, err
+	}
+	return out.Results, nil
+}
+
+// Unblock sends a request to unblock the given targets, each of which may
+// be a domain, a rule ID or a unique rule ID prefix. It returns the outcome
+// for every target; targets that could not be removed carry an Error.
+func (c *Client) Unblock(ctx context.Context, targets ...string) ([]api.UnblockResult, error) {
+	req := api.UnblockRequest{Targets: targets}
+	var out api.UnblockResponse
+	if err := c.post(ctx, "/v1/unblock", req, &out); err != nil {
+		return nil, err
+	}
+	return out.Results, nil
 }
 
 // Status retrieves the current status of the daemon.
@@ -61,29 +105,184 @@ func (c *Client) Rules(ctx context.Context) ([]rules.Rule, error) {
 	return out, err
 }
 
-// --------------------------- HTTP helpers --------------------------
+// Stats retrieves the current rules along with pf's hit counters.
+func (c *Client) Stats(ctx context.Context) ([]api.RuleResponse, error) {
+	var out []api.RuleResponse
+	err := c.get(ctx, "/v1/rules", &out)
+	return out, err
+}
+
+// Rule retrieves one rule, named by domain, rule ID or unique ID prefix,
+// with its counters and the upstreams its names are resolved with.
+func (c *Client) Rule(ctx context.Context, target string) (api.RuleDetail, error) {
+	var out api.RuleDetail
+	err := c.get(ctx, "/v1/rules?target="+url.QueryEscape(target), &out)
+	return out, err
+}
+
+// UpdateExpiry extends, shortens or converts the expiry of one rule and
+// returns the updated rule.
+func (c *Client) UpdateExpiry(ctx context.Context, req api.ExpiryRequest) (rules.Rule, error) {
+	var out rules.Rule
+	err := c.do(ctx, http.MethodPatch, "/v1/rules", req, &out)
+	return out, err
+}
+
+// Groups retrieves all group definitions.
+func (c *Client) Groups(ctx context.Context) ([]group.Group, error) {
+	var out []group.Group
+	err := c.get(ctx, "/v1/groups", &out)
+	return out, err
+}
+
+// DefineGroup creates or replaces a named group of domains.
+func (c *Client) DefineGroup(ctx context.Context, name string, domains []string) (group.Group, error) {
+	var out group.Group
+	err := c.post(ctx, "/v1/groups", api.GroupRequest{Name: name, Domains: domains}, &out)
+	return out, err
+}
+
+// DeleteGroup removes a group definition.
+func (c *Client) DeleteGroup(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/groups?name="+url.QueryEscape(name), nil, nil)
+}
+
+// Schedules retrieves all recurring block windows.
+func (c *Client) Schedules(ctx context.Context) ([]api.ScheduleResponse, error) {
+	var out []api.ScheduleResponse
+	err := c.get(ctx, "/v1/schedules", &out)
+	return out, err
+}
+
+// AddSchedule registers a recurring block window.
+func (c *Client) AddSchedule(ctx context.Context, req api.ScheduleRequest) (api.ScheduleResponse, error) {
+	var out api.ScheduleResponse
+	err := c.post(ctx, "/v1/schedules", req, &out)
+	return out, err
+}
+
+// RemoveSchedule deletes the schedule whose ID is or starts with id.
+func (c *Client) RemoveSchedule(ctx context.Context, id string) (api.ScheduleResponse, error) {
+	var out api.ScheduleResponse
+	err := c.do(ctx, http.MethodDelete, "/v1/schedules?id="+url.QueryEscape(id), nil, &out)
+	return out, err
+}
+
+// Session retrieves the current focus session and its phase.
+func (c *Client) Session(ctx context.Context) (api.SessionResponse, error) {
+	var out api.SessionResponse
+	err := c.get(ctx, "/v1/sessions", &out)
+	return out, err
+}
+
+// StartSession starts a focus session.
+func (c *Client) StartSession(ctx context.Context, req api.SessionRequest) (api.SessionResponse, error) {
+	var out api.SessionResponse
+	err := c.post(ctx, "/v1/sessions", req, &out)
+	return out, err
+}
+
+// StopSession ends the current focus session and lifts its active blocks.
+func (c *Client) StopSession(ctx context.Context) (api.SessionResponse, error) {
+	var out api.SessionResponse
+	err := c.do(ctx, http.MethodDelete, "/v1/sessions", nil, &out)
+	return out, err
+}
+
+// FlushCache drops every DNS answer the daemon has cached and returns how
+// many there were.
+func (c *Client) FlushCache(ctx context.Context) (int, error) {
+	var out api.CacheFlushResponse
+	err := c.do(ctx, http.MethodDelete, "/v1/cache", nil, &out)
+	return out.Flushed, err
+}
 
-func (c *Client) post(ctx context.Context, path string, payload any) error {
-	buf, err := json.Marshal(payload)
+// Audit retrieves the daemon's audit log entries since the given time,
+// either a duration back from now (e.g. "24h") or an RFC 3339 time.
+// An empty since means the last 24 hours.
+func (c *Client) Audit(ctx context.Context, since string) ([]events.Event, error) {
+	var out []events.Event
+	err := c.get(ctx, "/v1/audit?since="+url.QueryEscape(since), &out)
+	return out, err
+}
+
+// Watch streams daemon events to fn until ctx is cancelled, the daemon
+// closes the stream, or fn returns an error, which Watch then returns.
+// Cancelling ctx is a normal way to stop and yields ctx.Err().
+func (c *Client) Watch(ctx context.Context, fn func(events.Event) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+"/v1/events", nil)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return statusError(resp)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.base+path, bytes.NewReader(buf))
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var ev events.Event
+		if err := dec.Decode(&ev); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return nil // daemon ended the stream
+			}
+			return fmt.Errorf("reading event stream: %w", err)
+		}
+		if err := fn(ev); err != nil {
+			return err
+		}
+	}
+}
+
+// --------------------------- HTTP helpers --------------------------
+
+// post sends payload as JSON and decodes the response into v, if non-nil.
+func (c *Client) post(ctx context.Context, path string, payload any, v any) error {
+	return c.do(ctx, http.MethodPost, path, payload, v)
+}
+
+// do sends payload (if non-nil) as JSON using method and decodes the
+// response into v, if non-nil.
+func (c *Client) do(ctx context.Context, method, path string, payload any, v any) error {
+	var body io.Reader
+	if payload != nil {
+		buf, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.reason != "" {
+		req.Header.Set(api.ReasonHeader, c.reason)
+	}
 	resp, err := c.hc.Do(req)
 	if err != nil {
 		return err
 	}
-	resp.Body.Close()
+	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("daemon returned %s", resp.Status)
+		return statusError(resp)
+	}
+	if v == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
 	}
-	return nil
+	return json.NewDecoder(resp.Body).Decode(v)
 }
 
 func (c *Client) get(ctx context.Context, path string, v any) error {
@@ -98,7 +297,17 @@ func (c *Client) get(ctx context.Context, path string, v any) error {
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("daemon returned %s", resp.Status)
+		return statusError(resp)
 	}
 	return json.NewDecoder(resp.Body).Decode(v)
 }
+
+// statusError builds an error from a non-2xx response, including the
+// message the daemon wrote to the body.
+func statusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if msg := strings.TrimSpace(string(body)); msg != "" {
+		return fmt.Errorf("daemon returned %s: %s", resp.Status, msg)
+	}
+	return fmt.Errorf("daemon returned %s", resp.Status)
+}